@@ -2,4 +2,7 @@ module github.com/teunlao/systemd-security-gate
 
 go 1.22
 
-require github.com/bmatcuk/doublestar/v4 v4.7.1
+require (
+	github.com/bmatcuk/doublestar/v4 v4.7.1
+	gopkg.in/yaml.v3 v3.0.1
+)
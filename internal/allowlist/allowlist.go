@@ -6,16 +6,68 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/teunlao/systemd-security-gate/internal/model"
 )
 
+// dateLayout is the format of the "expires" field.
+const dateLayout = "2006-01-02"
+
 type Allowlist struct {
-	AllowUnits []string `json:"allowUnits,omitempty"`
-	AllowTests []struct {
-		Unit string `json:"unit"`
-		Test string `json:"test"`
-	} `json:"allowTests,omitempty"`
+	AllowUnits []UnitEntry `json:"allowUnits,omitempty"`
+	AllowTests []TestEntry `json:"allowTests,omitempty"`
+}
+
+// Meta documents why an exception exists and until when it applies.
+type Meta struct {
+	Reason string `json:"reason,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+	Ticket string `json:"ticket,omitempty"`
+	// Expires is the last day (YYYY-MM-DD, UTC) the entry allows anything.
+	Expires string `json:"expires,omitempty"`
+	// MaxExposure is a budget: an allowUnits entry only allows the unit while
+	// its overall exposure is at most this, an allowTests entry only allows
+	// the check while its exposure is at most this.
+	MaxExposure *float64 `json:"maxExposure,omitempty"`
+	// Location is the suppression comment an inline entry comes from.
+	Location *model.Location `json:"-"`
+}
+
+type UnitEntry struct {
+	Unit string `json:"unit"`
+	Meta
+}
+
+// UnmarshalJSON accepts a bare unit string as well as an entry object.
+func (e *UnitEntry) UnmarshalJSON(b []byte) error {
+	var unit string
+	if err := json.Unmarshal(b, &unit); err == nil {
+		*e = UnitEntry{Unit: unit}
+		return nil
+	}
+	type plain UnitEntry
+	return json.Unmarshal(b, (*plain)(e))
+}
+
+type TestEntry struct {
+	Unit string `json:"unit"`
+	Test string `json:"test"`
+	Meta
+}
+
+// Decision is the outcome of matching a unit over its threshold against the
+// allowlist.
+type Decision struct {
+	Allowed bool
+	// Expired is set when only expired entries would have allowed the unit.
+	Expired bool
+	// OverBudget is set when entries would have allowed the unit but it
+	// exceeds their maxExposure.
+	OverBudget bool
+	// Exceptions are the entries that allowed the unit, or the expired or
+	// exceeded ones that no longer do.
+	Exceptions []model.Exception
 }
 
 func LoadFile(repoRootAbs string, path string) (Allowlist, error) {
@@ -32,65 +84,231 @@ func LoadFile(repoRootAbs string, path string) (Allowlist, error) {
 		return Allowlist{}, fmt.Errorf("parse %s: %w", path, err)
 	}
 	for i := range a.AllowUnits {
-		a.AllowUnits[i] = normalizeUnitKey(a.AllowUnits[i])
+		a.AllowUnits[i].Unit = normalizeUnitKey(a.AllowUnits[i].Unit)
+		if err := validateUnitKey(a.AllowUnits[i].Unit); err != nil {
+			return Allowlist{}, fmt.Errorf("%s: allowUnits[%d]: %w", path, i, err)
+		}
+		if err := a.AllowUnits[i].validate(); err != nil {
+			return Allowlist{}, fmt.Errorf("%s: allowUnits[%d]: %w", path, i, err)
+		}
 	}
 	for i := range a.AllowTests {
 		a.AllowTests[i].Unit = normalizeUnitKey(a.AllowTests[i].Unit)
 		a.AllowTests[i].Test = strings.TrimSpace(a.AllowTests[i].Test)
+		if err := validateUnitKey(a.AllowTests[i].Unit); err != nil {
+			return Allowlist{}, fmt.Errorf("%s: allowTests[%d]: %w", path, i, err)
+		}
+		if err := validateTestKey(a.AllowTests[i].Test); err != nil {
+			return Allowlist{}, fmt.Errorf("%s: allowTests[%d]: %w", path, i, err)
+		}
+		if err := a.AllowTests[i].validate(); err != nil {
+			return Allowlist{}, fmt.Errorf("%s: allowTests[%d]: %w", path, i, err)
+		}
 	}
 	return a, nil
 }
 
-func (a Allowlist) AllowsUnit(unitKey string) bool {
-	if unitKey == "" {
-		return false
+func (m Meta) validate() error {
+	if m.MaxExposure != nil && *m.MaxExposure < 0 {
+		return fmt.Errorf("maxExposure must not be negative")
+	}
+	if m.Expires == "" {
+		return nil
+	}
+	if _, err := time.Parse(dateLayout, m.Expires); err != nil {
+		return fmt.Errorf("invalid expires %q (want YYYY-MM-DD)", m.Expires)
+	}
+	return nil
+}
+
+// expiresAt returns the moment the entry stops applying: the end of its
+// expiry day.
+func (m Meta) expiresAt() (time.Time, bool) {
+	if m.Expires == "" {
+		return time.Time{}, false
+	}
+	day, err := time.Parse(dateLayout, m.Expires)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day.AddDate(0, 0, 1), true
+}
+
+// fits reports whether exposure is within the entry's budget, if any.
+func (m Meta) fits(exposure float64) bool {
+	return m.MaxExposure == nil || exposure <= *m.MaxExposure+1e-9
+}
+
+func (m Meta) Expired(now time.Time) bool {
+	end, ok := m.expiresAt()
+	return ok && !now.Before(end)
+}
+
+// ExpiresWithin reports whether a still valid entry expires in the next d.
+func (m Meta) ExpiresWithin(now time.Time, d time.Duration) bool {
+	end, ok := m.expiresAt()
+	return ok && now.Before(end) && end.Sub(now) <= d
+}
+
+func (e UnitEntry) exception() model.Exception {
+	return model.Exception{Unit: e.Unit, Reason: e.Reason, Owner: e.Owner, Ticket: e.Ticket, Expires: e.Expires, MaxExposure: e.MaxExposure, Location: e.Location}
+}
+
+func (e TestEntry) exception() model.Exception {
+	return model.Exception{Unit: e.Unit, Test: e.Test, Reason: e.Reason, Owner: e.Owner, Ticket: e.Ticket, Expires: e.Expires, MaxExposure: e.MaxExposure, Location: e.Location}
+}
+
+// Decide matches a unit, known by its repo-relative path, unit name and
+// template name, with the given overall exposure and issues against the
+// allowlist at now.
+func (a Allowlist) Decide(unitKeys []string, overall float64, issues []model.SecurityCheck, now time.Time) Decision {
+	if ok, used := a.allowsUnit(unitKeys, overall, live(now)); ok {
+		return Decision{Allowed: true, Exceptions: used}
+	}
+	if ok, used := a.allowsAllIssues(unitKeys, issues, live(now)); ok {
+		return Decision{Allowed: true, Exceptions: used}
+	}
+
+	// Would expired entries have allowed it? Prefer live entries for the
+	// issues they cover so only the expired ones are reported.
+	all := func(m Meta, exposure float64) bool { return m.fits(exposure) }
+	ok, used := a.allowsUnit(unitKeys, overall, all)
+	if !ok {
+		ok, used = a.allowsAllIssues(unitKeys, issues, live(now), all)
+	}
+	if ok {
+		var expired []model.Exception
+		for _, e := range used {
+			if (Meta{Expires: e.Expires}).Expired(now) {
+				expired = append(expired, e)
+			}
+		}
+		return Decision{Expired: true, Exceptions: expired}
+	}
+
+	// Would it be allowed without budgets? Report the exceeded ones.
+	unbounded := func(m Meta, _ float64) bool { return !m.Expired(now) }
+	ok, used = a.allowsUnit(unitKeys, overall, unbounded)
+	if !ok {
+		ok, used = a.allowsAllIssues(unitKeys, issues, live(now), unbounded)
+	}
+	if !ok {
+		return Decision{}
+	}
+	var exceeded []model.Exception
+	for _, e := range used {
+		if e.MaxExposure != nil && exceedsBudget(e, overall, issues) {
+			exceeded = append(exceeded, e)
+		}
+	}
+	return Decision{OverBudget: true, Exceptions: exceeded}
+}
+
+// exceedsBudget reports whether the unit, or for allowTests entries a check
+// the entry covers, is over the entry's maxExposure.
+func exceedsBudget(e model.Exception, overall float64, issues []model.SecurityCheck) bool {
+	m := Meta{MaxExposure: e.MaxExposure}
+	if e.Test == "" {
+		return !m.fits(overall)
 	}
-	unitKey = normalizeUnitKey(unitKey)
-	for _, u := range a.AllowUnits {
-		if u == unitKey {
+	for _, c := range issues {
+		if matchTest(e.Test, c) && !m.fits(c.Exposure) {
 			return true
 		}
 	}
 	return false
 }
 
-func (a Allowlist) AllowsAllIssues(repoRelPath string, unitName string, issues []model.SecurityCheck) bool {
+// ExpiringWithin lists the entries that are still valid at now but expire in
+// the next d.
+func (a Allowlist) ExpiringWithin(now time.Time, d time.Duration) []model.Exception {
+	var out []model.Exception
+	for _, e := range a.AllowUnits {
+		if e.ExpiresWithin(now, d) {
+			out = append(out, e.exception())
+		}
+	}
+	for _, e := range a.AllowTests {
+		if e.ExpiresWithin(now, d) {
+			out = append(out, e.exception())
+		}
+	}
+	return out
+}
+
+// An acceptor decides whether an entry may cover something with the given
+// exposure: the overall one for allowUnits, the check's for allowTests.
+type acceptor func(m Meta, exposure float64) bool
+
+func live(now time.Time) acceptor {
+	return func(m Meta, exposure float64) bool { return !m.Expired(now) && m.fits(exposure) }
+}
+
+func (a Allowlist) allowsUnit(unitKeys []string, overall float64, include acceptor) (bool, []model.Exception) {
+	for _, k := range unitKeys {
+		for _, u := range a.AllowUnits {
+			if matchUnit(u.Unit, k) && include(u.Meta, overall) {
+				e := u.exception()
+				if IsPattern(u.Unit) {
+					e.MatchedUnit = normalizeUnitKey(k)
+				}
+				return true, []model.Exception{e}
+			}
+		}
+	}
+	return false, nil
+}
+
+// allowsAllIssues covers each issue with the first entry accepted by one of
+// includes, tried in order.
+func (a Allowlist) allowsAllIssues(unitKeys []string, issues []model.SecurityCheck, includes ...acceptor) (bool, []model.Exception) {
 	if len(issues) == 0 {
-		return true
+		return true, nil
 	}
-	unitKeys := []string{normalizeUnitKey(repoRelPath), normalizeUnitKey(unitName)}
 
+	var used []model.Exception
+	byEntry := map[int]int{}
 	for _, issue := range issues {
-		testID := issue.JSONField
-		if testID == "" {
-			testID = issue.Name
-		}
-		if testID == "" {
-			return false
+		if model.CheckID(issue) == "" {
+			return false, nil
 		}
-		allowed := false
-		for _, k := range unitKeys {
-			if a.allowsTest(k, testID) {
-				allowed = true
+		i, key := -1, ""
+		for _, include := range includes {
+			if i, key = a.findTest(unitKeys, issue, include); i >= 0 {
 				break
 			}
 		}
-		if !allowed {
-			return false
+		if i < 0 {
+			return false, nil
+		}
+		t := a.AllowTests[i]
+		j, ok := byEntry[i]
+		if !ok {
+			j = len(used)
+			byEntry[i] = j
+			used = append(used, t.exception())
+			if IsPattern(t.Unit) {
+				used[j].MatchedUnit = normalizeUnitKey(key)
+			}
+		}
+		if IsPattern(t.Test) {
+			used[j].MatchedTests = append(used[j].MatchedTests, model.CheckID(issue))
 		}
 	}
-	return true
+	return true, used
 }
 
-func (a Allowlist) allowsTest(unitKey string, testID string) bool {
-	unitKey = normalizeUnitKey(unitKey)
-	testID = strings.TrimSpace(testID)
-	for _, t := range a.AllowTests {
-		if t.Unit == unitKey && t.Test == testID {
-			return true
+// findTest returns the index of the first entry for issue accepted by
+// include, and the unit key it matched.
+func (a Allowlist) findTest(unitKeys []string, issue model.SecurityCheck, include acceptor) (int, string) {
+	for _, k := range unitKeys {
+		for i, t := range a.AllowTests {
+			if matchUnit(t.Unit, k) && matchTest(t.Test, issue) && include(t.Meta, issue.Exposure) {
+				return i, k
+			}
 		}
 	}
-	return false
+	return -1, ""
 }
 
 func normalizeUnitKey(s string) string {
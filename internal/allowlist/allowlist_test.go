@@ -3,9 +3,12 @@ package allowlist
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/unitfile"
 )
 
 func TestAllowlistAllowsAllIssues(t *testing.T) {
@@ -29,17 +32,314 @@ func TestAllowlistAllowsAllIssues(t *testing.T) {
 		{JSONField: "PrivateNetwork", Exposure: 0.5},
 		{JSONField: "ProtectSystem", Exposure: 0.4},
 	}
-	if !a.AllowsAllIssues("deploy/systemd/myapp.service", "myapp.service", issues) {
+	if d := a.Decide([]string{"deploy/systemd/myapp.service", "myapp.service"}, 7, issues, time.Now()); !d.Allowed {
 		t.Fatalf("expected issues to be allowlisted")
 	}
 }
 
 func TestAllowlistUnitAllow(t *testing.T) {
-	a := Allowlist{AllowUnits: []string{"legacy.service"}}
-	if !a.AllowsUnit("legacy.service") {
+	a := Allowlist{AllowUnits: []UnitEntry{{Unit: "legacy.service"}}}
+	if !a.Decide([]string{"legacy.service"}, 7, nil, time.Now()).Allowed {
 		t.Fatalf("expected unit to be allowed")
 	}
-	if a.AllowsUnit("other.service") {
+	if a.Decide([]string{"other.service"}, 7, []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5}}, time.Now()).Allowed {
 		t.Fatalf("expected other unit to be disallowed")
 	}
 }
+
+func TestLoadFileEntryMetadata(t *testing.T) {
+	repo := t.TempDir()
+	allowPath := filepath.Join(repo, "allow.json")
+	if err := os.WriteFile(allowPath, []byte(`{
+  "allowUnits": [
+    "./legacy.service",
+    { "unit": "vendor.service", "reason": "vendor blob", "owner": "@infra", "ticket": "OPS-1", "expires": "2026-03-31" }
+  ],
+  "allowTests": [
+    { "unit": "myapp.service", "test": "PrivateNetwork", "owner": "@web", "expires": "2026-01-15" }
+  ]
+}`), 0o644); err != nil {
+		t.Fatalf("write allowlist: %v", err)
+	}
+
+	a, err := LoadFile(repo, allowPath)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if a.AllowUnits[0].Unit != "legacy.service" || a.AllowUnits[0].Expires != "" {
+		t.Fatalf("unexpected plain entry: %#v", a.AllowUnits[0])
+	}
+	want := UnitEntry{Unit: "vendor.service", Meta: Meta{Reason: "vendor blob", Owner: "@infra", Ticket: "OPS-1", Expires: "2026-03-31"}}
+	if a.AllowUnits[1] != want {
+		t.Fatalf("entry = %#v, want %#v", a.AllowUnits[1], want)
+	}
+	if a.AllowTests[0].Owner != "@web" || a.AllowTests[0].Expires != "2026-01-15" {
+		t.Fatalf("unexpected test entry: %#v", a.AllowTests[0])
+	}
+
+	if err := os.WriteFile(allowPath, []byte(`{"allowTests": [{"unit": "a.service", "test": "X", "expires": "31/03/2026"}]}`), 0o644); err != nil {
+		t.Fatalf("write allowlist: %v", err)
+	}
+	if _, err := LoadFile(repo, allowPath); err == nil || !strings.Contains(err.Error(), "allowTests[0]: invalid expires") {
+		t.Fatalf("expected invalid expires error, got %v", err)
+	}
+}
+
+func TestDecideHonoursExpiry(t *testing.T) {
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	keys := []string{"deploy/myapp.service", "myapp.service", ""}
+	issues := []model.SecurityCheck{
+		{JSONField: "PrivateNetwork", Exposure: 0.5},
+		{JSONField: "ProtectSystem", Exposure: 0.4},
+	}
+	a := Allowlist{AllowTests: []TestEntry{
+		{Unit: "myapp.service", Test: "PrivateNetwork", Meta: Meta{Expires: "2026-03-30"}},
+		{Unit: "myapp.service", Test: "PrivateNetwork", Meta: Meta{Expires: "2026-03-31"}},
+		{Unit: "deploy/myapp.service", Test: "ProtectSystem", Meta: Meta{Owner: "@web"}},
+	}}
+
+	d := a.Decide(keys, 7, issues, now)
+	if !d.Allowed || d.Expired || len(d.Exceptions) != 2 || d.Exceptions[0].Expires != "2026-03-31" {
+		t.Fatalf("decision = %#v, want allowed by the entries valid through 2026-03-31", d)
+	}
+
+	d = a.Decide(keys, 7, issues, now.Add(2*time.Hour))
+	if d.Allowed || !d.Expired || len(d.Exceptions) != 1 || d.Exceptions[0].Test != "PrivateNetwork" {
+		t.Fatalf("decision = %#v, want expired PrivateNetwork exception only", d)
+	}
+
+	d = a.Decide(keys, 7, append(issues, model.SecurityCheck{JSONField: "NoNewPrivileges", Exposure: 0.2}), now)
+	if d.Allowed || d.Expired || len(d.Exceptions) != 0 {
+		t.Fatalf("decision = %#v, want not allowed", d)
+	}
+
+	units := Allowlist{AllowUnits: []UnitEntry{{Unit: "myapp.service", Meta: Meta{Expires: "2026-01-01"}}}}
+	if d := units.Decide(keys, 7, issues, now); d.Allowed || !d.Expired || d.Exceptions[0].Unit != "myapp.service" {
+		t.Fatalf("decision = %#v, want expired unit exception", d)
+	}
+}
+
+func TestExpiringWithin(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	a := Allowlist{
+		AllowUnits: []UnitEntry{
+			{Unit: "soon.service", Meta: Meta{Expires: "2026-03-10"}},
+			{Unit: "later.service", Meta: Meta{Expires: "2026-06-01"}},
+			{Unit: "gone.service", Meta: Meta{Expires: "2026-02-01"}},
+			{Unit: "forever.service"},
+		},
+		AllowTests: []TestEntry{{Unit: "a.service", Test: "PrivateNetwork", Meta: Meta{Expires: "2026-03-01"}}},
+	}
+	got := a.ExpiringWithin(now, 14*24*time.Hour)
+	if len(got) != 2 || got[0].Unit != "soon.service" || got[1].Test != "PrivateNetwork" {
+		t.Fatalf("ExpiringWithin = %#v", got)
+	}
+}
+
+func TestStaleAndWithout(t *testing.T) {
+	budget, checkBudget := 6.5, 0.5
+	a := Allowlist{
+		AllowUnits: []UnitEntry{
+			{Unit: "renamed.service"},
+			{Unit: "deploy/hardened.service", Meta: Meta{Owner: "@web"}},
+			{Unit: "worker@.service"},
+			{Unit: "deploy/myapp.service", Meta: Meta{MaxExposure: &budget}},
+		},
+		AllowTests: []TestEntry{
+			{Unit: "deploy/myapp.service", Test: "PrivateNetwork"},
+			{Unit: "myapp.service", Test: "ProtectHome"},
+			{Unit: "hardened.service", Test: "PrivateNetwork"},
+			{Unit: "broken.service", Test: "PrivateNetwork"},
+			{Unit: "myapp.service", Test: "ProtectSystem", Meta: Meta{MaxExposure: &checkBudget}},
+			// The budget is the check's, not the unit's overall exposure.
+			{Unit: "myapp.service", Test: "PrivateNetwork", Meta: Meta{MaxExposure: &checkBudget}},
+		},
+	}
+	units := []ScannedUnit{
+		{Keys: []string{"deploy/myapp.service", "myapp.service", ""}, Issues: []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5}, {JSONField: "ProtectSystem", Exposure: 0.6}}, Exposure: 7, NeedsException: true},
+		{Keys: []string{"deploy/hardened.service", "hardened.service", ""}, Issues: []model.SecurityCheck{{JSONField: "PrivateNetwork"}}},
+		{Keys: []string{"deploy/worker@.service", "worker@a.service", "worker@.service"}, NeedsException: true},
+		{Keys: []string{"deploy/broken.service", "broken.service", ""}, Failed: true},
+	}
+
+	stale := a.Stale(units)
+	var got []string
+	for _, e := range stale {
+		got = append(got, e.Describe()+": "+e.Cause)
+	}
+	want := []string{
+		"renamed.service: unit not found",
+		"deploy/hardened.service (owner: @web): unit no longer over threshold",
+		"deploy/myapp.service (max exposure: 6.50): exposure over the entry's maxExposure",
+		"myapp.service / ProtectHome: check no longer exposed",
+		"hardened.service / PrivateNetwork: unit no longer over threshold",
+		"myapp.service / ProtectSystem (max exposure: 0.50): exposure over the entry's maxExposure",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Stale() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	pruned := a.Without(stale)
+	if len(pruned.AllowUnits) != 1 || pruned.AllowUnits[0].Unit != "worker@.service" ||
+		len(pruned.AllowTests) != 3 || pruned.AllowTests[0].Test != "PrivateNetwork" || pruned.AllowTests[1].Unit != "broken.service" ||
+		pruned.AllowTests[2].Unit != "myapp.service" || pruned.AllowTests[2].MaxExposure == nil {
+		t.Fatalf("Without() = %#v", pruned)
+	}
+}
+
+func TestInline(t *testing.T) {
+	comments := []unitfile.Comment{
+		{Text: "ssg:allow PrivateNetwork ProtectHome reason=\"needs outbound HTTP\" expires=2027-01-01", Line: 2, Path: "deploy/myapp.service"},
+		{Text: "ssg:allowed is not a marker", Line: 3, Path: "deploy/myapp.service"},
+		{Text: "just a comment", Line: 4, Path: "deploy/myapp.service"},
+		{Text: "ssg:allow * owner=@web", Line: 1, Path: "deploy/myapp.service.d/10-legacy.conf"},
+	}
+	a, err := Inline("./deploy/myapp.service", comments)
+	if err != nil {
+		t.Fatalf("Inline() error = %v", err)
+	}
+	if len(a.AllowTests) != 2 || a.AllowTests[1].Test != "ProtectHome" || a.AllowTests[1].Unit != "deploy/myapp.service" ||
+		a.AllowTests[1].Reason != "needs outbound HTTP" || a.AllowTests[1].Expires != "2027-01-01" || a.AllowTests[1].Location.Line != 2 {
+		t.Fatalf("allowTests = %#v", a.AllowTests)
+	}
+	if len(a.AllowUnits) != 1 || a.AllowUnits[0].Owner != "@web" || a.AllowUnits[0].Location.Path != "deploy/myapp.service.d/10-legacy.conf" {
+		t.Fatalf("allowUnits = %#v", a.AllowUnits)
+	}
+
+	for text, want := range map[string]string{
+		"ssg:allow reason=x":                    "no checks listed",
+		"ssg:allow PrivateNetwork color=blue":   `unknown field "color"`,
+		"ssg:allow PrivateNetwork expires=soon": `invalid expires "soon"`,
+		`ssg:allow PrivateNetwork reason="open`: "unterminated quote",
+	} {
+		_, err := Inline("a.service", []unitfile.Comment{{Text: text, Line: 7, Path: "a.service"}})
+		if err == nil || !strings.Contains(err.Error(), "a.service:7: ssg:allow: "+want) {
+			t.Errorf("Inline(%q) error = %v, want %q", text, err, want)
+		}
+	}
+
+	// Paths are matched literally, glob characters and "re:" included.
+	for _, path := range []string{"deploy/app[1].service", "re:odd.service", "deploy/{a,b}.service"} {
+		a, err := Inline(path, []unitfile.Comment{{Text: "ssg:allow *", Line: 1, Path: path}})
+		if err != nil {
+			t.Fatalf("Inline(%s) error = %v", path, err)
+		}
+		if !a.Decide([]string{path}, 0, nil, time.Now()).Allowed {
+			t.Errorf("Inline(%s) does not allow its own unit: %#v", path, a.AllowUnits)
+		}
+	}
+	a, _ = Inline("deploy/app[1].service", []unitfile.Comment{{Text: "ssg:allow *", Line: 1, Path: "deploy/app[1].service"}})
+	if a.Decide([]string{"deploy/app1.service"}, 0, []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5}}, time.Now()).Allowed {
+		t.Errorf("Inline(deploy/app[1].service) allows deploy/app1.service")
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	repo := t.TempDir()
+	allowPath := filepath.Join(repo, "allow.json")
+	if err := os.WriteFile(allowPath, []byte(`{
+  "allowUnits": ["deploy/legacy/**"],
+  "allowTests": [
+    { "unit": "worker-*.service", "test": "SystemCallFilter=~@*", "reason": "shared worker profile" },
+    { "unit": "re:^worker-[0-9]+\\.service$", "test": "RestrictAddressFamilies_*" },
+    { "unit": "worker-*.service", "test": "PrivateNetwork" }
+  ]
+}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	a, err := LoadFile(repo, "allow.json")
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if d := a.Decide([]string{"deploy/legacy/old/a.service", "a.service"}, 7, nil, now); !d.Allowed || d.Exceptions[0].MatchedUnit != "deploy/legacy/old/a.service" {
+		t.Fatalf("decision = %#v, want allowed by the glob", d)
+	}
+
+	issues := []model.SecurityCheck{
+		{JSONField: "SystemCallFilter_clock", Name: "SystemCallFilter=~@clock", Exposure: 0.1},
+		{JSONField: "SystemCallFilter_swap", Name: "SystemCallFilter=~@swap", Exposure: 0.1},
+		{JSONField: "RestrictAddressFamilies_AF_PACKET", Name: "RestrictAddressFamilies=~AF_PACKET", Exposure: 0.1},
+		{JSONField: "PrivateNetwork", Name: "PrivateNetwork=", Exposure: 0.5},
+	}
+	d := a.Decide([]string{"deploy/worker-12.service", "worker-12.service"}, 7, issues, now)
+	if !d.Allowed || len(d.Exceptions) != 3 {
+		t.Fatalf("decision = %#v, want allowed by three entries", d)
+	}
+	got := []string{d.Exceptions[0].Describe(), d.Exceptions[1].Describe(), d.Exceptions[2].Describe()}
+	want := []string{
+		"worker-*.service [worker-12.service] / SystemCallFilter=~@* [SystemCallFilter_clock, SystemCallFilter_swap] (reason: shared worker profile)",
+		`re:^worker-[0-9]+\.service$ [worker-12.service] / RestrictAddressFamilies_* [RestrictAddressFamilies_AF_PACKET]`,
+		"worker-*.service [worker-12.service] / PrivateNetwork",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("exceptions =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if d := a.Decide([]string{"deploy/worker-a.service", "worker-a.service"}, 7, issues, now); d.Allowed {
+		t.Fatalf("decision = %#v, want the regex entry not to match worker-a", d)
+	}
+
+	// Regular expressions match whole names, like globs.
+	partial := Allowlist{
+		AllowUnits: []UnitEntry{{Unit: "re:worker"}},
+		AllowTests: []TestEntry{{Unit: "re:.*", Test: "re:PrivateNet"}},
+	}
+	if d := partial.Decide([]string{"deploy/myworker2.service", "myworker2.service"}, 7, issues[3:], now); d.Allowed {
+		t.Fatalf("decision = %#v, want re:worker not to match myworker2.service", d)
+	}
+	if d := partial.Decide([]string{"worker"}, 7, nil, now); !d.Allowed {
+		t.Fatalf("decision = %#v, want re:worker to match worker", d)
+	}
+
+	for _, bad := range []string{
+		`{"allowUnits": ["deploy/[.service"]}`,
+		`{"allowTests": [{"unit": "re:(", "test": "PrivateNetwork"}]}`,
+		`{"allowTests": [{"unit": "a.service", "test": "re:["}]}`,
+	} {
+		if err := os.WriteFile(allowPath, []byte(bad), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := LoadFile(repo, "allow.json"); err == nil || !strings.Contains(err.Error(), "invalid") {
+			t.Errorf("LoadFile(%s) error = %v, want invalid pattern", bad, err)
+		}
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	budget := func(v float64) *float64 { return &v }
+	keys := []string{"deploy/legacy.service", "legacy.service"}
+	issues := []model.SecurityCheck{
+		{JSONField: "PrivateNetwork", Exposure: 0.5},
+		{JSONField: "SystemCallFilter_clock", Exposure: 0.1},
+	}
+
+	units := Allowlist{AllowUnits: []UnitEntry{{Unit: "legacy.service", Meta: Meta{MaxExposure: budget(8.2)}}}}
+	if d := units.Decide(keys, 8.2, issues, now); !d.Allowed {
+		t.Fatalf("decision = %#v, want allowed up to the budget", d)
+	}
+	d := units.Decide(keys, 8.3, issues, now)
+	if d.Allowed || !d.OverBudget || len(d.Exceptions) != 1 || d.Exceptions[0].Describe() != "legacy.service (max exposure: 8.20)" {
+		t.Fatalf("decision = %#v, want over budget", d)
+	}
+
+	tests := Allowlist{AllowTests: []TestEntry{
+		{Unit: "legacy.service", Test: "PrivateNetwork"},
+		{Unit: "legacy.service", Test: "SystemCallFilter_*", Meta: Meta{MaxExposure: budget(0.1)}},
+	}}
+	if d := tests.Decide(keys, 9, issues, now); !d.Allowed || len(d.Exceptions) != 2 {
+		t.Fatalf("decision = %#v, want allowed", d)
+	}
+	issues[1].Exposure = 0.2
+	d = tests.Decide(keys, 9, issues, now)
+	if d.Allowed || !d.OverBudget || len(d.Exceptions) != 1 || d.Exceptions[0].Test != "SystemCallFilter_*" {
+		t.Fatalf("decision = %#v, want the check over its budget", d)
+	}
+
+	// A newly exposed check without an entry is not a budget problem.
+	d = tests.Decide(keys, 9, append(issues[:1], model.SecurityCheck{JSONField: "ProtectHome", Exposure: 0.1}), now)
+	if d.Allowed || d.OverBudget {
+		t.Fatalf("decision = %#v, want plain failure", d)
+	}
+}
@@ -0,0 +1,127 @@
+package allowlist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/unitfile"
+)
+
+// InlineMarker starts a suppression comment in a unit file or drop-in:
+//
+//	# ssg:allow PrivateNetwork ProtectHome reason="needs outbound HTTP" expires=2027-01-01
+//
+// It lists checks like allowTests ("*" allows the whole unit, like
+// allowUnits) followed by reason, owner, ticket, expires and maxExposure.
+const InlineMarker = "ssg:allow"
+
+// Inline collects the suppression comments of a unit, given with their
+// repo-relative paths, into entries for the unit at repoRelPath. The path is
+// matched literally, even if it contains glob characters.
+func Inline(repoRelPath string, comments []unitfile.Comment) (Allowlist, error) {
+	var a Allowlist
+	unitKey := LiteralUnitKey(normalizeUnitKey(repoRelPath))
+	if err := validateUnitKey(unitKey); err != nil {
+		return Allowlist{}, err
+	}
+	for _, c := range comments {
+		rest, ok := strings.CutPrefix(c.Text, InlineMarker)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+		tests, meta, err := parseInline(rest)
+		if err != nil {
+			return Allowlist{}, fmt.Errorf("%s:%d: %s: %w", c.Path, c.Line, InlineMarker, err)
+		}
+		meta.Location = &model.Location{Path: c.Path, Line: c.Line}
+		for _, t := range tests {
+			if t == "*" {
+				a.AllowUnits = append(a.AllowUnits, UnitEntry{Unit: unitKey, Meta: meta})
+				continue
+			}
+			a.AllowTests = append(a.AllowTests, TestEntry{Unit: unitKey, Test: t, Meta: meta})
+		}
+	}
+	return a, nil
+}
+
+func parseInline(s string) ([]string, Meta, error) {
+	fields, err := splitFields(s)
+	if err != nil {
+		return nil, Meta{}, err
+	}
+	var tests []string
+	var m Meta
+	for _, f := range fields {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok {
+			tests = append(tests, f)
+			continue
+		}
+		switch key {
+		case "reason":
+			m.Reason = value
+		case "owner":
+			m.Owner = value
+		case "ticket":
+			m.Ticket = value
+		case "expires":
+			m.Expires = value
+		case "maxExposure":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, Meta{}, fmt.Errorf("invalid maxExposure %q", value)
+			}
+			m.MaxExposure = &v
+		default:
+			return nil, Meta{}, fmt.Errorf("unknown field %q", key)
+		}
+	}
+	if len(tests) == 0 {
+		return nil, Meta{}, fmt.Errorf("no checks listed (use * for the whole unit)")
+	}
+	for _, t := range tests {
+		if err := validateTestKey(t); err != nil {
+			return nil, Meta{}, err
+		}
+	}
+	if err := m.validate(); err != nil {
+		return nil, Meta{}, err
+	}
+	return tests, m, nil
+}
+
+// splitFields splits s at spaces outside double-quoted values, unquoting them.
+func splitFields(s string) ([]string, error) {
+	var fields []string
+	s = strings.TrimSpace(s)
+	for s != "" {
+		end := strings.IndexAny(s, " \t")
+		if eq := strings.Index(s, "=\""); eq >= 0 && (end < 0 || eq < end) {
+			quoted, err := strconv.QuotedPrefix(s[eq+1:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated quote in %q", s)
+			}
+			value, _ := strconv.Unquote(quoted)
+			fields = append(fields, s[:eq+1]+value)
+			s = strings.TrimSpace(s[eq+1+len(quoted):])
+			continue
+		}
+		if end < 0 {
+			end = len(s)
+		}
+		fields = append(fields, s[:end])
+		s = strings.TrimSpace(s[end:])
+	}
+	return fields, nil
+}
+
+// Merge returns an allowlist with the entries of a and then b.
+func (a Allowlist) Merge(b Allowlist) Allowlist {
+	return Allowlist{
+		AllowUnits: append(append([]UnitEntry(nil), a.AllowUnits...), b.AllowUnits...),
+		AllowTests: append(append([]TestEntry(nil), a.AllowTests...), b.AllowTests...),
+	}
+}
@@ -0,0 +1,117 @@
+package allowlist
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+// RegexPrefix marks a unit or test key as a regular expression
+// ("re:worker-[0-9]+\.service"), which must match the whole name like a glob.
+// Other keys are literals or globs: unit keys are doublestar globs like
+// --paths, test keys may use "*" (any text, "/" included) and "?".
+const RegexPrefix = "re:"
+
+// IsPattern reports whether key matches more than its literal value.
+func IsPattern(key string) bool {
+	return strings.HasPrefix(key, RegexPrefix) || strings.ContainsAny(key, "*?[{")
+}
+
+func validateUnitKey(key string) error {
+	if re, ok := strings.CutPrefix(key, RegexPrefix); ok {
+		if _, err := compileAnchored(re); err != nil {
+			return fmt.Errorf("invalid unit pattern %q: %w", key, err)
+		}
+		return nil
+	}
+	if !doublestar.ValidatePattern(key) {
+		return fmt.Errorf("invalid unit glob %q", key)
+	}
+	return nil
+}
+
+// LiteralUnitKey escapes key, such as a repo path, so that it only matches
+// itself as a unit key.
+func LiteralUnitKey(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		// A leading backslash keeps "re:..." paths from reading as regexps.
+		if strings.ContainsRune(`*?[]{}\`, r) || (i == 0 && strings.HasPrefix(key, RegexPrefix)) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func validateTestKey(key string) error {
+	if _, err := testRegexp(key); err != nil {
+		return fmt.Errorf("invalid test pattern %q: %w", key, err)
+	}
+	return nil
+}
+
+// matchUnit reports whether the unit key (a repo-relative path, unit name or
+// template name) matches pattern.
+func matchUnit(pattern, key string) bool {
+	key = normalizeUnitKey(key)
+	if key == "" {
+		return false
+	}
+	if re, ok := strings.CutPrefix(pattern, RegexPrefix); ok {
+		r, err := compileAnchored(re)
+		return err == nil && r.MatchString(key)
+	}
+	if pattern == key {
+		return true
+	}
+	matched, _ := doublestar.Match(pattern, key)
+	return matched
+}
+
+// matchTest reports whether the check's JSON field or name matches pattern.
+func matchTest(pattern string, c model.SecurityCheck) bool {
+	if !IsPattern(pattern) {
+		return pattern == model.CheckID(c) || (c.Name != "" && pattern == c.Name)
+	}
+	re, err := testRegexp(pattern)
+	if err != nil {
+		return false
+	}
+	return (c.JSONField != "" && re.MatchString(c.JSONField)) || (c.Name != "" && re.MatchString(c.Name))
+}
+
+// testRegexp compiles a test key: regular expressions and globs with "*"
+// and "?" as the only wildcards, both anchored.
+func testRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := strings.CutPrefix(pattern, RegexPrefix); ok {
+		return compileAnchored(re)
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// compileAnchored compiles the regular expression of a "re:" key so it only
+// matches whole names.
+func compileAnchored(re string) (*regexp.Regexp, error) {
+	if _, err := regexp.Compile(re); err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^(?:" + re + ")$")
+}
@@ -0,0 +1,163 @@
+package allowlist
+
+import (
+	"encoding/json"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+// Causes of stale entries.
+const (
+	StaleUnitNotFound    = "unit not found"
+	StaleCheckNotExposed = "check no longer exposed"
+	StaleUnitNotFlagged  = "unit no longer over threshold"
+	StaleOverBudget      = "exposure over the entry's maxExposure"
+)
+
+// ScannedUnit is what a scan saw of one unit.
+type ScannedUnit struct {
+	// Keys are the unit's repo-relative path, unit name and template name.
+	Keys []string
+	// Issues are the unit's exposed checks.
+	Issues []model.SecurityCheck
+	// Exposure is the unit's overall exposure, checked against the budgets of
+	// allowUnits entries.
+	Exposure float64
+	// NeedsException is set when the unit exceeds its threshold or regressed
+	// against a baseline, whether or not the scan gates on the baseline.
+	NeedsException bool
+	// Failed is set when the unit could not be analyzed; entries matching it
+	// are never stale.
+	Failed bool
+}
+
+// StaleEntry is an entry that no longer applies to any scanned unit.
+type StaleEntry struct {
+	model.StaleException
+	// Index is the entry's position in AllowTests, or in AllowUnits when
+	// Exception.Test is empty.
+	Index int
+}
+
+// Stale returns the entries that match no unit of a full scan, name a check
+// that is no longer exposed, or whose units no longer need an exception.
+func (a Allowlist) Stale(units []ScannedUnit) []StaleEntry {
+	var out []StaleEntry
+	for i, e := range a.AllowUnits {
+		if cause := staleCause(matching(units, e.Unit), "", e.Meta); cause != "" {
+			out = append(out, StaleEntry{StaleException: model.StaleException{Exception: e.exception(), Cause: cause}, Index: i})
+		}
+	}
+	for i, e := range a.AllowTests {
+		if cause := staleCause(matching(units, e.Unit), e.Test, e.Meta); cause != "" {
+			out = append(out, StaleEntry{StaleException: model.StaleException{Exception: e.exception(), Cause: cause}, Index: i})
+		}
+	}
+	return out
+}
+
+func matching(units []ScannedUnit, unitKey string) []ScannedUnit {
+	var out []ScannedUnit
+	for _, u := range units {
+		for _, k := range u.Keys {
+			if matchUnit(unitKey, k) {
+				out = append(out, u)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// staleCause explains why an entry for test (or the whole unit when test is
+// empty) with meta applies to none of units, or returns "" if it still
+// applies.
+func staleCause(units []ScannedUnit, test string, meta Meta) string {
+	if len(units) == 0 {
+		return StaleUnitNotFound
+	}
+	exposed, needed := false, false
+	for _, u := range units {
+		if u.Failed {
+			return ""
+		}
+		if test != "" && !exposes(u.Issues, test) {
+			continue
+		}
+		exposed = true
+		if !u.NeedsException {
+			continue
+		}
+		needed = true
+		if withinBudget(u, test, meta) {
+			return ""
+		}
+	}
+	switch {
+	case !exposed:
+		return StaleCheckNotExposed
+	case !needed:
+		return StaleUnitNotFlagged
+	default:
+		return StaleOverBudget
+	}
+}
+
+// withinBudget reports whether u is within the entry's budget, as Decide
+// applies it: the overall exposure for a unit entry, the exposure of a
+// covered check for a test entry.
+func withinBudget(u ScannedUnit, test string, meta Meta) bool {
+	if test == "" {
+		return meta.fits(u.Exposure)
+	}
+	for _, c := range u.Issues {
+		if matchTest(test, c) && meta.fits(c.Exposure) {
+			return true
+		}
+	}
+	return false
+}
+
+func exposes(issues []model.SecurityCheck, test string) bool {
+	for _, c := range issues {
+		if matchTest(test, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Without returns the allowlist without the given entries.
+func (a Allowlist) Without(stale []StaleEntry) Allowlist {
+	dropUnits := map[int]bool{}
+	dropTests := map[int]bool{}
+	for _, e := range stale {
+		if e.Test == "" {
+			dropUnits[e.Index] = true
+		} else {
+			dropTests[e.Index] = true
+		}
+	}
+	var out Allowlist
+	for i, e := range a.AllowUnits {
+		if !dropUnits[i] {
+			out.AllowUnits = append(out.AllowUnits, e)
+		}
+	}
+	for i, e := range a.AllowTests {
+		if !dropTests[i] {
+			out.AllowTests = append(out.AllowTests, e)
+		}
+	}
+	return out
+}
+
+// MarshalJSON writes entries without metadata as a bare unit string, the way
+// they are usually written by hand.
+func (e UnitEntry) MarshalJSON() ([]byte, error) {
+	if e.Meta == (Meta{}) {
+		return json.Marshal(e.Unit)
+	}
+	type plain UnitEntry
+	return json.Marshal(plain(e))
+}
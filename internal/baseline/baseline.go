@@ -0,0 +1,84 @@
+// Package baseline compares a scan against a previous JSON report so the gate
+// can fail on regressions instead of an absolute threshold.
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+type Baseline struct {
+	units map[string]model.UnitReport
+}
+
+func LoadFile(repoRootAbs string, path string) (Baseline, error) {
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(repoRootAbs, path)
+	}
+	b, err := os.ReadFile(abs)
+	if err != nil {
+		return Baseline{}, err
+	}
+	var report model.ScanReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return Baseline{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return New(report), nil
+}
+
+// New indexes the analyzed units of report by unit name.
+func New(report model.ScanReport) Baseline {
+	b := Baseline{units: map[string]model.UnitReport{}}
+	for _, u := range report.Units {
+		if u.Error == "" {
+			b.units[u.UnitName] = u
+		}
+	}
+	return b
+}
+
+// Compare reports how u changed since the baseline. A unit regresses when its
+// overall exposure grows or a check that had zero exposure becomes exposed.
+func (b Baseline) Compare(u model.UnitReport) *model.BaselineDelta {
+	prev, ok := b.units[u.UnitName]
+	if !ok {
+		return &model.BaselineDelta{New: true}
+	}
+
+	d := &model.BaselineDelta{
+		PreviousExposure: prev.OverallExposure,
+		Delta:            (tenths(u.OverallExposure) - tenths(prev.OverallExposure)) / 10,
+	}
+
+	before := map[string]float64{}
+	for _, c := range prev.Checks {
+		before[model.CheckID(c)] = c.Exposure
+	}
+	for _, c := range u.Checks {
+		id := model.CheckID(c)
+		was, known := before[id]
+		if !known {
+			continue
+		}
+		switch {
+		case was == 0 && c.Exposure > 0:
+			d.IntroducedChecks = append(d.IntroducedChecks, id)
+		case was > 0 && c.Exposure == 0:
+			d.ResolvedChecks = append(d.ResolvedChecks, id)
+		}
+	}
+	d.Regressed = d.Delta > 0 || len(d.IntroducedChecks) > 0
+	return d
+}
+
+// tenths rounds an exposure to systemd's 0..100 integer scale so deltas don't
+// pick up float noise.
+func tenths(exposure float64) float64 {
+	return math.Round(exposure * 10)
+}
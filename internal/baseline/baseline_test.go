@@ -0,0 +1,101 @@
+package baseline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+func TestCompare(t *testing.T) {
+	base := New(model.ScanReport{Units: []model.UnitReport{
+		{
+			UnitName:        "a.service",
+			OverallExposure: 4.2,
+			Checks: []model.SecurityCheck{
+				{JSONField: "PrivateNetwork", Exposure: 0},
+				{JSONField: "ProtectSystem", Exposure: 0.2},
+				{Name: "SystemCallFilter=~@swap", Exposure: 0.1},
+			},
+		},
+		{UnitName: "broken.service", Error: "boom"},
+	}})
+
+	cases := []struct {
+		name string
+		unit model.UnitReport
+		want model.BaselineDelta
+	}{
+		{
+			name: "unchanged",
+			unit: model.UnitReport{UnitName: "a.service", OverallExposure: 4.2, Checks: []model.SecurityCheck{{JSONField: "ProtectSystem", Exposure: 0.2}}},
+			want: model.BaselineDelta{PreviousExposure: 4.2},
+		},
+		{
+			name: "worse exposure",
+			unit: model.UnitReport{UnitName: "a.service", OverallExposure: 4.3},
+			want: model.BaselineDelta{PreviousExposure: 4.2, Delta: 0.1, Regressed: true},
+		},
+		{
+			name: "new exposed check at lower overall",
+			unit: model.UnitReport{UnitName: "a.service", OverallExposure: 4.0, Checks: []model.SecurityCheck{
+				{JSONField: "PrivateNetwork", Exposure: 0.5},
+				{JSONField: "ProtectSystem", Exposure: 0},
+				{Name: "SystemCallFilter=~@swap", Exposure: 0},
+				{JSONField: "Unknown", Exposure: 0.3},
+			}},
+			want: model.BaselineDelta{
+				PreviousExposure: 4.2,
+				Delta:            -0.2,
+				IntroducedChecks: []string{"PrivateNetwork"},
+				ResolvedChecks:   []string{"ProtectSystem", "SystemCallFilter=~@swap"},
+				Regressed:        true,
+			},
+		},
+		{
+			name: "not in baseline",
+			unit: model.UnitReport{UnitName: "b.service", OverallExposure: 9.6},
+			want: model.BaselineDelta{New: true},
+		},
+		{
+			name: "errored in baseline",
+			unit: model.UnitReport{UnitName: "broken.service", OverallExposure: 1},
+			want: model.BaselineDelta{New: true},
+		},
+	}
+	for _, tc := range cases {
+		if got := base.Compare(tc.unit); !reflect.DeepEqual(*got, tc.want) {
+			t.Errorf("%s: Compare = %#v, want %#v", tc.name, *got, tc.want)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "base.json"), `{"units": [{"unitName": "a.service", "overallExposure": 2.5}]}`)
+	b, err := LoadFile(repo, "base.json")
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if d := b.Compare(model.UnitReport{UnitName: "a.service", OverallExposure: 2.5}); d.New || d.Regressed {
+		t.Fatalf("unexpected delta %#v", d)
+	}
+
+	mustWrite(t, filepath.Join(repo, "bad.json"), `[`)
+	if _, err := LoadFile(repo, "bad.json"); err == nil || !strings.Contains(err.Error(), "parse bad.json") {
+		t.Fatalf("expected parse error, got %v", err)
+	}
+}
+
+func mustWrite(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
@@ -0,0 +1,314 @@
+package checkdoc
+
+import "strings"
+
+// entries document the checks that are not part of a deny-list family.
+var entries = map[string]Doc{
+	"UserOrDynamicUser": {
+		Measures:    "Whether the service runs as root. An unprivileged or dynamic user limits what a compromised service can touch.",
+		Recommended: "`DynamicUser=yes`, or `User=` a dedicated unprivileged user",
+		Breakages:   "Services that write to root-owned paths, bind ports below 1024 or read secrets owned by root; use `StateDirectory=`, `AmbientCapabilities=CAP_NET_BIND_SERVICE` or `LoadCredential=` instead. `DynamicUser=` also implies `ProtectSystem=strict` and `PrivateTmp=yes`.",
+		Related:     []string{"SupplementaryGroups", "PrivateUsers", "NoNewPrivileges"},
+	},
+	"SupplementaryGroups": {
+		Measures:    "Whether the service runs with supplementary groups, which grant access to files beyond its own user.",
+		Recommended: "`SupplementaryGroups=` (empty), or only the groups the service needs",
+		Breakages:   "Services that rely on group membership for devices (`video`, `dialout`) or shared sockets.",
+		Related:     []string{"UserOrDynamicUser"},
+	},
+	"PrivateDevices": {
+		Measures:    "Whether the service sees the host's /dev, and with it physical devices.",
+		Recommended: "`PrivateDevices=yes`",
+		Breakages:   "Services that talk to hardware (GPUs, serial ports, block devices) or need /dev/kvm; allow them with `DeviceAllow=` instead.",
+		Related:     []string{"DeviceAllow", "CapabilityBoundingSet_CAP_MKNOD", "CapabilityBoundingSet_CAP_SYS_RAWIO"},
+	},
+	"PrivateMounts": {
+		Measures:    "Whether mounts made by the service propagate to the host.",
+		Recommended: "`PrivateMounts=yes`",
+		Breakages:   "Services whose job is to mount file systems for other processes (automounters, storage agents).",
+		Related:     []string{"SystemCallFilter_mount", "RestrictNamespaces_mnt"},
+	},
+	"PrivateNetwork": {
+		Measures:    "Whether the service shares the host's network namespace, with access to every interface.",
+		Recommended: "`PrivateNetwork=yes` for services that need no network",
+		Breakages:   "Anything that listens on or connects to the network, including DNS lookups; socket-activated services still receive their sockets.",
+		Related:     []string{"IPAddressDeny", "RestrictAddressFamilies_AF_INET_INET6", "RestrictNamespaces_net"},
+	},
+	"PrivateTmp": {
+		Measures:    "Whether the service shares /tmp and /var/tmp with the rest of the system.",
+		Recommended: "`PrivateTmp=yes`",
+		Breakages:   "Services that exchange files or sockets with other processes through /tmp.",
+		Related:     []string{"ProtectSystem"},
+	},
+	"PrivateUsers": {
+		Measures:    "Whether the service sees the host's users, or runs in a user namespace that maps only its own user and root.",
+		Recommended: "`PrivateUsers=yes`",
+		Breakages:   "Services that change ownership to other users, or that need capabilities on the host (the namespace drops them).",
+		Related:     []string{"UserOrDynamicUser", "RestrictNamespaces_user"},
+	},
+	"ProtectControlGroups": {
+		Measures:    "Whether the service may write to the cgroup file system.",
+		Recommended: "`ProtectControlGroups=yes`",
+		Breakages:   "Container managers and services that manage their own cgroups; use `Delegate=` for those.",
+		Related:     []string{"Delegate", "RestrictNamespaces_cgroup"},
+	},
+	"ProtectKernelModules": {
+		Measures:    "Whether the service may load kernel modules or read /usr/lib/modules.",
+		Recommended: "`ProtectKernelModules=yes`",
+		Breakages:   "Services that load modules on demand (network or storage setup tools).",
+		Related:     []string{"CapabilityBoundingSet_CAP_SYS_MODULE", "SystemCallFilter_module"},
+	},
+	"ProtectKernelTunables": {
+		Measures:    "Whether the service may write kernel variables in /proc/sys, /sys and similar.",
+		Recommended: "`ProtectKernelTunables=yes`",
+		Breakages:   "Services that tune sysctls at startup; move those settings to sysctl.d.",
+		Related:     []string{"ProtectKernelModules", "ProtectKernelLogs"},
+	},
+	"ProtectKernelLogs": {
+		Measures:    "Whether the service may read or write the kernel log ring buffer.",
+		Recommended: "`ProtectKernelLogs=yes`",
+		Breakages:   "Log collectors that read /dev/kmsg or /proc/kmsg.",
+		Related:     []string{"CapabilityBoundingSet_CAP_SYSLOG"},
+	},
+	"ProtectClock": {
+		Measures:    "Whether the service may set the system or hardware clock.",
+		Recommended: "`ProtectClock=yes`",
+		Breakages:   "Time synchronization daemons.",
+		Related:     []string{"CapabilityBoundingSet_CAP_SYS_TIME", "SystemCallFilter_clock"},
+	},
+	"ProtectHome": {
+		Measures:    "How much of /home, /root and /run/user the service can see.",
+		Recommended: "`ProtectHome=yes` (or `read-only`/`tmpfs`)",
+		Breakages:   "Services that read users' home directories, or whose working directory is in /home.",
+		Related:     []string{"ProtectSystem"},
+	},
+	"ProtectHostname": {
+		Measures:    "Whether the service may change the hostname or domain name.",
+		Recommended: "`ProtectHostname=yes`",
+		Breakages:   "Services that set the hostname, such as network configuration tools.",
+		Related:     []string{"RestrictNamespaces_uts"},
+	},
+	"ProtectSystem": {
+		Measures:    "How much of the OS file hierarchy (/usr, /boot, /etc) the service can write.",
+		Recommended: "`ProtectSystem=strict`, with `ReadWritePaths=` for the paths the service writes",
+		Breakages:   "Services that write state or logs outside the paths listed in `ReadWritePaths=`, `StateDirectory=` or `LogsDirectory=`.",
+		Related:     []string{"ProtectHome", "PrivateTmp", "RootDirectoryOrRootImage"},
+	},
+	"RootDirectoryOrRootImage": {
+		Measures:    "Whether the service runs in the host's root directory rather than its own tree or image.",
+		Recommended: "`RootDirectory=` or `RootImage=`",
+		Breakages:   "Anything that needs files from the host; the tree or image must ship the binary and its libraries.",
+		Related:     []string{"ProtectSystem"},
+	},
+	"LockPersonality": {
+		Measures:    "Whether the service may change its execution domain (personality(2)).",
+		Recommended: "`LockPersonality=yes`",
+		Breakages:   "Rarely anything; emulators that run binaries of other architectures.",
+		Related:     []string{"SystemCallArchitectures"},
+	},
+	"MemoryDenyWriteExecute": {
+		Measures:    "Whether the service may create memory that is both writable and executable.",
+		Recommended: "`MemoryDenyWriteExecute=yes`",
+		Breakages:   "JIT compilers: Java, Node.js, .NET, PHP with JIT, LuaJIT and some regex engines.",
+		Related:     []string{"SystemCallFilter_privileged"},
+	},
+	"NoNewPrivileges": {
+		Measures:    "Whether the service's processes may gain privileges through setuid binaries or file capabilities.",
+		Recommended: "`NoNewPrivileges=yes`",
+		Breakages:   "Services that run sudo, su, ping (on older systems) or other setuid helpers.",
+		Related:     []string{"RestrictSUIDSGID", "AmbientCapabilities"},
+	},
+	"UMask": {
+		Measures:    "Whether files the service creates are readable by other users.",
+		Recommended: "`UMask=0077`",
+		Breakages:   "Services that create files or sockets other users must read; loosen to `0027` for group access.",
+		Related:     []string{"ProtectSystem"},
+	},
+	"KeyringMode": {
+		Measures:    "Whether the service shares the kernel keyring with other services.",
+		Recommended: "`KeyringMode=private`",
+		Breakages:   "Services that hand keys to other services through the keyring.",
+		Related:     []string{"UserOrDynamicUser"},
+	},
+	"ProtectProc": {
+		Measures:    "Whether the service can see other users' processes in /proc.",
+		Recommended: "`ProtectProc=invisible`",
+		Breakages:   "Monitoring agents and services that inspect other processes.",
+		Related:     []string{"ProcSubset", "CapabilityBoundingSet_CAP_SYS_PTRACE"},
+	},
+	"ProcSubset": {
+		Measures:    "Whether the service can see the non-process files in /proc (kernel and system information).",
+		Recommended: "`ProcSubset=pid`",
+		Breakages:   "Services that read /proc/meminfo, /proc/cpuinfo or /proc/sys, including many language runtimes.",
+		Related:     []string{"ProtectProc", "ProtectKernelTunables"},
+	},
+	"NotifyAccess": {
+		Measures:    "Whether processes other than the main process may send sd_notify() status updates.",
+		Recommended: "`NotifyAccess=main` (or `none`)",
+		Breakages:   "Type=notify services whose readiness is signaled by a child or a wrapper script.",
+	},
+	"RemoveIPC": {
+		Measures:    "Whether SysV IPC objects and POSIX message queues of the service user outlive the service.",
+		Recommended: "`RemoveIPC=yes`",
+		Breakages:   "Services that keep shared memory around across restarts.",
+		Related:     []string{"UserOrDynamicUser"},
+	},
+	"Delegate": {
+		Measures:    "Whether the service manages its own control group subtree.",
+		Recommended: "`Delegate=no`",
+		Breakages:   "Container runtimes and service managers that need delegation.",
+		Related:     []string{"ProtectControlGroups"},
+		ManURL:      ResourceControlManURL,
+	},
+	"RestrictRealtime": {
+		Measures:    "Whether the service may use realtime scheduling, which can starve the rest of the system.",
+		Recommended: "`RestrictRealtime=yes`",
+		Breakages:   "Audio servers and latency-sensitive services.",
+		Related:     []string{"CapabilityBoundingSet_CAP_SYS_NICE_RESOURCE", "SystemCallFilter_resources"},
+	},
+	"RestrictSUIDSGID": {
+		Measures:    "Whether the service may create setuid or setgid files.",
+		Recommended: "`RestrictSUIDSGID=yes`",
+		Breakages:   "Package managers and installers.",
+		Related:     []string{"NoNewPrivileges", "CapabilityBoundingSet_CAP_CHOWN_FSETID_SETFCAP"},
+	},
+	"SystemCallArchitectures": {
+		Measures:    "Whether the service may use system calls of non-native ABIs (such as 32-bit calls on x86-64), which bypass filters written for the native one.",
+		Recommended: "`SystemCallArchitectures=native`",
+		Breakages:   "32-bit binaries on 64-bit hosts.",
+		Related:     []string{"LockPersonality", "SystemCallFilter_cpu_emulation"},
+	},
+	"IPAddressDeny": {
+		Measures:    "Whether the service may exchange IP traffic with any address.",
+		Recommended: "`IPAddressDeny=any`, with `IPAddressAllow=` for the ranges the service needs",
+		Breakages:   "Connections to addresses missing from `IPAddressAllow=`, including DNS servers; socket-activated listeners are filtered too.",
+		Related:     []string{"PrivateNetwork", "RestrictAddressFamilies_AF_INET_INET6"},
+		ManURL:      ResourceControlManURL,
+	},
+	"DeviceAllow": {
+		Measures:    "Whether the service may open device nodes beyond an explicit allow list.",
+		Recommended: "`DevicePolicy=closed`, with `DeviceAllow=` for the devices the service needs",
+		Breakages:   "Services that open devices missing from `DeviceAllow=`.",
+		Related:     []string{"PrivateDevices"},
+		ManURL:      ResourceControlManURL,
+	},
+	"AmbientCapabilities": {
+		Measures:    "Whether the service's processes receive capabilities even when running as an unprivileged user.",
+		Recommended: "`AmbientCapabilities=` (empty)",
+		Breakages:   "Unprivileged services that bind ports below 1024 (`CAP_NET_BIND_SERVICE`) or need similar single privileges; keep just those.",
+		Related:     []string{"UserOrDynamicUser", "NoNewPrivileges"},
+	},
+}
+
+// capabilityBreakages names what dropping a capability typically breaks.
+var capabilityBreakages = map[string]string{
+	"CAP_SYS_ADMIN":                       "Services that mount file systems, set up namespaces or use many privileged ioctls.",
+	"CAP_SET_UID_GID_PCAP":                "Services that start as root and drop to another user themselves, and login-like programs.",
+	"CAP_SYS_PTRACE":                      "Debuggers, profilers and monitoring agents that read other processes.",
+	"CAP_SYS_TIME":                        "Time synchronization daemons.",
+	"CAP_NET_ADMIN":                       "Network managers, VPNs, firewalls and anything that configures interfaces or routes.",
+	"CAP_SYS_RAWIO":                       "Services that access I/O ports or raw block devices.",
+	"CAP_SYS_MODULE":                      "Services that load kernel modules.",
+	"CAP_AUDIT":                           "Audit daemons and login programs that write audit records.",
+	"CAP_SYSLOG":                          "Log collectors that read the kernel log.",
+	"CAP_SYS_NICE_RESOURCE":               "Services that raise their priority, set realtime scheduling or exceed resource limits.",
+	"CAP_MKNOD":                           "Services that create device nodes.",
+	"CAP_CHOWN_FSETID_SETFCAP":            "Services that chown files to other users, such as installers and backup restorers.",
+	"CAP_DAC_FOWNER_IPC_OWNER":            "Root services that read or write files they do not own; backup agents.",
+	"CAP_KILL":                            "Supervisors that signal processes of other users.",
+	"CAP_NET_BIND_SERVICE_BROADCAST_RAW)": "Servers binding ports below 1024, ping and tools using raw sockets; keep `CAP_NET_BIND_SERVICE` via `AmbientCapabilities=` for unprivileged servers.",
+	"CAP_SYS_BOOT":                        "Services that reboot the machine or load a new kernel.",
+	"CAP_MAC":                             "Services that manage SMACK or other MAC policy.",
+	"CAP_LINUX_IMMUTABLE":                 "Services that set the immutable or append-only file attribute.",
+	"CAP_IPC_LOCK":                        "Services that lock memory, such as secret stores and databases using huge pages.",
+	"CAP_SYS_CHROOT":                      "Services that chroot() themselves.",
+	"CAP_BLOCK_SUSPEND":                   "Services that keep the system awake.",
+	"CAP_WAKE_ALARM":                      "Services that set wake-up alarms.",
+	"CAP_LEASE":                           "Services that take file leases, such as Samba.",
+	"CAP_SYS_TTY_CONFIG":                  "Services that configure terminals.",
+	"CAP_SYS_PACCT":                       "Process accounting tools.",
+	"CAP_BPF":                             "Services that load BPF programs, such as observability agents.",
+}
+
+// syscallBreakages names what denying a system call group typically breaks.
+var syscallBreakages = map[string]string{
+	"swap":          "Services that enable swap.",
+	"obsolete":      "Very old binaries.",
+	"clock":         "Time synchronization daemons.",
+	"cpu-emulation": "DOS and legacy x86 emulators.",
+	"debug":         "Debuggers and profilers; services that trace themselves.",
+	"mount":         "Services that mount file systems or set up their own namespaces.",
+	"module":        "Services that load kernel modules.",
+	"raw-io":        "Services that access I/O ports directly.",
+	"reboot":        "Services that reboot the machine.",
+	"privileged":    "Services that drop privileges themselves (setuid(), chroot()) or need other privileged calls; allow-list with `@system-service` instead.",
+	"resources":     "Services that change priorities, CPU affinity or resource limits.",
+}
+
+// namespaceBreakages names what denying a namespace type typically breaks.
+var namespaceBreakages = map[string]string{
+	"user":   "Browsers and tools that sandbox themselves, container runtimes.",
+	"mnt":    "Services that set up private mounts themselves, container runtimes.",
+	"ipc":    "Container runtimes.",
+	"pid":    "Container runtimes.",
+	"cgroup": "Container runtimes.",
+	"net":    "Container runtimes and services that isolate their children's network.",
+	"uts":    "Container runtimes.",
+}
+
+// describe documents one catalog check.
+func describe(jsonField, name string) Doc {
+	if d, ok := entries[jsonField]; ok {
+		return d
+	}
+	if c, ok := strings.CutPrefix(jsonField, "CapabilityBoundingSet_"); ok {
+		caps := strings.TrimPrefix(name, "CapabilityBoundingSet=~")
+		return Doc{
+			Measures:    "Whether " + caps + " is left in the capability bounding set, so that the service may use the privileges it grants.",
+			Recommended: "`" + name + "`, or a `CapabilityBoundingSet=` that lists only the capabilities the service needs",
+			Breakages:   orDefault(capabilityBreakages[c], "Services that need the capability."),
+			Related:     []string{"AmbientCapabilities", "NoNewPrivileges", "UserOrDynamicUser"},
+		}
+	}
+	if _, ok := strings.CutPrefix(jsonField, "SystemCallFilter_"); ok {
+		group := strings.TrimPrefix(name, "SystemCallFilter=~@")
+		return Doc{
+			Measures:    "Whether the system call filter denies the @" + group + " group.",
+			Recommended: "`" + name + "`, or `SystemCallFilter=@system-service`",
+			Breakages:   orDefault(syscallBreakages[group], "Services that use the group's system calls.") + " Denied calls kill the process with SIGSYS unless `SystemCallErrorNumber=` is set.",
+			Related:     []string{"SystemCallArchitectures"},
+		}
+	}
+	if ns, ok := strings.CutPrefix(jsonField, "RestrictNamespaces_"); ok {
+		return Doc{
+			Measures:    "Whether the service may create " + ns + " namespaces.",
+			Recommended: "`" + name + "`, or `RestrictNamespaces=yes` to deny all",
+			Breakages:   orDefault(namespaceBreakages[ns], "Services that create the namespace."),
+			Related:     []string{"PrivateUsers", "CapabilityBoundingSet_CAP_SYS_ADMIN"},
+		}
+	}
+	if _, ok := strings.CutPrefix(jsonField, "RestrictAddressFamilies_"); ok {
+		d := Doc{
+			Measures:    "Whether the service may create sockets of the " + strings.TrimPrefix(name, "RestrictAddressFamilies=~") + " address families.",
+			Recommended: "`RestrictAddressFamilies=` listing only the families the service uses, e.g. `AF_UNIX AF_INET AF_INET6`",
+			Breakages:   "Services using a family missing from the list: AF_UNIX for journald and D-Bus, AF_NETLINK for interface and route lookups, AF_INET/AF_INET6 for any network access.",
+			Related:     []string{"PrivateNetwork", "IPAddressDeny"},
+		}
+		if jsonField == "RestrictAddressFamilies_OTHER" {
+			d.Measures = "Whether the service may create sockets of families other than AF_INET, AF_INET6, AF_UNIX, AF_NETLINK and AF_PACKET."
+		}
+		return d
+	}
+	return Doc{
+		Measures:    "The " + strings.TrimSuffix(name, "=") + " setting.",
+		Recommended: "`" + name + "`",
+		Breakages:   "Services that depend on the access the setting removes.",
+	}
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
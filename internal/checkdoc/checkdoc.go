@@ -0,0 +1,187 @@
+// Package checkdoc documents the checks of "systemd-analyze security": what
+// each measures, how much it weighs, how to fix it and what the fix may
+// break. It backs "ssg explain" and the help texts of the reports.
+package checkdoc
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/nativeanalyze"
+)
+
+const (
+	ExecManURL            = "https://www.freedesktop.org/software/systemd/man/systemd.exec.html"
+	ResourceControlManURL = "https://www.freedesktop.org/software/systemd/man/systemd.resource-control.html"
+)
+
+// Doc describes one check. Recommended, Breakages and Measures are Markdown
+// sentences; Related lists JSON fields.
+type Doc struct {
+	Name           string
+	JSONField      string
+	DescriptionBad string
+	Weight         uint64
+	Range          uint64
+
+	Measures    string
+	Recommended string
+	Breakages   string
+	Related     []string
+	ManURL      string
+}
+
+// ManPage names the man page at ManURL.
+func (d Doc) ManPage() string {
+	if d.ManURL == ResourceControlManURL {
+		return "systemd.resource-control(5)"
+	}
+	return "systemd.exec(5)"
+}
+
+// Directives are the unit settings the check is about ("User=/DynamicUser="
+// is about User and DynamicUser).
+func (d Doc) Directives() []string {
+	var keys []string
+	for _, part := range strings.Split(d.Name, "/") {
+		if key, _, ok := strings.Cut(part, "="); ok && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+var (
+	docs    []Doc
+	byField map[string]int
+	// totalWeight sums the weights of all checks, the denominator of the
+	// overall exposure when every check applies.
+	totalWeight uint64
+)
+
+func init() {
+	catalog, err := nativeanalyze.Catalog("")
+	if err != nil {
+		panic(fmt.Sprintf("checkdoc: %v", err))
+	}
+	byField = make(map[string]int, len(catalog))
+	for _, c := range catalog {
+		d := describe(c.JSONField, c.Name)
+		d.Name = c.Name
+		d.JSONField = c.JSONField
+		d.DescriptionBad = c.DescriptionBad
+		d.Weight = c.Weight
+		d.Range = c.Range
+		if d.ManURL == "" {
+			d.ManURL = ExecManURL
+		}
+		byField[c.JSONField] = len(docs)
+		docs = append(docs, d)
+		totalWeight += c.Weight
+	}
+}
+
+// All returns the docs in systemd's table order.
+func All() []Doc {
+	return append([]Doc(nil), docs...)
+}
+
+// Get returns the doc of the check with the given JSON field.
+func Get(jsonField string) (Doc, bool) {
+	i, ok := byField[jsonField]
+	if !ok {
+		return Doc{}, false
+	}
+	return docs[i], true
+}
+
+// Find looks checks up by JSON field ("SystemCallFilter_clock"), name
+// ("SystemCallFilter=~@clock", "PrivateNetwork=" or "PrivateNetwork"),
+// directive ("RestrictAddressFamilies" matches all its checks) or deny-list
+// entry ("CAP_KILL", "@clock", or "CAP_NET_RAW" of the grouped
+// "CAP_NET_(BIND_SERVICE|BROADCAST|RAW)"), in that order, ignoring case.
+func Find(query string) []Doc {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return nil
+	}
+	for _, d := range docs {
+		name := strings.ToLower(d.Name)
+		if strings.ToLower(d.JSONField) == q || name == q || strings.TrimSuffix(name, "=") == q {
+			return []Doc{d}
+		}
+	}
+	// Directives before deny-list entries: "User" is the directive, not
+	// RestrictNamespaces=~user.
+	key := strings.TrimSuffix(q, "=")
+	var out []Doc
+	for _, d := range docs {
+		for _, k := range d.Directives() {
+			if strings.ToLower(k) == key {
+				out = append(out, d)
+				break
+			}
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	for _, d := range docs {
+		if _, entry, ok := strings.Cut(strings.ToLower(d.Name), "=~"); ok && (entry == q || strings.TrimPrefix(entry, "@") == q || groupHas(entry, q)) {
+			return []Doc{d}
+		}
+	}
+	return nil
+}
+
+// groupHas reports whether the deny-list entry of a grouped check, such as
+// "cap_net_(bind_service|broadcast|raw)" or "cap_mac_*", names q.
+func groupHas(entry, q string) bool {
+	alts := []string{entry}
+	if open := strings.Index(entry, "("); open >= 0 {
+		if end := strings.Index(entry[open:], ")"); end >= 0 {
+			prefix, group, suffix := entry[:open], entry[open+1:open+end], entry[open+end+1:]
+			alts = nil
+			for _, alt := range strings.Split(group, "|") {
+				alts = append(alts, prefix+alt+suffix)
+			}
+		}
+	}
+	for _, alt := range alts {
+		if ok, _ := path.Match(alt, q); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxExposure is how much the check adds to the overall exposure (0-10)
+// when it is fully exposed and every check applies.
+func (d Doc) MaxExposure() float64 {
+	if totalWeight == 0 {
+		return 0
+	}
+	return float64(d.Weight) / float64(totalWeight) * 10
+}
+
+// Explain renders the doc for the terminal.
+func (d Doc) Explain() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n\n", d.Name, d.JSONField)
+	fmt.Fprintf(&b, "What it measures:\n  %s\n\n", d.Measures)
+	if d.DescriptionBad != "" {
+		fmt.Fprintf(&b, "Reported when exposed:\n  %s\n\n", d.DescriptionBad)
+	}
+	fmt.Fprintf(&b, "Weight:\n  %d (range %d); fully exposed, it adds about %.2f to the overall exposure.\n\n", d.Weight, d.Range, d.MaxExposure())
+	fmt.Fprintf(&b, "Recommended:\n  %s\n\n", d.Recommended)
+	fmt.Fprintf(&b, "Common breakages:\n  %s\n\n", d.Breakages)
+	if len(d.Related) > 0 {
+		related := append([]string(nil), d.Related...)
+		sort.Strings(related)
+		fmt.Fprintf(&b, "Related checks:\n  %s\n\n", strings.Join(related, ", "))
+	}
+	fmt.Fprintf(&b, "See %s: %s\n", d.ManPage(), d.ManURL)
+	return b.String()
+}
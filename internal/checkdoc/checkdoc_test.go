@@ -0,0 +1,66 @@
+package checkdoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEveryCheckIsDocumented(t *testing.T) {
+	for _, d := range All() {
+		if d.Measures == "" || d.Recommended == "" || d.Breakages == "" || d.ManURL == "" {
+			t.Errorf("%s: incomplete doc %#v", d.JSONField, d)
+		}
+		if strings.HasPrefix(d.Measures, "The ") {
+			t.Errorf("%s: generic description, add an entry", d.JSONField)
+		}
+		for _, r := range d.Related {
+			if _, ok := Get(r); !ok {
+				t.Errorf("%s: unknown related check %s", d.JSONField, r)
+			}
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"PrivateNetwork", []string{"PrivateNetwork"}},
+		{"privatenetwork=", []string{"PrivateNetwork"}},
+		{"UserOrDynamicUser", []string{"UserOrDynamicUser"}},
+		{"DynamicUser", []string{"UserOrDynamicUser"}},
+		{"User", []string{"UserOrDynamicUser"}},
+		{"user", []string{"UserOrDynamicUser"}},
+		{"User=/DynamicUser=", []string{"UserOrDynamicUser"}},
+		{"CAP_SYS_ADMIN", []string{"CapabilityBoundingSet_CAP_SYS_ADMIN"}},
+		{"@raw-io", []string{"SystemCallFilter_raw_io"}},
+		{"RestrictNamespaces=~user", []string{"RestrictNamespaces_user"}},
+		{"CAP_NET_RAW", []string{"CapabilityBoundingSet_CAP_NET_BIND_SERVICE_BROADCAST_RAW)"}},
+		{"cap_setpcap", []string{"CapabilityBoundingSet_CAP_SET_UID_GID_PCAP"}},
+		{"CAP_DAC_READ_SEARCH", []string{"CapabilityBoundingSet_CAP_DAC_FOWNER_IPC_OWNER"}},
+		{"CAP_MAC_ADMIN", []string{"CapabilityBoundingSet_CAP_MAC"}},
+		{"AF_INET6", []string{"RestrictAddressFamilies_AF_INET_INET6"}},
+		{"CAP_NET", nil},
+		{"nope", nil},
+	} {
+		var got []string
+		for _, d := range Find(tc.query) {
+			got = append(got, d.JSONField)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("Find(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+	if n := len(Find("CapabilityBoundingSet")); n != 26 {
+		t.Errorf("Find(CapabilityBoundingSet) = %d checks, want 26", n)
+	}
+}
+
+func TestExplainUsesResourceControlManPage(t *testing.T) {
+	d, _ := Get("IPAddressDeny")
+	out := d.Explain()
+	if !strings.Contains(out, "See systemd.resource-control(5): "+ResourceControlManURL) {
+		t.Fatalf("Explain() =\n%s", out)
+	}
+}
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/teunlao/systemd-security-gate/internal/allowlist"
+	"github.com/teunlao/systemd-security-gate/internal/baseline"
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+func runAllowlist(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: ssg allowlist prune [--dry-run] [flags]")
+		return 2
+	}
+	switch args[0] {
+	case "prune":
+		return runAllowlistPrune(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown allowlist command: %s\n", args[0])
+		return 2
+	}
+}
+
+func runAllowlistPrune(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("allowlist prune", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	f := addScanFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "List the stale entries without rewriting the allowlist")
+	baselinePath := fs.String("baseline", "", "Path to the baseline report the scan gates on; entries of units that regressed against it are kept (optional)")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	s, code := f.newScanner(stderr, *baselinePath == "")
+	if s == nil {
+		return code
+	}
+	if *f.allowlistPath == "" {
+		fmt.Fprintln(stderr, "error: --allowlist is required (or set allowlist in the config file)")
+		return 2
+	}
+	var base *baseline.Baseline
+	if *baselinePath != "" {
+		b, err := baseline.LoadFile(s.repoAbs, *baselinePath)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: load baseline: %v\n", err)
+			return 2
+		}
+		base = &b
+	}
+
+	matches, units, err := s.scanTree(s.repoAbs, f.paths, f.exclude, base)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(stderr, "error: no unit files matched --paths")
+		return 1
+	}
+	// Entries of units that failed analysis are kept, but the scan still
+	// fails so the result is not mistaken for a complete prune.
+	exit := 0
+	for _, u := range units {
+		if u.Error != "" {
+			fmt.Fprintf(stderr, "error: %s: %s\n", u.UnitName, u.Error)
+			exit = 1
+		}
+	}
+
+	stale := s.staleAllowlist(units)
+	if len(stale) == 0 {
+		fmt.Fprintln(stdout, "No stale allowlist entries.")
+		return exit
+	}
+	verb := "removed"
+	if *dryRun {
+		verb = "would remove"
+	}
+	for _, e := range stale {
+		fmt.Fprintf(stdout, "%s %s (%s)\n", verb, e.Describe(), e.Cause)
+	}
+	if *dryRun {
+		return exit
+	}
+
+	b, err := json.MarshalIndent(s.allow.Without(stale), "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(repoPath(s.repoAbs, *f.allowlistPath), append(b, '\n'), 0o644); err != nil {
+		fmt.Fprintf(stderr, "error: write allowlist: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "wrote %s\n", *f.allowlistPath)
+	return exit
+}
+
+// needsException reports whether allowlist entries still serve u: it
+// exceeds its threshold or regressed. Gated on a baseline without a threshold
+// of its own, a unit may regress on any later change, so it keeps its entries.
+func needsException(u model.UnitReport) bool {
+	if u.ThresholdExceeded {
+		return true
+	}
+	return u.Baseline != nil && (u.Baseline.Regressed || u.Threshold >= maxExposure)
+}
+
+// staleAllowlist returns the allowlist entries that no unit of a full scan
+// uses.
+func (s *scanner) staleAllowlist(units []model.UnitReport) []allowlist.StaleEntry {
+	scanned := make([]allowlist.ScannedUnit, 0, len(units))
+	for _, u := range units {
+		su := allowlist.ScannedUnit{
+			Keys:           []string{u.RepoRelPath, u.UnitName, u.Template},
+			Issues:         model.Issues(u.Checks),
+			Exposure:       u.OverallExposure,
+			NeedsException: needsException(u),
+			Failed:         u.Error != "",
+		}
+		scanned = append(scanned, su)
+	}
+	return s.allow.Stale(scanned)
+}
@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+func TestStaleAllowlistEntries(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/usr/bin/myapp\nPrivateNetwork=yes\n")
+	mustWrite(t, filepath.Join(repo, "allow.json"), `{
+  "allowUnits": ["deploy/renamed.service"],
+  "allowTests": [
+    { "unit": "myapp.service", "test": "PrivateNetwork", "owner": "@web" },
+    { "unit": "myapp.service", "test": "ProtectSystem" }
+  ]
+}`)
+	run := func(args ...string) (int, string, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := Run(append(append([]string{"ssg"}, args...),
+			"--repo-root", repo,
+			"--paths", "deploy/*.service",
+			"--threshold", "5",
+			"--backend", "native",
+			"--allowlist", "allow.json",
+		), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+	code, out, stderr := run("scan", "--json-report", jsonReport)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1 (not every issue is allowed)\nstdout:\n%s\nstderr:\n%s", code, out, stderr)
+	}
+	for _, want := range []string{
+		"warning: stale allowlist entry (unit not found): deploy/renamed.service",
+		"warning: stale allowlist entry (check no longer exposed): myapp.service / PrivateNetwork (owner: @web)",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Fatalf("expected %q in stderr:\n%s", want, stderr)
+		}
+	}
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if len(report.StaleExceptions) != 2 || report.StaleExceptions[1].Test != "PrivateNetwork" || report.StaleExceptions[1].Cause != "check no longer exposed" {
+		t.Fatalf("staleExceptions = %#v", report.StaleExceptions)
+	}
+
+	if code, out, _ := run("allowlist", "prune", "--dry-run"); code != 0 || !strings.Contains(out, "would remove deploy/renamed.service (unit not found)") {
+		t.Fatalf("dry run exit code = %d\n%s", code, out)
+	}
+	if code, out, _ := run("allowlist", "prune"); code != 0 || !strings.Contains(out, "wrote allow.json") {
+		t.Fatalf("prune exit code = %d\n%s", code, out)
+	}
+	b, err := os.ReadFile(filepath.Join(repo, "allow.json"))
+	if err != nil {
+		t.Fatalf("read allowlist: %v", err)
+	}
+	if strings.Contains(string(b), "renamed") || strings.Contains(string(b), "@web") || !strings.Contains(string(b), `"test": "ProtectSystem"`) {
+		t.Fatalf("pruned allowlist:\n%s", b)
+	}
+
+	if code, _, stderr := run("scan", "--fail-on-stale-allowlist"); code != 1 || strings.Contains(stderr, "stale allowlist entries") {
+		t.Fatalf("exit code = %d, want no stale entries after prune\n%s", code, stderr)
+	}
+	mustWrite(t, filepath.Join(repo, "allow.json"), `{"allowUnits": ["myapp.service", "gone.service"]}`)
+	if code, _, stderr := run("scan", "--fail-on-stale-allowlist"); code != 1 || !strings.Contains(stderr, "error: 1 stale allowlist entries") {
+		t.Fatalf("exit code = %d, want failure on the stale entry\n%s", code, stderr)
+	}
+}
+
+func TestStaleAllowlistEntriesWithBaseline(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+	mustWrite(t, filepath.Join(repo, "deploy/hardened.service"), "[Service]\nExecStart=/usr/bin/myapp\nDynamicUser=yes\nPrivateNetwork=yes\nProtectSystem=strict\nProtectHome=yes\nPrivateDevices=yes\nCapabilityBoundingSet=\n")
+	mustWrite(t, filepath.Join(repo, "allow.json"), `{
+  "allowUnits": ["deploy/hardened.service"],
+  "allowTests": [{ "unit": "myapp.service", "test": "PrivateNetwork" }]
+}`)
+	run := func(args ...string) (int, string, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := Run(append(append([]string{"ssg"}, args...),
+			"--repo-root", repo,
+			"--paths", "deploy/*.service",
+			"--backend", "native",
+			"--allowlist", "allow.json",
+		), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+	if code, out, stderr := run("scan", "--threshold", "9", "--mode", "report", "--json-report", filepath.Join(repo, "baseline.json")); code != 0 {
+		t.Fatalf("baseline run exit code = %d\n%s\n%s", code, out, stderr)
+	}
+
+	// myapp.service is still over the threshold without regressing: its
+	// entry is still needed, while hardened.service's is not.
+	code, _, stderr := run("scan", "--threshold", "9", "--baseline", "baseline.json", "--fail-on-stale-allowlist")
+	if code != 1 || !strings.Contains(stderr, "error: 1 stale allowlist entries") ||
+		!strings.Contains(stderr, "stale allowlist entry (unit no longer over threshold): deploy/hardened.service") || strings.Contains(stderr, "myapp.service / PrivateNetwork") {
+		t.Fatalf("exit code = %d, want only hardened.service stale\n%s", code, stderr)
+	}
+
+	// Without a threshold, baseline-gated units keep their entries.
+	if code, _, stderr := run("scan", "--baseline", "baseline.json", "--fail-on-stale-allowlist"); code != 0 || strings.Contains(stderr, "stale") {
+		t.Fatalf("exit code = %d, want no stale entries\n%s", code, stderr)
+	}
+
+	if code, out, stderr := run("allowlist", "prune", "--threshold", "9", "--baseline", "baseline.json"); code != 0 || !strings.Contains(out, "removed deploy/hardened.service") {
+		t.Fatalf("prune exit code = %d\n%s\n%s", code, out, stderr)
+	}
+	b, err := os.ReadFile(filepath.Join(repo, "allow.json"))
+	if err != nil {
+		t.Fatalf("read allowlist: %v", err)
+	}
+	if strings.Contains(string(b), "hardened") || !strings.Contains(string(b), `"test": "PrivateNetwork"`) {
+		t.Fatalf("pruned allowlist:\n%s", b)
+	}
+}
+
+func TestScanInlineSuppressions(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\n# ssg:allow * reason=\"legacy, see OPS-1\" expires=2999-12-31\nExecStart=/usr/bin/myapp\n")
+	scan := func() (int, model.ScanReport, string, string) {
+		t.Helper()
+		jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+		var stdout, stderr bytes.Buffer
+		code := Run([]string{
+			"ssg", "scan",
+			"--repo-root", repo,
+			"--paths", "deploy/*.service",
+			"--threshold", "5",
+			"--backend", "native",
+			"--json-report", jsonReport,
+		}, &stdout, &stderr)
+		var report model.ScanReport
+		if code != 2 {
+			mustReadJSON(t, jsonReport, &report)
+		}
+		return code, report, stdout.String(), stderr.String()
+	}
+
+	code, report, out, stderr := scan()
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0\nstdout:\n%s\nstderr:\n%s", code, out, stderr)
+	}
+	u := report.Units[0]
+	if !u.Allowed || len(u.Exceptions) != 1 || u.Exceptions[0].Location == nil || *u.Exceptions[0].Location != (model.Location{Path: "deploy/myapp.service", Line: 2}) {
+		t.Fatalf("unit = %#v, want allowed by the comment", u)
+	}
+	if !strings.Contains(out, "- Exception: deploy/myapp.service (expires: 2999-12-31, reason: legacy, see OPS-1, source: deploy/myapp.service:2)") {
+		t.Fatalf("expected the exception with its source in the summary:\n%s", out)
+	}
+
+	// Per-check suppressions in drop-ins merge with the unit's; an invalid
+	// one fails the unit.
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service.d/10-allow.conf"), "# ssg:allow PrivateNetwork\n")
+	if code, report, _, _ := scan(); code != 1 || report.Units[0].Allowed {
+		t.Fatalf("exit code = %d, want 1 with only PrivateNetwork allowed", code)
+	}
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service.d/10-allow.conf"), "# ssg:allow PrivateNetwork until=tomorrow\n")
+	if code, report, _, _ := scan(); code != 1 || !strings.Contains(report.Units[0].Error, `deploy/myapp.service.d/10-allow.conf:1: ssg:allow: unknown field "until"`) {
+		t.Fatalf("exit code = %d, units = %#v", code, report.Units)
+	}
+
+	// Inline entries warn before they expire, like the allowlist file's.
+	soon := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service.d/10-allow.conf"), "# ssg:allow * expires="+soon+"\n")
+	code, report, _, stderr = scan()
+	want := "allowlist entry expires soon: deploy/myapp.service (expires: " + soon + ", source: deploy/myapp.service.d/10-allow.conf:1)"
+	if code != 0 || !strings.Contains(stderr, "warning: "+want) || len(report.Warnings) != 1 || report.Warnings[0] != want {
+		t.Fatalf("exit code = %d, warnings = %q\nstderr:\n%s", code, report.Warnings, stderr)
+	}
+}
+
+func TestScanInlineSuppressionsMatchPathLiterally(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/app[1].service"), "[Service]\n# ssg:allow *\nExecStart=/usr/bin/myapp\n")
+	mustWrite(t, filepath.Join(repo, "deploy/app1.service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"ssg", "scan", "--repo-root", repo, "--paths", "deploy/*.service", "--threshold", "5", "--backend", "native", "--json-report", jsonReport}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1 for app1.service\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	allowed := map[string]bool{}
+	for _, u := range report.Units {
+		allowed[u.UnitName] = u.Allowed
+	}
+	if !allowed["app[1].service"] || allowed["app1.service"] {
+		t.Fatalf("allowed = %v, want only app[1].service", allowed)
+	}
+}
+
+func TestScanWarnsOnUnparsableUnitFile(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\n# ssg:allow *\nExecStart=/usr/bin/myapp\nnot a setting\n")
+	stub := writeSystemdAnalyzeStub(t, repo, stubOptions{exposure: 7.2, rating: "EXPOSED"})
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"ssg", "scan", "--repo-root", repo, "--paths", "deploy/*.service", "--threshold", "6", "--systemd-analyze", stub}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1: the suppression can't be read\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "warning: deploy/myapp.service: ignoring ssg:allow comments and finding locations:") {
+		t.Fatalf("expected a parse warning, got:\n%s", stderr.String())
+	}
+}
+
+func TestScanAllowlistBudget(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/legacy.service"), "[Service]\nExecStart=/usr/bin/legacy\n")
+	scan := func(budget string) (int, string) {
+		t.Helper()
+		mustWrite(t, filepath.Join(repo, "allow.json"), `{"allowUnits": [{"unit": "legacy.service", "maxExposure": `+budget+`}]}`)
+		var stdout, stderr bytes.Buffer
+		code := Run([]string{
+			"ssg", "scan",
+			"--repo-root", repo,
+			"--paths", "deploy/*.service",
+			"--threshold", "5",
+			"--backend", "native",
+			"--allowlist", "allow.json",
+		}, &stdout, &stderr)
+		return code, stdout.String()
+	}
+
+	if code, out := scan("9.6"); code != 0 || !strings.Contains(out, "⚠️ allowed") {
+		t.Fatalf("exit code = %d, want allowed within the budget\n%s", code, out)
+	}
+	code, out := scan("9.5")
+	if code != 1 || !strings.Contains(out, "❌ over budget") || !strings.Contains(out, "- Exceeded exception budget: legacy.service (max exposure: 9.50)") {
+		t.Fatalf("exit code = %d, want over budget\n%s", code, out)
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/nativeanalyze"
+	"github.com/teunlao/systemd-security-gate/internal/systemdanalyze"
+)
+
+const (
+	backendSystemdAnalyze = "systemd-analyze"
+	backendNative         = "native"
+)
+
+// unitAnalysis is what a backend reports for one unit in the offline root.
+type unitAnalysis struct {
+	OverallExposure   float64
+	OverallRating     string
+	ThresholdExceeded bool
+	Checks            []model.SecurityCheck
+}
+
+// analyzeFunc scores unitName against threshold, with policyAbs ("" for the
+// built-in weights) as the security policy.
+type analyzeFunc func(root string, unitName string, policyAbs string, threshold float64) (unitAnalysis, error)
+
+// newAnalyzer returns the analysis function for backend along with a version
+// string for the report. verifyOverall makes the systemd-analyze backend
+// cross-check the overall exposure it derives from the JSON table against
+// the text report.
+func newAnalyzer(backend string, systemdAnalyze string, verifyOverall bool) (analyzeFunc, string, error) {
+	switch backend {
+	case backendNative:
+		return func(root string, unitName string, policyAbs string, threshold float64) (unitAnalysis, error) {
+			res, err := nativeanalyze.Security(nativeanalyze.Args{
+				Root:       root,
+				UnitName:   unitName,
+				PolicyPath: policyAbs,
+				Threshold:  threshold,
+			})
+			if err != nil {
+				return unitAnalysis{}, err
+			}
+			return unitAnalysis(res), nil
+		}, nativeanalyze.TargetVersion, nil
+
+	case backendSystemdAnalyze:
+		version, _ := systemdanalyze.GetVersion(systemdAnalyze)
+		return func(root string, unitName string, policyAbs string, threshold float64) (unitAnalysis, error) {
+			res, err := systemdanalyze.Security(systemdAnalyze, systemdanalyze.SecurityArgs{
+				Root:       root,
+				UnitName:   unitName,
+				PolicyPath: policyAbs,
+				Threshold:  threshold,
+				Version:    version,
+				Verify:     verifyOverall,
+			})
+			if err != nil {
+				return unitAnalysis{}, err
+			}
+			return unitAnalysis{
+				OverallExposure:   res.OverallExposure,
+				OverallRating:     res.OverallRating,
+				ThresholdExceeded: res.ThresholdExceeded,
+				Checks:            res.Checks,
+			}, nil
+		}, version, nil
+
+	default:
+		return nil, "", fmt.Errorf("unknown backend %q (want %s or %s)", backend, backendNative, backendSystemdAnalyze)
+	}
+}
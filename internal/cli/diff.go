@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/teunlao/systemd-security-gate/internal/baseline"
+	"github.com/teunlao/systemd-security-gate/internal/gitrev"
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/report"
+)
+
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	f := addScanFlags(fs)
+	var (
+		baseRef = fs.String("base", "", "Git ref to compare against; units are scanned at its merge base with HEAD (required)")
+
+		jsonReportPath = fs.String("json-report", "", "Write JSON diff report to file (optional)")
+		summaryPath    = fs.String("summary-file", "", "Write Markdown summary to file (optional; defaults to $GITHUB_STEP_SUMMARY if set)")
+	)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *baseRef == "" {
+		fmt.Fprintln(stderr, "error: --base is required")
+		return 2
+	}
+
+	// The threshold only gates units added since the base.
+	s, code := f.newScanner(stderr, false)
+	if s == nil {
+		return code
+	}
+
+	commit, err := gitrev.MergeBase(s.repoAbs, *baseRef)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 2
+	}
+	tree, err := gitrev.Materialize(s.repoAbs, commit)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(tree)
+
+	_, baseUnits, err := s.scanTree(tree, f.paths, f.exclude, nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: scan %s: %v\n", *baseRef, err)
+		return 1
+	}
+	base := baseline.New(model.ScanReport{Units: baseUnits})
+
+	matches, headUnits, err := s.scanTree(s.repoAbs, f.paths, f.exclude, &base)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(stderr, "error: no unit files matched --paths")
+		return 1
+	}
+
+	scan := s.newReport(f, matches)
+	scan.Base = *baseRef
+	scan.BaseCommit = commit
+	head := map[string]bool{}
+	for _, u := range headUnits {
+		head[u.UnitName] = true
+		if u.Error != "" || u.Baseline.Changed() {
+			scan.Units = append(scan.Units, u)
+		}
+	}
+	for _, u := range baseUnits {
+		if !head[u.UnitName] {
+			scan.DeletedUnits = append(scan.DeletedUnits, u)
+		}
+	}
+	scan.Templates = model.GroupTemplates(scan.Units)
+	addUnitWarnings(&scan, headUnits, stderr)
+
+	md := report.MarkdownSummary(scan)
+	fmt.Fprintln(stdout, md)
+	writeSummaryFile(*summaryPath, md, stderr)
+
+	if *jsonReportPath != "" {
+		if err := writeJSON(*jsonReportPath, scan); err != nil {
+			fmt.Fprintf(stderr, "error: write JSON report: %v\n", err)
+			return 1
+		}
+	}
+
+	return exitCode(scan.Units)
+}
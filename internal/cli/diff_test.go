@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+func TestDiffReportsChangedAddedAndDeletedUnits(t *testing.T) {
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "-q", "-b", "main")
+	mustWrite(t, filepath.Join(repo, "deploy/a.service"), "[Service]\nExecStart=/bin/true\nPrivateNetwork=yes\n")
+	mustWrite(t, filepath.Join(repo, "deploy/b.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/c.service"), "[Service]\nExecStart=/bin/true\n")
+	gitRun(t, repo, "add", "-A")
+	gitRun(t, repo, "commit", "-q", "-m", "base")
+
+	gitRun(t, repo, "checkout", "-q", "-b", "feature")
+	mustWrite(t, filepath.Join(repo, "deploy/a.service.d/net.conf"), "[Service]\nPrivateNetwork=no\n")
+	mustWrite(t, filepath.Join(repo, "deploy/d.service"), "[Service]\nExecStart=/bin/true\n")
+	if err := os.Remove(filepath.Join(repo, "deploy/c.service")); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", "-A")
+	gitRun(t, repo, "commit", "-q", "-m", "feature")
+
+	jsonReport := filepath.Join(t.TempDir(), "diff.json")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "diff",
+		"--base", "main",
+		"--repo-root", repo,
+		"--paths", "deploy/*.service",
+		"--backend", "native",
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	for _, want := range []string{"- Base: `main`", "| `a.service` | `deploy/a.service` | ❌ regressed |", "| `d.service` | `deploy/d.service` | ✅ pass |", "| new |", "### Deleted units\n\n- `c.service` (`deploy/c.service`), was 9.60 UNSAFE"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("expected %q in summary, got:\n%s", want, stdout.String())
+		}
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if len(report.Units) != 2 || report.Units[0].UnitName != "a.service" || report.Units[1].UnitName != "d.service" {
+		t.Fatalf("units = %#v, want a.service and d.service only", report.Units)
+	}
+	if len(report.BaseCommit) != 40 || len(report.DeletedUnits) != 1 || report.DeletedUnits[0].UnitName != "c.service" {
+		t.Fatalf("report = %#v, want base commit and c.service deleted", report)
+	}
+
+	// Added units are still held to an explicit threshold.
+	stdout.Reset()
+	stderr.Reset()
+	gitRun(t, repo, "checkout", "-q", "main")
+	gitRun(t, repo, "checkout", "-q", "-b", "only-add")
+	mustWrite(t, filepath.Join(repo, "deploy/d.service"), "[Service]\nExecStart=/bin/true\n")
+	code = Run([]string{"ssg", "diff", "--base", "main", "--repo-root", repo, "--paths", "deploy/*.service", "--backend", "native", "--threshold", "5"}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stdout.String(), "| `d.service` | `deploy/d.service` | ❌ fail |") || strings.Contains(stdout.String(), "b.service") {
+		t.Fatalf("exit code = %d\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestDiffRequiresBase(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"ssg", "diff", "--paths", "deploy/*.service"}, &stdout, &stderr)
+	if code != 2 || !strings.Contains(stderr.String(), "--base is required") {
+		t.Fatalf("exit code = %d, stderr = %q", code, stderr.String())
+	}
+}
+
+func TestDiffReportsAddedTemplateInstances(t *testing.T) {
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "-q", "-b", "main")
+	mustWrite(t, filepath.Join(repo, "deploy/a.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), "instances:\n  w@.service: [x]\n")
+	gitRun(t, repo, "add", "-A")
+	gitRun(t, repo, "commit", "-q", "-m", "base")
+
+	gitRun(t, repo, "checkout", "-q", "-b", "feature")
+	mustWrite(t, filepath.Join(repo, "deploy/w@.service"), "[Service]\nExecStart=/bin/true\n")
+	gitRun(t, repo, "add", "-A")
+	gitRun(t, repo, "commit", "-q", "-m", "feature")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"ssg", "diff", "--base", "main", "--repo-root", repo, "--paths", "deploy/*.service", "--backend", "native"}, &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), "| `w@x.service` | `deploy/w@.service` | ✅ pass |") || strings.Contains(stdout.String(), "`a.service`") {
+		t.Fatalf("exit code = %d\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestDiffFailsWhenNothingMatches(t *testing.T) {
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "-q", "-b", "main")
+	mustWrite(t, filepath.Join(repo, "README"), "hello\n")
+	gitRun(t, repo, "add", "-A")
+	gitRun(t, repo, "commit", "-q", "-m", "base")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"ssg", "diff", "--base", "main", "--repo-root", repo, "--paths", "deploy/*.service", "--backend", "native"}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "no unit files matched --paths") {
+		t.Fatalf("exit code = %d, stderr = %q", code, stderr.String())
+	}
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/teunlao/systemd-security-gate/internal/checkdoc"
+)
+
+func runExplain(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: ssg explain [CHECK]\n\nCHECK is a JSON field (PrivateNetwork, SystemCallFilter_clock), a check name\n(SystemCallFilter=~@clock) or a directive (RestrictAddressFamilies).\nWithout CHECK, lists all checks.")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	switch fs.NArg() {
+	case 0:
+		listChecks(stdout, checkdoc.All())
+		return 0
+	case 1:
+	default:
+		fmt.Fprintln(stderr, "error: explain takes one check")
+		return 2
+	}
+
+	docs := checkdoc.Find(fs.Arg(0))
+	switch len(docs) {
+	case 0:
+		fmt.Fprintf(stderr, "error: unknown check: %s (run \"ssg explain\" for the list)\n", fs.Arg(0))
+		return 2
+	case 1:
+		fmt.Fprint(stdout, docs[0].Explain())
+	default:
+		fmt.Fprintf(stdout, "%d checks match %s:\n\n", len(docs), fs.Arg(0))
+		listChecks(stdout, docs)
+		fmt.Fprintln(stdout, "\nRun \"ssg explain JSON_FIELD\" for details.")
+	}
+	return 0
+}
+
+func listChecks(w io.Writer, docs []checkdoc.Doc) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "JSON FIELD\tNAME\tWEIGHT")
+	for _, d := range docs {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", d.JSONField, d.Name, d.Weight)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	explain := func(args ...string) (int, string, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := Run(append([]string{"ssg", "explain"}, args...), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, out, _ := explain("SystemCallFilter=~@clock")
+	for _, want := range []string{
+		"SystemCallFilter=~@clock (SystemCallFilter_clock)",
+		"Weight:\n  1000 (range 10)",
+		"Recommended:\n  `SystemCallFilter=~@clock`",
+		"Common breakages:\n  Time synchronization daemons.",
+		"Related checks:\n  SystemCallArchitectures",
+		"systemd.exec.html",
+	} {
+		if code != 0 || !strings.Contains(out, want) {
+			t.Fatalf("exit code = %d, expected %q in:\n%s", code, want, out)
+		}
+	}
+
+	if code, out, _ := explain("restrictaddressfamilies"); code != 0 || !strings.Contains(out, "5 checks match") || !strings.Contains(out, "RestrictAddressFamilies_AF_PACKET") {
+		t.Fatalf("exit code = %d, expected the directive's checks:\n%s", code, out)
+	}
+	if code, out, _ := explain("User"); code != 0 || !strings.HasPrefix(out, "User=/DynamicUser= (UserOrDynamicUser)") {
+		t.Fatalf("exit code = %d, expected the User= check:\n%s", code, out)
+	}
+	if code, out, _ := explain(); code != 0 || !strings.Contains(out, "UserOrDynamicUser") || !strings.Contains(out, "AmbientCapabilities") {
+		t.Fatalf("exit code = %d, expected the list of checks:\n%s", code, out)
+	}
+	if code, _, stderr := explain("PrivateCoffee"); code != 2 || !strings.Contains(stderr, "unknown check: PrivateCoffee") {
+		t.Fatalf("exit code = %d, stderr = %q", code, stderr)
+	}
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/hardening"
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/offlineroot"
+)
+
+// dropInFix is the hardening drop-in of one unit file, shared by the
+// instances of a template.
+type dropInFix struct {
+	repoRel  string
+	issues   []model.SecurityCheck
+	existing string
+	content  string
+}
+
+func runFix(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fix", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	f := addScanFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Print the drop-in changes as a diff instead of writing them")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	s, code := f.newScanner(stderr, true)
+	if s == nil {
+		return code
+	}
+
+	matches, root, units, err := s.buildTree(s.repoAbs, f.paths, f.exclude)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(stderr, "error: no unit files matched --paths")
+		return 1
+	}
+	defer os.RemoveAll(root)
+
+	before := s.analyzeUnits(s.repoAbs, root, units, nil)
+	exit := 0
+
+	var fixes []*dropInFix
+	byPath := map[string]*dropInFix{}
+	failing := false
+	for _, u := range before {
+		if u.Error != "" {
+			fmt.Fprintf(stderr, "error: %s: %s\n", u.UnitName, u.Error)
+			exit = 1
+			continue
+		}
+		if !u.Failing() {
+			continue
+		}
+		failing = true
+		var issues []model.SecurityCheck
+		if u.Flagged() && !u.Allowed {
+			issues = hardening.Needed(u.Checks, u.OverallExposure, u.Threshold)
+		}
+		// Required checks fail the unit whatever its exposure.
+		for _, c := range u.RequiredIssues() {
+			if hardening.Fixable(c) {
+				issues = append(issues, c)
+			}
+		}
+		if len(hardening.Directives(issues)) == 0 {
+			fmt.Fprintf(stdout, "%s: no fixable checks\n", u.UnitName)
+			continue
+		}
+		fix, ok := byPath[u.RepoRelPath]
+		if !ok {
+			fix = &dropInFix{repoRel: path.Join(u.RepoRelPath+".d", hardening.DropInName)}
+			byPath[u.RepoRelPath] = fix
+			fixes = append(fixes, fix)
+		}
+		fix.issues = append(fix.issues, issues...)
+	}
+	if !failing {
+		fmt.Fprintln(stdout, "No unit fails the gate; nothing to fix.")
+		return exit
+	}
+	if len(fixes) == 0 {
+		return exit
+	}
+
+	// Install the drop-ins in the offline root to confirm the new scores.
+	for unitRel, fix := range byPath {
+		existing, err := os.ReadFile(filepath.Join(s.repoAbs, fix.repoRel))
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(stderr, "error: read %s: %v\n", fix.repoRel, err)
+			return 1
+		}
+		fix.existing = string(existing)
+		fix.content, err = hardening.Render(fix.existing, hardening.Directives(fix.issues))
+		if err != nil {
+			fmt.Fprintf(stderr, "error: parse %s: %v\n", fix.repoRel, err)
+			return 1
+		}
+		dir := filepath.Join(root, filepath.FromSlash(offlineroot.UnitDir), path.Base(unitRel)+".d")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(filepath.Join(dir, hardening.DropInName), []byte(fix.content), 0o644); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+
+	var fixedUnits []model.UnitFile
+	var fixedBefore []model.UnitReport
+	for i, u := range units {
+		if byPath[u.RepoRelPath] != nil {
+			fixedUnits = append(fixedUnits, u)
+			fixedBefore = append(fixedBefore, before[i])
+		}
+	}
+	after := s.analyzeUnits(s.repoAbs, root, fixedUnits, nil)
+
+	for _, fix := range fixes {
+		if fix.content == fix.existing {
+			fmt.Fprintf(stdout, "%s: no directives to add\n", fix.repoRel)
+			continue
+		}
+		if *dryRun {
+			fmt.Fprint(stdout, hardening.Diff(fix.repoRel, fix.existing, fix.content))
+			continue
+		}
+		dst := filepath.Join(s.repoAbs, filepath.FromSlash(fix.repoRel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(dst, []byte(fix.content), 0o644); err != nil {
+			fmt.Fprintf(stderr, "error: write %s: %v\n", fix.repoRel, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", fix.repoRel)
+	}
+
+	fmt.Fprintln(stdout)
+	for i, u := range after {
+		if u.Error != "" {
+			fmt.Fprintf(stdout, "%s: %.2f -> error: %s\n", u.UnitName, fixedBefore[i].OverallExposure, u.Error)
+			exit = 1
+			continue
+		}
+		status := "passes"
+		if u.ThresholdExceeded {
+			status = "still exceeds"
+		}
+		gate := fmt.Sprintf("threshold %.2f", u.Threshold)
+		if u.MaxRating != "" {
+			gate = "max rating " + u.MaxRating
+		}
+		line := fmt.Sprintf("%s: %.2f %s -> %.2f %s (%s %s)",
+			u.UnitName, fixedBefore[i].OverallExposure, fixedBefore[i].OverallRating,
+			u.OverallExposure, u.OverallRating, status, gate)
+		if len(u.RequiredChecksFailed) > 0 {
+			line += "; still exposes required checks: " + strings.Join(u.RequiredChecksFailed, ", ")
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	return exit
+}
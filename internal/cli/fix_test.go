@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixWritesHardeningDropIn(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+	dropIn := filepath.Join(repo, "deploy/myapp.service.d/50-ssg-hardening.conf")
+
+	fix := func(extra ...string) (int, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		args := append([]string{
+			"ssg", "fix",
+			"--repo-root", repo,
+			"--paths", "deploy/myapp.service",
+			"--threshold", "5",
+			"--backend", "native",
+		}, extra...)
+		code := Run(args, &stdout, &stderr)
+		if stderr.Len() > 0 {
+			t.Logf("stderr:\n%s", stderr.String())
+		}
+		return code, stdout.String()
+	}
+
+	code, out := fix("--dry-run")
+	if code != 0 || !strings.Contains(out, "+++ b/deploy/myapp.service.d/50-ssg-hardening.conf") || !strings.Contains(out, "+PrivateNetwork=yes") {
+		t.Fatalf("dry run exit code = %d\n%s", code, out)
+	}
+	if !strings.Contains(out, "myapp.service: 9.60 UNSAFE -> ") || !strings.Contains(out, "(passes threshold 5.00)") {
+		t.Fatalf("dry run did not confirm the new score:\n%s", out)
+	}
+	if _, err := os.Stat(dropIn); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote %s", dropIn)
+	}
+
+	if code, out := fix(); code != 0 || !strings.Contains(out, "wrote deploy/myapp.service.d/50-ssg-hardening.conf") {
+		t.Fatalf("fix exit code = %d\n%s", code, out)
+	}
+	b, err := os.ReadFile(dropIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "[Service]\nPrivateNetwork=yes\n") {
+		t.Fatalf("unexpected drop-in:\n%s", b)
+	}
+
+	// The unit now passes, so a scan gates green and fix has nothing to do.
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"ssg", "scan", "--repo-root", repo, "--paths", "deploy/myapp.service", "--threshold", "5", "--backend", "native"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("scan after fix exit code = %d\n%s%s", code, stdout.String(), stderr.String())
+	}
+	if code, out := fix(); code != 0 || !strings.Contains(out, "nothing to fix") {
+		t.Fatalf("second fix exit code = %d\n%s", code, out)
+	}
+}
+
+func TestFixRequiredChecks(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+	dropIn := filepath.Join(repo, "deploy/myapp.service.d/50-ssg-hardening.conf")
+
+	fix := func(required string) (int, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := Run([]string{
+			"ssg", "fix",
+			"--repo-root", repo,
+			"--paths", "deploy/myapp.service",
+			"--threshold", "10",
+			"--required-checks", required,
+			"--backend", "native",
+		}, &stdout, &stderr)
+		if stderr.Len() > 0 {
+			t.Logf("stderr:\n%s", stderr.String())
+		}
+		return code, stdout.String()
+	}
+
+	// RootDirectory=/RootImage= has no mechanical fix: no comment-only drop-in.
+	if code, out := fix("RootDirectoryOrRootImage"); code != 0 || !strings.Contains(out, "myapp.service: no fixable checks") || strings.Contains(out, "wrote") {
+		t.Fatalf("fix exit code = %d\n%s", code, out)
+	}
+	if _, err := os.Stat(dropIn); !os.IsNotExist(err) {
+		t.Fatalf("fix wrote %s without directives", dropIn)
+	}
+
+	// A unit within its threshold still gets its required checks fixed.
+	if code, out := fix("PrivateNetwork"); code != 0 || !strings.Contains(out, "wrote deploy/myapp.service.d/50-ssg-hardening.conf") || strings.Contains(out, "still exposes") {
+		t.Fatalf("fix exit code = %d\n%s", code, out)
+	}
+	b, err := os.ReadFile(dropIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(b), "[Service]\nPrivateNetwork=yes\n") {
+		t.Fatalf("unexpected drop-in:\n%s", b)
+	}
+}
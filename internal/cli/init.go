@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/teunlao/systemd-security-gate/internal/allowlist"
+	"github.com/teunlao/systemd-security-gate/internal/config"
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/nativeanalyze"
+)
+
+const (
+	defaultInitPaths    = "**/*"
+	defaultPolicyOut    = ".ci/systemd-security-policy.json"
+	defaultAllowlistOut = ".ci/ssg-allowlist.json"
+	baselineReason      = "baseline"
+	defaultBaselineDays = 90
+)
+
+// policyWeights is one entry of a systemd-analyze security policy.
+type policyWeights struct {
+	Weight uint64 `json:"weight"`
+	Range  uint64 `json:"range"`
+}
+
+func runInit(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	f := addScanFlags(fs)
+	configOut := fs.String("config-out", config.DefaultPath, "Where to write the repo config")
+	policyOut := fs.String("policy-out", defaultPolicyOut, "Where to write the security policy (not written with --policy)")
+	allowlistOut := fs.String("allowlist-out", defaultAllowlistOut, "Where to write the allowlist of current issues")
+	expireDays := fs.Int("expire-days", defaultBaselineDays, "Days until the allowlist entries expire")
+	owner := fs.String("owner", "", "Owner recorded on the allowlist entries (optional)")
+	force := fs.Bool("force", false, "Overwrite existing files")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if *expireDays <= 0 {
+		fmt.Fprintln(stderr, "error: --expire-days must be positive")
+		return 2
+	}
+	if *f.allowlistPath != "" {
+		fmt.Fprintln(stderr, "error: --allowlist is not supported by init; it writes --allowlist-out")
+		return 2
+	}
+
+	outputs := []string{*configOut, *allowlistOut}
+	writePolicy := *f.policyPath == ""
+	if writePolicy {
+		outputs = append(outputs, *policyOut)
+	}
+	repoAbs, err := filepath.Abs(*f.repoRoot)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: resolve --repo-root: %v\n", err)
+		return 1
+	}
+	if !*force {
+		for _, out := range outputs {
+			if _, err := os.Stat(filepath.Join(repoAbs, out)); err == nil {
+				fmt.Fprintf(stderr, "error: %s already exists (use --force to overwrite)\n", out)
+				return 2
+			}
+		}
+	}
+
+	// Look at every unit file unless told where they are.
+	if len(f.paths) == 0 {
+		f.paths = stringSliceFlag{defaultInitPaths}
+	}
+	s, code := f.newScanner(stderr, true)
+	if s == nil {
+		return code
+	}
+
+	matches, root, units, err := s.buildTree(s.repoAbs, f.paths, f.exclude)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(stderr, "error: no unit files matched --paths")
+		return 1
+	}
+	defer os.RemoveAll(root)
+
+	results := s.analyzeUnits(s.repoAbs, root, units, nil)
+	expires := s.now.UTC().AddDate(0, 0, *expireDays).Format("2006-01-02")
+	allow, failing, err := baselineAllowlist(results, allowlist.Meta{Reason: baselineReason, Owner: *owner, Expires: expires})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+
+	policy := *f.policyPath
+	if writePolicy {
+		policy = *policyOut
+		b, err := defaultPolicy()
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		if err := writeRepoFile(s.repoAbs, policy, b); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "wrote %s\n", policy)
+	}
+
+	b, err := json.MarshalIndent(allow, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := writeRepoFile(s.repoAbs, *allowlistOut, append(b, '\n')); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "wrote %s (%d entries for %d units over the threshold, expiring %s)\n", *allowlistOut, len(allow.AllowTests), failing, expires)
+
+	// With --force, an existing config keeps its rules and other settings.
+	existing, err := os.ReadFile(filepath.Join(s.repoAbs, *configOut))
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(stderr, "error: read %s: %v\n", *configOut, err)
+		return 1
+	}
+	cfg, err := initConfig(existing, f.paths, *allowlistOut, policy, s.defaults)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %s: %v\n", *configOut, err)
+		return 1
+	}
+	if err := writeRepoFile(s.repoAbs, *configOut, cfg); err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "wrote %s\n", *configOut)
+	return 0
+}
+
+// baselineAllowlist allows every current issue of the units over their
+// threshold, so that the gate passes until meta expires. It fails on units
+// that could not be analyzed, whose issues are unknown, and on units exposing
+// required checks, which no allowlist entry covers.
+func baselineAllowlist(results []model.UnitReport, meta allowlist.Meta) (allowlist.Allowlist, int, error) {
+	var a allowlist.Allowlist
+	seen := map[string]bool{}
+	failing := 0
+	for _, u := range results {
+		if u.Error != "" {
+			return allowlist.Allowlist{}, 0, fmt.Errorf("%s: %s", u.UnitName, u.Error)
+		}
+		if len(u.RequiredChecksFailed) > 0 {
+			return allowlist.Allowlist{}, 0, fmt.Errorf("%s exposes required checks the allowlist can't cover: %s (fix them, or exempt the unit with a requiredChecks rule in the config)",
+				u.UnitName, strings.Join(u.RequiredChecksFailed, ", "))
+		}
+		if !u.ThresholdExceeded {
+			continue
+		}
+		failing++
+		for _, c := range model.Issues(u.Checks) {
+			test := model.CheckID(c)
+			key := u.RepoRelPath + "\x00" + test
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			a.AllowTests = append(a.AllowTests, allowlist.TestEntry{Unit: allowlist.LiteralUnitKey(u.RepoRelPath), Test: test, Meta: meta})
+		}
+	}
+	return a, failing, nil
+}
+
+// defaultPolicy renders the built-in weights and ranges as a policy file to
+// tune from.
+func defaultPolicy() ([]byte, error) {
+	catalog, err := nativeanalyze.Catalog("")
+	if err != nil {
+		return nil, err
+	}
+	policy := make(map[string]policyWeights, len(catalog))
+	for _, c := range catalog {
+		policy[c.JSONField] = policyWeights{Weight: c.Weight, Range: c.Range}
+	}
+	b, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// initConfig returns the config with the paths, allowlist, gate and policy
+// of the scan, in enforce mode. Settings of an existing config that init
+// doesn't write, such as its unit rules, are kept.
+func initConfig(existing []byte, paths []string, allowlistPath, policy string, gate config.Settings) ([]byte, error) {
+	pathsNode := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, p := range paths {
+		pathsNode.Content = append(pathsNode.Content, quotedNode(p))
+	}
+	gateKey, otherGateKey := "threshold", "maxRating"
+	gateNode := &yaml.Node{Kind: yaml.ScalarNode, Value: strconv.FormatFloat(gate.Threshold, 'f', -1, 64)}
+	if gate.MaxRating != "" {
+		gateKey, otherGateKey = otherGateKey, gateKey
+		gateNode = quotedNode(gate.MaxRating)
+	}
+	settings := []struct {
+		key   string
+		value *yaml.Node
+	}{
+		{"paths", pathsNode},
+		{"allowlist", quotedNode(allowlistPath)},
+		{gateKey, gateNode},
+		{"policy", quotedNode(policy)},
+		{"mode", &yaml.Node{Kind: yaml.ScalarNode, Value: "enforce"}},
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(existing, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+		doc.HeadComment = "Written by ssg init. The allowlist holds the issues present at init;\nfix them and remove their entries before they expire."
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("not a mapping")
+	}
+	for _, kv := range settings {
+		found := false
+		for i := 0; i < len(root.Content); i += 2 {
+			key := root.Content[i]
+			if key.Value == kv.key || (kv.key == gateKey && key.Value == otherGateKey) {
+				// Switching gates keeps the key's place and comments.
+				key.Value = kv.key
+				root.Content[i+1] = kv.value
+				found = true
+				break
+			}
+		}
+		if !found {
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: kv.key}, kv.value)
+		}
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func quotedNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: s, Style: yaml.DoubleQuotedStyle}
+}
+
+func writeRepoFile(repoAbs, rel string, content []byte) error {
+	dst := filepath.Join(repoAbs, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, content, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", rel, err)
+	}
+	return nil
+}
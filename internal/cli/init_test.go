@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/teunlao/systemd-security-gate/internal/allowlist"
+)
+
+func TestInitAllowsCurrentIssues(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+	mustWrite(t, filepath.Join(repo, "deploy/hardened.service"), "[Service]\nExecStart=/usr/bin/hardened\nDynamicUser=yes\nPrivateNetwork=yes\nProtectSystem=strict\nProtectHome=yes\nPrivateDevices=yes\nCapabilityBoundingSet=\nSystemCallFilter=@system-service\nRestrictAddressFamilies=AF_UNIX\n")
+
+	run := func(args ...string) (int, string, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		code := Run(append([]string{"ssg"}, args...), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, out, stderr := run("init", "--repo-root", repo, "--threshold", "5", "--backend", "native", "--owner", "@infra")
+	if code != 0 {
+		t.Fatalf("init exit code = %d\nstdout:\n%s\nstderr:\n%s", code, out, stderr)
+	}
+	if !strings.Contains(out, "wrote .ci/ssg-allowlist.json") || !strings.Contains(out, "for 1 units over the threshold") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	cfg, err := os.ReadFile(filepath.Join(repo, ".ssg.yaml"))
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	for _, want := range []string{"paths:\n  - \"**/*\"\n", "allowlist: \".ci/ssg-allowlist.json\"\n", "threshold: 5\n", "policy: \".ci/systemd-security-policy.json\"\n", "mode: enforce\n"} {
+		if !strings.Contains(string(cfg), want) {
+			t.Fatalf("expected %q in config:\n%s", want, cfg)
+		}
+	}
+
+	var policy map[string]policyWeights
+	b, err := os.ReadFile(filepath.Join(repo, ".ci/systemd-security-policy.json"))
+	if err != nil {
+		t.Fatalf("read policy: %v", err)
+	}
+	if err := json.Unmarshal(b, &policy); err != nil || policy["PrivateNetwork"] != (policyWeights{Weight: 2500, Range: 1}) {
+		t.Fatalf("policy = %v, %v", policy["PrivateNetwork"], err)
+	}
+
+	allow, err := allowlist.LoadFile(repo, ".ci/ssg-allowlist.json")
+	if err != nil {
+		t.Fatalf("load allowlist: %v", err)
+	}
+	tests := map[string]bool{}
+	for _, e := range allow.AllowTests {
+		if e.Unit != "deploy/myapp.service" || e.Reason != "baseline" || e.Owner != "@infra" || e.Expires == "" {
+			t.Fatalf("unexpected entry %#v", e)
+		}
+		tests[e.Test] = true
+	}
+	if !tests["PrivateNetwork"] || !tests["UserOrDynamicUser"] {
+		t.Fatalf("expected the unit's issues, got %v", tests)
+	}
+
+	// The gate passes in enforce mode with nothing but the written config.
+	if code, out, stderr := run("scan", "--repo-root", repo, "--backend", "native"); code != 0 || !strings.Contains(out, "allowed") {
+		t.Fatalf("scan exit code = %d\nstdout:\n%s\nstderr:\n%s", code, out, stderr)
+	}
+
+	if code, _, stderr := run("init", "--repo-root", repo, "--threshold", "5", "--backend", "native"); code != 2 || !strings.Contains(stderr, ".ssg.yaml already exists") {
+		t.Fatalf("second init exit code = %d, stderr = %q", code, stderr)
+	}
+}
+
+func TestInitAllowsPathsLiterally(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/[ab].service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := Run([]string{"ssg", "init", "--repo-root", repo, "--threshold", "5", "--backend", "native"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("init exit code = %d\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	allow, err := allowlist.LoadFile(repo, ".ci/ssg-allowlist.json")
+	if err != nil {
+		t.Fatalf("load allowlist: %v", err)
+	}
+	if len(allow.AllowTests) == 0 || allow.AllowTests[0].Unit != `deploy/\[ab\].service` {
+		t.Fatalf("entries = %#v, want the path escaped", allow.AllowTests)
+	}
+
+	// The entries don't cover a new unit the path would match as a glob.
+	mustWrite(t, filepath.Join(repo, "deploy/a.service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+	stdout.Reset()
+	stderr.Reset()
+	if code := Run([]string{"ssg", "scan", "--repo-root", repo, "--backend", "native"}, &stdout, &stderr); code != 1 || !strings.Contains(stdout.String(), "| `a.service` | `deploy/a.service` | ❌ fail |") {
+		t.Fatalf("scan exit code = %d\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestInitForceKeepsConfigRules(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/usr/bin/myapp\n")
+	mustWrite(t, filepath.Join(repo, "deploy/vendor/legacy.service"), "[Service]\nExecStart=/usr/bin/legacy\n")
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), `# Team config
+threshold: 3
+requiredChecks: [PrivateNetwork]
+units:
+  - match: ["deploy/vendor/**"]
+    requiredChecks: []
+`)
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"ssg", "init", "--repo-root", repo, "--paths", "deploy/**/*.service", "--backend", "native", "--max-rating", "medium", "--force"}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "myapp.service exposes required checks the allowlist can't cover: PrivateNetwork") {
+		t.Fatalf("exit code = %d, want a refusal on the required check\nstderr:\n%s", code, stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".ci/ssg-allowlist.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no allowlist after the refusal, got %v", err)
+	}
+
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/usr/bin/myapp\nPrivateNetwork=yes\n")
+	stdout.Reset()
+	stderr.Reset()
+	code = Run([]string{"ssg", "init", "--repo-root", repo, "--paths", "deploy/**/*.service", "--backend", "native", "--max-rating", "medium", "--force"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("init exit code = %d\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	cfg, err := os.ReadFile(filepath.Join(repo, ".ssg.yaml"))
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	for _, want := range []string{"# Team config\n", "maxRating: \"MEDIUM\"\n", "requiredChecks: [PrivateNetwork]\n", "  - match: [\"deploy/vendor/**\"]\n", "paths:\n  - \"deploy/**/*.service\"\n"} {
+		if !strings.Contains(string(cfg), want) {
+			t.Fatalf("expected %q in config:\n%s", want, cfg)
+		}
+	}
+	if strings.Contains(string(cfg), "threshold:") {
+		t.Fatalf("expected maxRating to replace threshold:\n%s", cfg)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := Run([]string{"ssg", "scan", "--repo-root", repo, "--backend", "native"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("scan exit code = %d\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+}
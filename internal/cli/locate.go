@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/allowlist"
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/unitfile"
+)
+
+// parseUnit parses the unit file of unit in treeAbs with its drop-ins.
+func parseUnit(treeAbs string, unit model.UnitFile) (unitfile.File, error) {
+	path := filepath.Join(treeAbs, unit.RepoRelPath)
+	dirs := []string{path + ".d"}
+	if unit.Template != "" {
+		// Per-instance drop-ins live next to the template and win over its own.
+		dirs = append([]string{filepath.Join(filepath.Dir(path), unit.UnitName+".d")}, dirs...)
+	}
+	return unitfile.ParseWithDropInDirs(path, dirs...)
+}
+
+// locateIssues points each exposed check at the line of f that sets its
+// directive, or at the [Service] header when none does. Drop-in overrides
+// point at the drop-in.
+func locateIssues(treeAbs string, f unitfile.File, checks []model.SecurityCheck) {
+	for i := range checks {
+		if checks[i].Exposure <= 0 {
+			continue
+		}
+		pos, ok := f.Locate("Service", directives(checks[i])...)
+		if !ok {
+			continue
+		}
+		rel, ok := treeRel(treeAbs, pos.Path)
+		if !ok {
+			continue
+		}
+		checks[i].Location = &model.Location{Path: rel, Line: pos.Line, EndLine: pos.EndLine, Key: pos.Key}
+	}
+}
+
+// inlineAllowlist returns the suppression comments of f as allowlist entries
+// for the unit at repoRelPath.
+func inlineAllowlist(treeAbs string, f unitfile.File, repoRelPath string) (allowlist.Allowlist, error) {
+	comments := make([]unitfile.Comment, 0, len(f.Comments))
+	for _, c := range f.Comments {
+		if rel, ok := treeRel(treeAbs, c.Path); ok {
+			c.Path = rel
+		}
+		comments = append(comments, c)
+	}
+	return allowlist.Inline(repoRelPath, comments)
+}
+
+// treeRel returns path relative to treeAbs with forward slashes.
+func treeRel(treeAbs, path string) (string, bool) {
+	rel, err := filepath.Rel(treeAbs, path)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// directives returns the unit settings a check is about, taken from its name
+// ("User=/DynamicUser=", "CapabilityBoundingSet=~CAP_SYS_ADMIN") or else the
+// start of its JSON field.
+func directives(c model.SecurityCheck) []string {
+	var keys []string
+	for _, part := range strings.Split(c.Name, "/") {
+		key, _, ok := strings.Cut(part, "=")
+		if ok && key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 && c.JSONField != "" {
+		key, _, _ := strings.Cut(c.JSONField, "_")
+		keys = append(keys, key)
+	}
+	return keys
+}
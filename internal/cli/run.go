@@ -14,6 +14,16 @@ func Run(args []string, stdout, stderr io.Writer) int {
 	switch args[1] {
 	case "scan":
 		return runScan(args[2:], stdout, stderr)
+	case "diff":
+		return runDiff(args[2:], stdout, stderr)
+	case "fix":
+		return runFix(args[2:], stdout, stderr)
+	case "init":
+		return runInit(args[2:], stdout, stderr)
+	case "allowlist":
+		return runAllowlist(args[2:], stdout, stderr)
+	case "explain":
+		return runExplain(args[2:], stdout, stderr)
 	case "-h", "--help", "help":
 		fmt.Fprintln(stdout, usage())
 		return 0
@@ -28,10 +38,21 @@ func usage() string {
 
 Usage:
   ssg scan [flags]
+  ssg diff --base REF [flags]
+  ssg fix [--dry-run] [flags]
+  ssg init (--threshold N | --max-rating RATING) [flags]
+  ssg allowlist prune [--dry-run] [flags]
+  ssg explain [CHECK]
 
 Commands:
-  scan   Scan .service units in a repo and gate on systemd-analyze security
+  scan     Scan systemd units in a repo and gate on systemd-analyze security
+  diff     Report units whose exposure changed since the merge base with REF
+  fix      Write hardening drop-ins for units over their threshold
+  init     Write a config, policy and allowlist of the current issues to adopt the gate
+  allowlist
+           prune: remove allowlist entries that no longer apply to any unit
+  explain  Describe a check: what it measures, its weight and how to fix it
 
-Run "ssg scan -h" for scan flags.
+Run "ssg <command> -h" for flags.
 `
 }
@@ -8,12 +8,23 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/teunlao/systemd-security-gate/internal/allowlist"
+	"github.com/teunlao/systemd-security-gate/internal/baseline"
+	"github.com/teunlao/systemd-security-gate/internal/checkdoc"
+	"github.com/teunlao/systemd-security-gate/internal/codeclimate"
+	"github.com/teunlao/systemd-security-gate/internal/config"
 	"github.com/teunlao/systemd-security-gate/internal/discover"
+	"github.com/teunlao/systemd-security-gate/internal/gitlabsast"
+	"github.com/teunlao/systemd-security-gate/internal/junit"
 	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/nativeanalyze"
 	"github.com/teunlao/systemd-security-gate/internal/offlineroot"
 	"github.com/teunlao/systemd-security-gate/internal/report"
 	"github.com/teunlao/systemd-security-gate/internal/sarif"
@@ -34,205 +45,677 @@ func (s *stringSliceFlag) Set(v string) error {
 	return nil
 }
 
-func runScan(args []string, stdout, stderr io.Writer) int {
-	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
-	fs.SetOutput(stderr)
+// optionalFloat is a float flag that may be passed empty, as the action does
+// for inputs left unset.
+type optionalFloat struct {
+	value float64
+	set   bool
+}
 
-	var (
-		repoRoot       = fs.String("repo-root", ".", "Path to repo root")
-		threshold      = fs.Float64("threshold", -1, "Fail if overall exposure is greater than this value (required)")
-		policyPath     = fs.String("policy", "", "Path to systemd-analyze security policy JSON (optional)")
-		allowlistPath  = fs.String("allowlist", "", "Path to allowlist JSON (optional)")
-		mode           = fs.String("mode", "enforce", "One of: enforce, report")
-		systemdAnalyze = fs.String("systemd-analyze", "systemd-analyze", "Path to systemd-analyze binary")
-		topN           = fs.Int("top", 10, "How many highest-exposure checks to show per unit")
+func (f *optionalFloat) String() string {
+	if !f.set {
+		return ""
+	}
+	return strconv.FormatFloat(f.value, 'f', -1, 64)
+}
 
-		jsonReportPath  = fs.String("json-report", "", "Write combined JSON report to file (optional)")
-		sarifReportPath = fs.String("sarif-report", "", "Write SARIF report to file (optional)")
-		summaryPath     = fs.String("summary-file", "", "Write Markdown summary to file (optional; defaults to $GITHUB_STEP_SUMMARY if set)")
+func (f *optionalFloat) Set(v string) error {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		f.set = false
+		return nil
+	}
+	x, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", v)
+	}
+	if x < 0 {
+		return fmt.Errorf("must not be negative")
+	}
+	f.value, f.set = x, true
+	return nil
+}
 
-		paths   stringSliceFlag
-		exclude stringSliceFlag
-	)
+// parseInstances parses "foo@.service=a,b" values into a template -> instance
+// names map.
+func parseInstances(values []string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, v := range values {
+		template, names, ok := strings.Cut(v, "=")
+		template = strings.TrimSpace(template)
+		if _, instance, isTemplate := offlineroot.SplitInstance(template); !ok || !isTemplate || instance != "" {
+			return nil, fmt.Errorf("expected TEMPLATE@.service=NAME[,NAME...], got %q", v)
+		}
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				out[template] = append(out[template], name)
+			}
+		}
+		if len(out[template]) == 0 {
+			return nil, fmt.Errorf("no instance names for %s", template)
+		}
+	}
+	return out, nil
+}
 
-	fs.Var(&paths, "paths", "Glob to find unit files (repeatable). Example: deploy/systemd/**/*.service")
-	fs.Var(&exclude, "exclude", "Glob to exclude from matches (repeatable)")
+// scanFlags are the flags shared by "scan" and "diff".
+type scanFlags struct {
+	repoRoot       *string
+	policyPath     *string
+	allowlistPath  *string
+	configPath     *string
+	maxRating      *string
+	mode           *string
+	systemdAnalyze *string
+	backend        *string
+	verifyOverall  *bool
+	topN           *int
+	expiryWarnDays *int
+	jobs           *int
 
-	if err := fs.Parse(args); err != nil {
-		if errors.Is(err, flag.ErrHelp) {
-			return 0
-		}
-		return 2
+	threshold      optionalFloat
+	paths          stringSliceFlag
+	exclude        stringSliceFlag
+	instances      stringSliceFlag
+	requiredChecks stringSliceFlag
+}
+
+func addScanFlags(fs *flag.FlagSet) *scanFlags {
+	f := &scanFlags{
+		repoRoot:       fs.String("repo-root", ".", "Path to repo root"),
+		policyPath:     fs.String("policy", "", "Path to systemd-analyze security policy JSON (optional)"),
+		allowlistPath:  fs.String("allowlist", "", "Path to allowlist JSON (optional)"),
+		maxRating:      fs.String("max-rating", "", "Fail if the overall rating is worse than this one, instead of using --threshold. One of: PERFECT, SAFE, OK, MEDIUM, EXPOSED, UNSAFE, DANGEROUS"),
+		configPath:     fs.String("config", "", "Path to repo config YAML with per-unit settings (optional; defaults to "+config.DefaultPath+" if present)"),
+		mode:           fs.String("mode", "", "One of: enforce, report (default enforce)"),
+		systemdAnalyze: fs.String("systemd-analyze", "systemd-analyze", "Path to systemd-analyze binary"),
+		backend:        fs.String("backend", backendSystemdAnalyze, "One of: systemd-analyze, native (built-in scoring, no systemd needed)"),
+		verifyOverall:  fs.Bool("verify-overall", false, "Also run systemd-analyze's text report and fail if its overall exposure differs from the one derived from the JSON table (debug)"),
+		topN:           fs.Int("top", 10, "How many highest-exposure checks to show per unit"),
+		expiryWarnDays: fs.Int("expiry-warning-days", 14, "Warn about allowlist entries that expire within this many days"),
+		jobs:           fs.Int("jobs", 0, "How many units to analyze concurrently (default: number of CPUs)"),
 	}
+	fs.Var(&f.threshold, "threshold", "Fail if overall exposure is greater than this value (required unless set in the config file)")
+	fs.Var(&f.paths, "paths", "Glob to find unit files (repeatable; .service, .socket, .timer, .path). Example: deploy/systemd/**/*.service")
+	fs.Var(&f.exclude, "exclude", "Glob to exclude from matches (repeatable)")
+	fs.Var(&f.instances, "instances", "Instances to analyze a template unit as (repeatable; replaces the config's instances of that template). Example: foo@.service=a,b")
+	fs.Var(&f.requiredChecks, "required-checks", "Check (json_field) that fails the unit if exposed, whatever its overall exposure (repeatable; replaces requiredChecks in the config file). Example: PrivateNetwork")
+	return f
+}
 
-	if *threshold < 0 {
-		fmt.Fprintln(stderr, "error: --threshold is required")
-		return 2
+// scanner analyzes unit trees with the settings resolved from flags, the
+// config file and the allowlist.
+type scanner struct {
+	repoAbs   string
+	cfg       config.Config
+	cfgPath   string
+	defaults  config.Settings
+	bands     nativeanalyze.RatingBands
+	instances map[string][]string
+	allow     allowlist.Allowlist
+	analyze   analyzeFunc
+	version   string
+	topN      int
+	jobs      int
+	now       time.Time
+	// expiryWarn is how soon an allowlist entry must expire to be warned
+	// about.
+	expiryWarn time.Duration
+	warnings   []string
+}
+
+// newScanner validates the flags and loads everything a scan needs. On
+// failure it reports the error to stderr and returns the exit code. Without
+// requireThreshold, units default to the top of the exposure scale.
+func (f *scanFlags) newScanner(stderr io.Writer, requireThreshold bool) (*scanner, int) {
+	if *f.mode != "" && *f.mode != "enforce" && *f.mode != "report" {
+		fmt.Fprintln(stderr, "error: --mode must be one of: enforce, report")
+		return nil, 2
 	}
-	if len(paths) == 0 {
-		fmt.Fprintln(stderr, "error: at least one --paths is required")
-		return 2
+
+	if *f.backend != backendSystemdAnalyze && *f.backend != backendNative {
+		fmt.Fprintln(stderr, "error: --backend must be one of: systemd-analyze, native")
+		return nil, 2
 	}
-	if *mode != "enforce" && *mode != "report" {
-		fmt.Fprintln(stderr, "error: --mode must be one of: enforce, report")
-		return 2
+	if *f.jobs < 0 {
+		fmt.Fprintln(stderr, "error: --jobs must not be negative")
+		return nil, 2
+	}
+	if *f.maxRating != "" {
+		rating, err := config.ParseRating(*f.maxRating)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: --max-rating %v\n", err)
+			return nil, 2
+		}
+		*f.maxRating = rating
+		if f.threshold.set {
+			fmt.Fprintln(stderr, "error: --threshold and --max-rating are mutually exclusive")
+			return nil, 2
+		}
+	}
+
+	instanceMap, err := parseInstances(f.instances)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: --instances: %v\n", err)
+		return nil, 2
 	}
 
-	repoAbs, err := filepath.Abs(*repoRoot)
+	repoAbs, err := filepath.Abs(*f.repoRoot)
 	if err != nil {
 		fmt.Fprintf(stderr, "error: resolve --repo-root: %v\n", err)
-		return 1
+		return nil, 1
 	}
 
-	policyAbs := *policyPath
-	if policyAbs != "" && !filepath.IsAbs(policyAbs) {
-		policyAbs = filepath.Join(repoAbs, policyAbs)
+	s := &scanner{repoAbs: repoAbs, topN: *f.topN, jobs: *f.jobs, now: time.Now(), expiryWarn: time.Duration(*f.expiryWarnDays) * 24 * time.Hour}
+	if s.jobs == 0 {
+		s.jobs = runtime.NumCPU()
 	}
 
-	matches, err := discover.ServiceUnits(repoAbs, paths, exclude)
-	if err != nil {
-		fmt.Fprintf(stderr, "error: discover unit files: %v\n", err)
-		return 1
+	s.cfgPath = *f.configPath
+	if s.cfgPath == "" {
+		if _, err := os.Stat(filepath.Join(repoAbs, config.DefaultPath)); err == nil {
+			s.cfgPath = config.DefaultPath
+		}
 	}
-	if len(matches) == 0 {
-		fmt.Fprintln(stderr, "error: no unit files matched --paths")
-		return 1
+	if s.cfgPath != "" {
+		s.cfg, err = config.LoadFile(repoAbs, s.cfgPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: load config: %v\n", err)
+			return nil, 2
+		}
 	}
-	sort.Strings(matches)
 
-	var allow allowlist.Allowlist
-	if *allowlistPath != "" {
-		allow, err = allowlist.LoadFile(repoAbs, *allowlistPath)
+	if len(f.paths) == 0 {
+		f.paths = append(f.paths, s.cfg.Paths...)
+	}
+	if len(f.paths) == 0 {
+		fmt.Fprintln(stderr, "error: at least one --paths is required (or set paths in the config file)")
+		return nil, 2
+	}
+	if *f.allowlistPath == "" {
+		*f.allowlistPath = s.cfg.Allowlist
+	}
+	s.instances = s.cfg.ResolveInstances(instanceMap)
+
+	// Non-empty flags take precedence over the config file's defaults.
+	s.defaults = config.Settings{Threshold: -1, MaxRating: s.cfg.MaxRating, Policy: s.cfg.Policy, Mode: s.cfg.Mode, RequiredChecks: s.cfg.RequiredChecks}
+	if len(f.requiredChecks) > 0 {
+		s.defaults.RequiredChecks = f.requiredChecks
+	}
+	if s.cfg.Threshold != nil {
+		s.defaults.Threshold = *s.cfg.Threshold
+	}
+	if f.threshold.set {
+		s.defaults.Threshold = f.threshold.value
+		s.defaults.MaxRating = ""
+	}
+	if *f.maxRating != "" {
+		s.defaults.MaxRating = *f.maxRating
+	}
+	if *f.policyPath != "" {
+		s.defaults.Policy = *f.policyPath
+	}
+	if *f.mode != "" {
+		s.defaults.Mode = *f.mode
+	}
+	if s.defaults.Mode == "" {
+		s.defaults.Mode = "enforce"
+	}
+	if s.defaults.Threshold < 0 && s.defaults.MaxRating == "" && !requireThreshold {
+		s.defaults.Threshold = maxExposure
+	}
+	if s.defaults.Threshold < 0 && s.defaults.MaxRating == "" {
+		fmt.Fprintln(stderr, "error: --threshold or --max-rating is required (or set threshold or maxRating in the config file)")
+		return nil, 2
+	}
+
+	if *f.allowlistPath != "" {
+		s.allow, err = allowlist.LoadFile(repoAbs, *f.allowlistPath)
 		if err != nil {
 			fmt.Fprintf(stderr, "error: load allowlist: %v\n", err)
-			return 1
+			return nil, 1
 		}
 	}
 
-	builder := offlineroot.Builder{RepoRootAbs: repoAbs}
-	root, units, err := builder.Build(matches)
+	s.analyze, s.version, err = newAnalyzer(*f.backend, *f.systemdAnalyze, *f.verifyOverall)
 	if err != nil {
-		fmt.Fprintf(stderr, "error: build offline root: %v\n", err)
-		return 1
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return nil, 2
+	}
+
+	// Rate with the bands of the systemd version that scores the units. An
+	// unknown version can't analyze anything, so any bands will do.
+	major, ok := systemdanalyze.VersionMajor(s.version)
+	if !ok {
+		major = nativeanalyze.TargetMajor
+	}
+	if s.bands, ok = nativeanalyze.BandsFor(major); !ok {
+		s.bands, _ = nativeanalyze.BandsFor(nativeanalyze.TargetMajor)
+	}
+	if s.defaults.MaxRating != "" {
+		s.defaults.Threshold = s.ratingThreshold(s.defaults.MaxRating)
 	}
-	defer os.RemoveAll(root)
 
-	sysdVersion, _ := systemdanalyze.GetVersion(*systemdAnalyze)
+	for _, id := range s.requiredChecks() {
+		if _, ok := checkdoc.Get(id); !ok {
+			s.warnings = append(s.warnings, fmt.Sprintf("unknown required check %q (run \"ssg explain\" for the list)", id))
+		}
+	}
+	for _, e := range s.allow.ExpiringWithin(s.now, s.expiryWarn) {
+		s.warnings = append(s.warnings, "allowlist entry expires soon: "+e.Describe())
+	}
+	for _, w := range s.warnings {
+		fmt.Fprintf(stderr, "warning: %s\n", w)
+	}
+	return s, 0
+}
+
+// ratingThreshold is the highest overall exposure rated rating or better.
+func (s *scanner) ratingThreshold(rating string) float64 {
+	exposure, _ := s.bands.MaxExposure(rating)
+	return float64(exposure) / 10
+}
 
+// requiredChecks returns the required checks of the defaults and the config
+// rules, once each.
+func (s *scanner) requiredChecks() []string {
+	lists := [][]string{s.defaults.RequiredChecks}
+	for _, r := range s.cfg.Units {
+		if r.RequiredChecks != nil {
+			lists = append(lists, *r.RequiredChecks)
+		}
+	}
+	var ids []string
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, id := range list {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// newReport starts a ScanReport with the scanner's settings.
+func (s *scanner) newReport(f *scanFlags, matches []string) model.ScanReport {
 	scan := model.ScanReport{
-		RepoRoot:        repoAbs,
-		SystemdAnalyze:  *systemdAnalyze,
-		SystemdVersion:  sysdVersion,
-		Threshold:       *threshold,
-		PolicyPath:      *policyPath,
-		AllowlistPath:   *allowlistPath,
-		Mode:            *mode,
+		RepoRoot:        s.repoAbs,
+		Backend:         *f.backend,
+		SystemdVersion:  s.version,
+		Threshold:       s.defaults.Threshold,
+		MaxRating:       s.defaults.MaxRating,
+		PolicyPath:      s.defaults.Policy,
+		AllowlistPath:   *f.allowlistPath,
+		ConfigPath:      s.cfgPath,
+		Mode:            s.defaults.Mode,
+		RequiredChecks:  s.defaults.RequiredChecks,
 		MatchedServices: append([]string(nil), matches...),
+		Warnings:        s.warnings,
 	}
+	if *f.backend == backendSystemdAnalyze {
+		scan.SystemdAnalyze = *f.systemdAnalyze
+	}
+	return scan
+}
 
-	var hasError bool
-	var hasUnallowedThreshold bool
-	for _, unit := range units {
-		unitRes := model.UnitReport{
-			UnitName:    unit.UnitName,
-			RepoRelPath: unit.RepoRelPath,
-		}
+// scanTree analyzes the units matched under treeAbs, which is the repo
+// itself or a copy of it at another revision. Units are compared with base
+// when it is non-nil.
+func (s *scanner) scanTree(treeAbs string, paths, exclude []string, base *baseline.Baseline) ([]string, []model.UnitReport, error) {
+	matches, root, units, err := s.buildTree(treeAbs, paths, exclude)
+	if err != nil || len(matches) == 0 {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(root)
+	return matches, s.analyzeUnits(treeAbs, root, units, base), nil
+}
 
-		overall, err := systemdanalyze.SecurityOverall(*systemdAnalyze, systemdanalyze.SecurityOverallArgs{
-			Root:       root,
-			UnitName:   unit.UnitName,
-			PolicyPath: policyAbs,
-			Threshold:  *threshold,
-		})
+// buildTree builds the offline root for the units matched under treeAbs.
+// The caller removes root; there is none when nothing matched.
+func (s *scanner) buildTree(treeAbs string, paths, exclude []string) (matches []string, root string, units []model.UnitFile, err error) {
+	matches, err = discover.Units(treeAbs, paths, exclude)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("discover unit files: %w", err)
+	}
+	if len(matches) == 0 {
+		return nil, "", nil, nil
+	}
+	sort.Strings(matches)
+
+	builder := offlineroot.Builder{RepoRootAbs: treeAbs, Instances: s.instances}
+	root, units, err = builder.Build(matches)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("build offline root: %w", err)
+	}
+	return matches, root, units, nil
+}
+
+// analyzeUnits analyzes units in the offline root built from treeAbs, --jobs
+// at a time.
+func (s *scanner) analyzeUnits(treeAbs string, root string, units []model.UnitFile, base *baseline.Baseline) []model.UnitReport {
+	// Workers fill their own slot so the order follows units, not completion.
+	reports := make([]model.UnitReport, len(units))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(s.jobs, len(units)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				reports[i] = s.analyzeUnit(treeAbs, root, units[i], base)
+			}
+		}()
+	}
+	for i := range units {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return reports
+}
+
+// analyzeUnit scores one unit in the offline root built from treeAbs and
+// applies the baseline and allowlist. It is called concurrently.
+func (s *scanner) analyzeUnit(treeAbs string, root string, unit model.UnitFile, base *baseline.Baseline) model.UnitReport {
+	unitRes := model.UnitReport{
+		UnitName:    unit.UnitName,
+		RepoRelPath: unit.RepoRelPath,
+		ActivatedBy: unit.ActivatedBy,
+		Template:    unit.Template,
+	}
+
+	settings := s.cfg.Resolve(s.defaults, unit.RepoRelPath, unit.UnitName, unit.Template)
+	if settings.MaxRating != "" {
+		settings.Threshold = s.ratingThreshold(settings.MaxRating)
+	}
+	unitRes.Threshold = settings.Threshold
+	unitRes.MaxRating = settings.MaxRating
+	unitRes.PolicyPath = settings.Policy
+	unitRes.Mode = settings.Mode
+	if unit.Error != "" {
+		unitRes.Error = unit.Error
+		return unitRes
+	}
+
+	// Locating issues and suppression comments is best effort: the analyzer
+	// reports unit files that can't be parsed.
+	f, parseErr := parseUnit(treeAbs, unit)
+	allow := s.allow
+	if parseErr == nil {
+		inline, err := inlineAllowlist(treeAbs, f, unit.RepoRelPath)
 		if err != nil {
 			unitRes.Error = err.Error()
-			hasError = true
-			scan.Units = append(scan.Units, unitRes)
-			continue
+			return unitRes
 		}
-		unitRes.OverallExposure = overall.OverallExposure
-		unitRes.OverallRating = overall.OverallRating
-		unitRes.ThresholdExceeded = overall.ThresholdExceeded
+		allow = allow.Merge(inline)
+		for _, e := range inline.ExpiringWithin(s.now, s.expiryWarn) {
+			unitRes.Warnings = append(unitRes.Warnings, "allowlist entry expires soon: "+e.Describe())
+		}
+	}
 
-		table, err := systemdanalyze.SecurityTable(*systemdAnalyze, systemdanalyze.SecurityTableArgs{
-			Root:       root,
-			UnitName:   unit.UnitName,
-			PolicyPath: policyAbs,
-		})
-		if err != nil {
-			unitRes.Error = err.Error()
-			hasError = true
-			scan.Units = append(scan.Units, unitRes)
-			continue
+	res, err := s.analyze(root, unit.UnitName, repoPath(s.repoAbs, settings.Policy), settings.Threshold)
+	if err != nil {
+		unitRes.Error = err.Error()
+		return unitRes
+	}
+	unitRes.OverallExposure = res.OverallExposure
+	unitRes.OverallRating = res.OverallRating
+	unitRes.ThresholdExceeded = res.ThresholdExceeded
+	unitRes.Checks = res.Checks
+	if parseErr != nil {
+		unitRes.Warnings = append(unitRes.Warnings, fmt.Sprintf("%s: ignoring ssg:allow comments and finding locations: %v", unit.RepoRelPath, parseErr))
+	}
+	if settings.MaxRating != "" {
+		rank, ok := s.bands.Rank(res.OverallRating)
+		if !ok {
+			unitRes.Error = fmt.Sprintf("unknown overall rating %q", res.OverallRating)
+			return unitRes
 		}
-		unitRes.Checks = table.Checks
+		maxRank, _ := s.bands.Rank(settings.MaxRating)
+		unitRes.ThresholdExceeded = rank > maxRank
+	}
+	if parseErr == nil {
+		locateIssues(treeAbs, f, unitRes.Checks)
+	}
+
+	allIssues := model.Issues(unitRes.Checks)
+	unitRes.TopIssues = model.TopIssues(allIssues, s.topN)
+	unitRes.RequiredChecksFailed = failedRequiredChecks(allIssues, settings.RequiredChecks)
+
+	if base != nil {
+		unitRes.Baseline = base.Compare(unitRes)
+	}
+
+	if unitRes.Flagged() {
+		d := allow.Decide([]string{unitRes.RepoRelPath, unitRes.UnitName, unitRes.Template}, unitRes.OverallExposure, allIssues, s.now)
+		unitRes.Allowed = d.Allowed
+		unitRes.ExceptionExpired = d.Expired
+		unitRes.OverBudget = d.OverBudget
+		unitRes.Exceptions = d.Exceptions
+	}
 
-		allIssues := model.Issues(unitRes.Checks)
-		unitRes.TopIssues = model.TopIssues(allIssues, *topN)
+	return unitRes
+}
 
-		if unitRes.ThresholdExceeded {
-			if allow.AllowsUnit(unitRes.RepoRelPath) || allow.AllowsUnit(unitRes.UnitName) {
-				unitRes.Allowed = true
-			} else if allow.AllowsAllIssues(unitRes.RepoRelPath, unitRes.UnitName, allIssues) {
-				unitRes.Allowed = true
-			} else {
-				hasUnallowedThreshold = true
+// failedRequiredChecks returns the IDs of the required checks among issues,
+// in the order of required.
+func failedRequiredChecks(issues []model.SecurityCheck, required []string) []string {
+	var failed []string
+	for _, id := range required {
+		for _, c := range issues {
+			if model.CheckID(c) == id {
+				failed = append(failed, id)
+				break
 			}
 		}
+	}
+	return failed
+}
 
-		scan.Units = append(scan.Units, unitRes)
+// addUnitWarnings reports the warnings of units, once each, and adds them to
+// scan.
+func addUnitWarnings(scan *model.ScanReport, units []model.UnitReport, stderr io.Writer) {
+	seen := map[string]bool{}
+	for _, u := range units {
+		for _, w := range u.Warnings {
+			if seen[w] {
+				continue
+			}
+			seen[w] = true
+			fmt.Fprintf(stderr, "warning: %s\n", w)
+			scan.Warnings = append(scan.Warnings, w)
+		}
 	}
+}
 
-	md := report.MarkdownSummary(scan)
-	fmt.Fprintln(stdout, md)
+// exitCode is 1 if any unit failed analysis or fails the gate in enforce
+// mode.
+func exitCode(units []model.UnitReport) int {
+	for _, u := range units {
+		if u.Error != "" {
+			return 1
+		}
+		if u.Failing() && u.Mode == "enforce" {
+			return 1
+		}
+	}
+	return 0
+}
 
-	if *summaryPath == "" {
-		if p := os.Getenv("GITHUB_STEP_SUMMARY"); p != "" {
-			*summaryPath = p
+func runScan(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	f := addScanFlags(fs)
+	var (
+		baselinePath = fs.String("baseline", "", "Path to a previous JSON report; fail on regressions against it instead of on the threshold (optional)")
+
+		jsonReportPath  = fs.String("json-report", "", "Write combined JSON report to file (optional)")
+		sarifReportPath = fs.String("sarif-report", "", "Write SARIF report to file (optional)")
+		sarifScope      = fs.String("sarif-scope", string(sarif.ScopeFailing), "One of: failing (top issues of failing units), all (every exposed check of every unit)")
+		sarifBaseline   = fs.String("sarif-baseline", "", "Previous SARIF or JSON report to set SARIF baselineState against (optional; defaults to --baseline)")
+		junitReportPath = fs.String("junit-report", "", "Write JUnit XML report to file (optional)")
+		sastReportPath  = fs.String("gitlab-sast-report", "", "Write GitLab SAST report to file (optional)")
+		ccReportPath    = fs.String("codeclimate-report", "", "Write Code Climate (GitLab Code Quality) report to file (optional)")
+		summaryPath     = fs.String("summary-file", "", "Write Markdown summary to file (optional; defaults to $GITHUB_STEP_SUMMARY if set)")
+		failOnStale     = fs.Bool("fail-on-stale-allowlist", false, "Fail if allowlist entries no longer apply to any unit")
+	)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
 		}
+		return 2
 	}
-	if *summaryPath != "" {
-		f, err := os.OpenFile(*summaryPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+
+	// Only units missing from the baseline would use a threshold.
+	s, code := f.newScanner(stderr, *baselinePath == "")
+	if s == nil {
+		return code
+	}
+
+	var base *baseline.Baseline
+	if *baselinePath != "" {
+		b, err := baseline.LoadFile(s.repoAbs, *baselinePath)
 		if err != nil {
-			fmt.Fprintf(stderr, "warning: write summary file: %v\n", err)
-		} else {
-			if _, err := f.WriteString(md); err != nil {
-				fmt.Fprintf(stderr, "warning: write summary file: %v\n", err)
-			}
-			_ = f.Close()
+			fmt.Fprintf(stderr, "error: load baseline: %v\n", err)
+			return 2
 		}
+		base = &b
 	}
 
-	if *jsonReportPath != "" {
-		b, err := json.MarshalIndent(scan, "", "  ")
+	scope := sarif.Scope(*sarifScope)
+	if scope != sarif.ScopeFailing && scope != sarif.ScopeAll {
+		fmt.Fprintln(stderr, "error: --sarif-scope must be one of: failing, all")
+		return 2
+	}
+	var prevSarif *sarif.Previous
+	if *sarifReportPath != "" && (*sarifBaseline != "" || *baselinePath != "") {
+		p := *sarifBaseline
+		if p == "" {
+			p = *baselinePath
+		}
+		var err error
+		prevSarif, err = sarif.LoadPrevious(repoPath(s.repoAbs, p), scope)
 		if err != nil {
-			fmt.Fprintf(stderr, "error: build JSON report: %v\n", err)
-			return 1
+			fmt.Fprintf(stderr, "error: load SARIF baseline: %v\n", err)
+			return 2
 		}
-		if err := os.WriteFile(*jsonReportPath, append(b, '\n'), 0o644); err != nil {
+	}
+
+	matches, units, err := s.scanTree(s.repoAbs, f.paths, f.exclude, base)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	if len(matches) == 0 {
+		fmt.Fprintln(stderr, "error: no unit files matched --paths")
+		return 1
+	}
+
+	scan := s.newReport(f, matches)
+	scan.BaselinePath = *baselinePath
+	scan.Units = units
+	scan.Templates = model.GroupTemplates(scan.Units)
+	addUnitWarnings(&scan, scan.Units, stderr)
+	for _, e := range s.staleAllowlist(scan.Units) {
+		w := fmt.Sprintf("stale allowlist entry (%s): %s", e.Cause, e.Describe())
+		fmt.Fprintf(stderr, "warning: %s\n", w)
+		scan.Warnings = append(scan.Warnings, w)
+		scan.StaleExceptions = append(scan.StaleExceptions, e.StaleException)
+	}
+
+	md := report.MarkdownSummary(scan)
+	fmt.Fprintln(stdout, md)
+	writeSummaryFile(*summaryPath, md, stderr)
+
+	if *jsonReportPath != "" {
+		if err := writeJSON(*jsonReportPath, scan); err != nil {
 			fmt.Fprintf(stderr, "error: write JSON report: %v\n", err)
 			return 1
 		}
 	}
 
 	if *sarifReportPath != "" {
-		s := sarif.FromScanReport(scan)
-		b, err := json.MarshalIndent(s, "", "  ")
-		if err != nil {
-			fmt.Fprintf(stderr, "error: build SARIF report: %v\n", err)
+		if err := writeJSON(*sarifReportPath, sarif.FromScanReportWith(scan, sarif.Options{Scope: scope, Previous: prevSarif})); err != nil {
+			fmt.Fprintf(stderr, "error: write SARIF report: %v\n", err)
 			return 1
 		}
-		if err := os.WriteFile(*sarifReportPath, append(b, '\n'), 0o644); err != nil {
-			fmt.Fprintf(stderr, "error: write SARIF report: %v\n", err)
+	}
+
+	if *junitReportPath != "" {
+		if err := writeJUnit(*junitReportPath, scan); err != nil {
+			fmt.Fprintf(stderr, "error: write JUnit report: %v\n", err)
 			return 1
 		}
 	}
 
-	if hasError {
-		return 1
+	if *sastReportPath != "" {
+		if err := writeJSON(*sastReportPath, gitlabsast.FromScanReport(scan, s.now, time.Now())); err != nil {
+			fmt.Fprintf(stderr, "error: write GitLab SAST report: %v\n", err)
+			return 1
+		}
 	}
-	if hasUnallowedThreshold && *mode == "enforce" {
+
+	if *ccReportPath != "" {
+		if err := writeJSON(*ccReportPath, codeclimate.FromScanReport(scan)); err != nil {
+			fmt.Fprintf(stderr, "error: write Code Climate report: %v\n", err)
+			return 1
+		}
+	}
+
+	if *failOnStale && len(scan.StaleExceptions) > 0 {
+		fmt.Fprintf(stderr, "error: %d stale allowlist entries (remove them with \"ssg allowlist prune\")\n", len(scan.StaleExceptions))
 		return 1
 	}
-	return 0
+	return exitCode(scan.Units)
+}
+
+// writeSummaryFile appends md to path, or to $GITHUB_STEP_SUMMARY when path
+// is empty. Failures are only warnings.
+func writeSummaryFile(path string, md string, stderr io.Writer) {
+	if path == "" {
+		path = os.Getenv("GITHUB_STEP_SUMMARY")
+	}
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		fmt.Fprintf(stderr, "warning: write summary file: %v\n", err)
+		return
+	}
+	if _, err := f.WriteString(md); err != nil {
+		fmt.Fprintf(stderr, "warning: write summary file: %v\n", err)
+	}
+	_ = f.Close()
+}
+
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func writeJUnit(path string, scan model.ScanReport) error {
+	b, err := junit.Marshal(junit.FromScanReport(scan))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// maxExposure is the top of systemd's exposure scale.
+const maxExposure = 10
+
+// repoPath resolves a path from flags or the config file against the repo root.
+func repoPath(repoAbs string, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(repoAbs, p)
 }
@@ -2,14 +2,23 @@ package cli
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/teunlao/systemd-security-gate/internal/codeclimate"
+	"github.com/teunlao/systemd-security-gate/internal/gitlabsast"
+	"github.com/teunlao/systemd-security-gate/internal/junit"
 	"github.com/teunlao/systemd-security-gate/internal/model"
 	"github.com/teunlao/systemd-security-gate/internal/sarif"
+	"github.com/teunlao/systemd-security-gate/internal/version"
 )
 
 func TestScanEnforceFailsWithoutAllowlist(t *testing.T) {
@@ -105,6 +114,58 @@ func TestScanAllowlistAllows(t *testing.T) {
 	}
 }
 
+func TestScanAllowlistExpiry(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/myapp.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/other.service"), "[Service]\nExecStart=/bin/true\n")
+	expired := time.Now().AddDate(0, 0, -2).Format("2006-01-02")
+	soon := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	mustWrite(t, filepath.Join(repo, "allow.json"), `{
+  "allowUnits": [
+    { "unit": "myapp.service", "owner": "@web", "expires": "`+expired+`" },
+    { "unit": "other.service", "ticket": "OPS-7", "expires": "`+soon+`" }
+  ]
+}`)
+
+	stub := writeSystemdAnalyzeStub(t, repo, stubOptions{
+		exposure: 7.2,
+		rating:   "EXPOSED",
+	})
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/systemd/**/*.service",
+		"--threshold", "6.0",
+		"--allowlist", "allow.json",
+		"--systemd-analyze", stub,
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "⌛ expired exception") || !strings.Contains(stdout.String(), "⚠️ allowed") {
+		t.Fatalf("expected expired and allowed units in stdout, got:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "warning: allowlist entry expires soon: other.service (expires: "+soon+", ticket: OPS-7)") {
+		t.Fatalf("expected expiry warning, got stderr:\n%s", stderr.String())
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	myapp, other := report.Units[0], report.Units[1]
+	if myapp.Allowed || !myapp.ExceptionExpired || len(myapp.Exceptions) != 1 || myapp.Exceptions[0].Owner != "@web" {
+		t.Fatalf("myapp.service = %#v, want expired exception", myapp)
+	}
+	if !other.Allowed || other.ExceptionExpired || len(report.Warnings) != 1 {
+		t.Fatalf("report = %#v, want other.service allowed with one warning", report)
+	}
+}
+
 func TestScanReportModeDoesNotFailOnThreshold(t *testing.T) {
 	repo := t.TempDir()
 	mustWrite(t, filepath.Join(repo, "deploy/systemd/myapp.service"), "[Service]\nExecStart=/bin/true\n")
@@ -192,6 +253,827 @@ func TestScanWritesGithubStepSummary(t *testing.T) {
 	}
 }
 
+func TestScanMapsSocketToService(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/web.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/web.socket"), "[Socket]\nListenStream=8080\n")
+
+	stub := writeSystemdAnalyzeStub(t, repo, stubOptions{
+		exposure: 4.1,
+		rating:   "OK",
+	})
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/systemd/**/*.socket",
+		"--threshold", "6.0",
+		"--systemd-analyze", stub,
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if len(report.Units) != 1 {
+		t.Fatalf("expected 1 unit in json report, got %d", len(report.Units))
+	}
+	u := report.Units[0]
+	if u.UnitName != "web.service" || len(u.ActivatedBy) != 1 || u.ActivatedBy[0] != "web.socket" {
+		t.Fatalf("unit = %#v, want web.service activated by web.socket", u)
+	}
+}
+
+func TestScanReportsBrokenActivatorPerUnit(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/orphan.socket"), "[Socket]\nListenStream=80\n")
+	mustWrite(t, filepath.Join(repo, "deploy/web.service"), "[Service]\nExecStart=/bin/true\nDynamicUser=yes\nPrivateNetwork=yes\nProtectSystem=strict\nProtectHome=yes\nPrivateDevices=yes\nCapabilityBoundingSet=\n")
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/*",
+		"--threshold", "6.0",
+		"--backend", "native",
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if len(report.Units) != 2 {
+		t.Fatalf("units = %#v, want orphan.socket and web.service", report.Units)
+	}
+	orphan, web := report.Units[0], report.Units[1]
+	if orphan.UnitName != "orphan.socket" || !strings.Contains(orphan.Error, `"orphan.service", which was not found`) {
+		t.Fatalf("orphan.socket = %#v, want a missing service error", orphan)
+	}
+	if web.UnitName != "web.service" || web.Error != "" || web.OverallExposure != 5.0 {
+		t.Fatalf("web.service = %#v, want it analyzed", web)
+	}
+}
+
+func TestScanTemplateInstances(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/worker@.service"), "[Service]\nExecStart=/bin/true\n")
+
+	stub := writeSystemdAnalyzeStub(t, repo, stubOptions{
+		exposure: 4.1,
+		rating:   "OK",
+	})
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/systemd/**/*.service",
+		"--threshold", "6.0",
+		"--instances", "worker@.service=a,b",
+		"--systemd-analyze", stub,
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if len(report.Units) != 2 || report.Units[0].UnitName != "worker@a.service" || report.Units[1].UnitName != "worker@b.service" {
+		t.Fatalf("units = %#v, want worker@a and worker@b", report.Units)
+	}
+	if len(report.Templates) != 1 || report.Templates[0].Template != "worker@.service" || len(report.Templates[0].Instances) != 2 {
+		t.Fatalf("templates = %#v, want worker@.service with 2 instances", report.Templates)
+	}
+}
+
+func TestScanTemplateInstancesFromConfig(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/worker@.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/web@.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), `paths: ["deploy/systemd/*.service"]
+threshold: 6.0
+instances:
+  worker@.service: [a, b]
+  web@.service: [main]
+`)
+
+	stub := writeSystemdAnalyzeStub(t, repo, stubOptions{
+		exposure: 4.1,
+		rating:   "OK",
+	})
+
+	run := func(args ...string) []string {
+		t.Helper()
+		jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+		var stdout, stderr bytes.Buffer
+		code := Run(append([]string{
+			"ssg", "scan",
+			"--repo-root", repo,
+			"--systemd-analyze", stub,
+			"--json-report", jsonReport,
+		}, args...), &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("exit code = %d, want 0\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+		}
+		var report model.ScanReport
+		mustReadJSON(t, jsonReport, &report)
+		var names []string
+		for _, u := range report.Units {
+			names = append(names, u.UnitName)
+		}
+		return names
+	}
+
+	if got := run(); strings.Join(got, " ") != "web@main.service worker@a.service worker@b.service" {
+		t.Fatalf("units = %v, want the config's instances", got)
+	}
+	// --instances replaces the config's names for its template only.
+	if got := run("--instances", "worker@.service=c"); strings.Join(got, " ") != "web@main.service worker@c.service" {
+		t.Fatalf("units = %v, want worker@c from the flag and web@main from the config", got)
+	}
+	// The config's instances cover the whole repo, not just --paths.
+	if got := run("--paths", "deploy/systemd/worker@.service"); strings.Join(got, " ") != "worker@a.service worker@b.service" {
+		t.Fatalf("units = %v, want worker@a and worker@b only", got)
+	}
+}
+
+func TestScanNativeBackend(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/plain.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/worker@.service"), "[Service]\nExecStart=/bin/true\nUser=%i\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/worker@.service.d/hardening.conf"), "[Service]\nProtectSystem=strict\n")
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/systemd/**/*.service",
+		"--threshold", "9.5",
+		"--instances", "worker@.service=alice",
+		"--backend", "native",
+		"--systemd-analyze", filepath.Join(repo, "does-not-exist"),
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Backend: native") {
+		t.Fatalf("expected native backend in summary, got:\n%s", stdout.String())
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if report.Backend != "native" || len(report.Units) != 2 {
+		t.Fatalf("report = %#v, want 2 units from the native backend", report)
+	}
+	plain, worker := report.Units[0], report.Units[1]
+	if plain.OverallExposure != 9.6 || plain.OverallRating != "UNSAFE" || !plain.ThresholdExceeded {
+		t.Fatalf("plain.service = %#v, want 9.6 UNSAFE over threshold", plain)
+	}
+	if worker.ThresholdExceeded || worker.OverallExposure >= plain.OverallExposure || len(worker.Checks) != 81 {
+		t.Fatalf("worker@alice.service = %#v, want a lower exposure under threshold", worker)
+	}
+}
+
+func TestScanConfigPerUnitSettings(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/app/api.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/vendor/legacy.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, ".ci/vendor-policy.json"), "{}\n")
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), `threshold: 3.0
+units:
+  - match: ["deploy/vendor/**"]
+    threshold: 9.7
+    policy: .ci/vendor-policy.json
+  - match: ["api.service"]
+    mode: report
+`)
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/**/*.service",
+		"--backend", "native",
+		// Empty values, as the action passes for unset inputs, keep the config defaults.
+		"--threshold", "",
+		"--policy", "",
+		"--mode", "",
+		"--config", "",
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "| 9.70 (`.ci/vendor-policy.json`) |") || !strings.Contains(stdout.String(), "❌ fail (report)") {
+		t.Fatalf("expected per-unit settings in summary, got:\n%s", stdout.String())
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if report.ConfigPath != ".ssg.yaml" || report.Threshold != 3 {
+		t.Fatalf("report = %#v, want config defaults", report)
+	}
+	api, legacy := report.Units[0], report.Units[1]
+	if api.Threshold != 3 || api.Mode != "report" || !api.ThresholdExceeded {
+		t.Fatalf("api.service = %#v, want threshold 3 in report mode", api)
+	}
+	if legacy.Threshold != 9.7 || legacy.Mode != "enforce" || legacy.PolicyPath != ".ci/vendor-policy.json" || legacy.ThresholdExceeded {
+		t.Fatalf("legacy.service = %#v, want vendor threshold and policy", legacy)
+	}
+
+	// An explicit --threshold replaces the config default but not the rules.
+	stdout.Reset()
+	stderr.Reset()
+	code = Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/**/*.service",
+		"--backend", "native",
+		"--threshold", "9.0",
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	mustReadJSON(t, jsonReport, &report)
+	if report.Units[0].Threshold != 9 || report.Units[1].Threshold != 9.7 {
+		t.Fatalf("units = %#v, want thresholds 9 and 9.7", report.Units)
+	}
+}
+
+func TestScanRequiredChecks(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/app/api.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/app/isolated.service"), "[Service]\nExecStart=/bin/true\nPrivateNetwork=yes\n")
+	mustWrite(t, filepath.Join(repo, "deploy/vendor/legacy.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), `threshold: 9.9
+requiredChecks: [PrivateNetwork, NoSuchCheck]
+units:
+  - match: ["deploy/vendor/**"]
+    requiredChecks: []
+`)
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+	junitReport := filepath.Join(t.TempDir(), "junit.xml")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/**/*.service",
+		"--backend", "native",
+		"--json-report", jsonReport,
+		"--junit-report", junitReport,
+	}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stderr.String(), `warning: unknown required check "NoSuchCheck"`) {
+		t.Fatalf("expected a warning about the unknown check, got:\n%s", stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "| ❌ required checks |") || !strings.Contains(stdout.String(), "- Required checks exposed: `PrivateNetwork`") {
+		t.Fatalf("expected required check failure in summary, got:\n%s", stdout.String())
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	api, isolated, legacy := report.Units[0], report.Units[1], report.Units[2]
+	if api.ThresholdExceeded || len(api.RequiredChecksFailed) != 1 || api.RequiredChecksFailed[0] != "PrivateNetwork" {
+		t.Fatalf("api.service = %#v, want PrivateNetwork failing under the threshold", api)
+	}
+	if len(isolated.RequiredChecksFailed) != 0 || len(legacy.RequiredChecksFailed) != 0 {
+		t.Fatalf("units = %#v, want no required check failures for isolated and legacy", report.Units)
+	}
+	if b, err := os.ReadFile(junitReport); err != nil || strings.Count(string(b), `type="RequiredCheckFailed"`) != 1 {
+		t.Fatalf("junit report = %s (%v), want one RequiredCheckFailed failure", b, err)
+	}
+
+	// In report mode the failure is reported without failing the scan.
+	stdout.Reset()
+	stderr.Reset()
+	code = Run([]string{"ssg", "scan", "--repo-root", repo, "--paths", "deploy/**/*.service", "--backend", "native", "--mode", "report", "--required-checks", "RootDirectoryOrRootImage"}, &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), "- Required checks: `RootDirectoryOrRootImage`") || strings.Contains(stderr.String(), "unknown required check") {
+		t.Fatalf("exit code = %d, want 0\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+}
+
+func TestScanMaxRating(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/app/api.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/app/hardened.service"), "[Service]\nExecStart=/bin/true\nDynamicUser=yes\nPrivateNetwork=yes\nProtectSystem=strict\nProtectHome=yes\nPrivateDevices=yes\nCapabilityBoundingSet=\n")
+	mustWrite(t, filepath.Join(repo, "deploy/vendor/legacy.service"), "[Service]\nExecStart=/bin/true\n")
+	// Ratings are case-insensitive in the config too.
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), `maxRating: medium
+units:
+  - match: ["deploy/vendor/**"]
+    threshold: 9.7
+`)
+
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/**/*.service",
+		"--backend", "native",
+		"--json-report", jsonReport,
+	}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "- Max rating: MEDIUM (exposure up to 7.40)") || !strings.Contains(stdout.String(), "| ❌ fail | 9.60 UNSAFE | MEDIUM |") {
+		t.Fatalf("expected rating gate in summary, got:\n%s", stdout.String())
+	}
+
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if report.MaxRating != "MEDIUM" || report.Threshold != 7.4 {
+		t.Fatalf("report = %#v, want max rating MEDIUM up to 7.4", report)
+	}
+	api, hardened, legacy := report.Units[0], report.Units[1], report.Units[2]
+	if api.MaxRating != "MEDIUM" || !api.ThresholdExceeded {
+		t.Fatalf("api.service = %#v, want UNSAFE to fail MEDIUM", api)
+	}
+	if hardened.OverallRating != "MEDIUM" || hardened.ThresholdExceeded {
+		t.Fatalf("hardened.service = %#v, want MEDIUM to pass", hardened)
+	}
+	if legacy.MaxRating != "" || legacy.Threshold != 9.7 || legacy.ThresholdExceeded {
+		t.Fatalf("legacy.service = %#v, want its rule's threshold instead of the rating", legacy)
+	}
+
+	// --max-rating overrides the config default and is case-insensitive.
+	stdout.Reset()
+	stderr.Reset()
+	code = Run([]string{"ssg", "scan", "--repo-root", repo, "--paths", "deploy/app/*.service", "--backend", "native", "--max-rating", "unsafe"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0\nstdout:\n%s\nstderr:\n%s", code, stdout.String(), stderr.String())
+	}
+
+	for _, args := range [][]string{
+		{"--max-rating", "bad"},
+		{"--max-rating", "OK", "--threshold", "3"},
+	} {
+		stderr.Reset()
+		code = Run(append([]string{"ssg", "scan", "--repo-root", repo, "--backend", "native"}, args...), &stdout, &stderr)
+		if code != 2 || !strings.Contains(stderr.String(), "--max-rating") {
+			t.Fatalf("%v: exit code = %d, stderr = %q", args, code, stderr.String())
+		}
+	}
+}
+
+func TestScanRejectsInvalidConfig(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/a.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "ssg.yaml"), "units:\n  - match: [\"*.service\"]\n    mode: strict\n")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"ssg", "scan", "--repo-root", repo, "--paths", "deploy/*.service", "--threshold", "5", "--config", "ssg.yaml", "--backend", "native"}, &stdout, &stderr)
+	if code != 2 || !strings.Contains(stderr.String(), "load config: ssg.yaml: units[0]: mode must be one of") {
+		t.Fatalf("exit code = %d, stderr = %q", code, stderr.String())
+	}
+}
+
+func TestScanBaselineRegression(t *testing.T) {
+	repo := t.TempDir()
+	unit := filepath.Join(repo, "deploy/systemd/myapp.service")
+	mustWrite(t, unit, "[Service]\nExecStart=/bin/true\nPrivateNetwork=yes\nProtectSystem=strict\n")
+	baselineReport := filepath.Join(repo, "ssg-baseline.json")
+	jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+
+	scan := func(extra ...string) (int, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		args := append([]string{
+			"ssg", "scan",
+			"--repo-root", repo,
+			"--paths", "deploy/systemd/**/*.service",
+			"--backend", "native",
+		}, extra...)
+		code := Run(args, &stdout, &stderr)
+		if stderr.Len() > 0 {
+			t.Logf("stderr:\n%s", stderr.String())
+		}
+		return code, stdout.String()
+	}
+
+	// Record the baseline; the unit is far above any sensible threshold.
+	if code, out := scan("--threshold", "1", "--mode", "report", "--json-report", baselineReport); code != 0 {
+		t.Fatalf("baseline run exit code = %d\n%s", code, out)
+	}
+
+	// Unchanged: passes without a threshold.
+	if code, out := scan("--baseline", "ssg-baseline.json"); code != 0 || !strings.Contains(out, "+0.00 (was ") {
+		t.Fatalf("unchanged run exit code = %d\n%s", code, out)
+	}
+
+	// Dropping PrivateNetwork= exposes a previously clean check.
+	mustWrite(t, unit, "[Service]\nExecStart=/bin/true\nProtectSystem=strict\n")
+	sarifReport := filepath.Join(t.TempDir(), "ssg.sarif")
+	code, out := scan("--baseline", "ssg-baseline.json", "--json-report", jsonReport, "--sarif-report", sarifReport)
+	if code != 1 || !strings.Contains(out, "❌ regressed") || !strings.Contains(out, "introduced `PrivateNetwork`") {
+		t.Fatalf("regressed run exit code = %d\n%s", code, out)
+	}
+	var report model.ScanReport
+	mustReadJSON(t, jsonReport, &report)
+	if d := report.Units[0].Baseline; d == nil || !d.Regressed || d.Delta <= 0 || report.BaselinePath != "ssg-baseline.json" {
+		t.Fatalf("report = %#v, want a regressed baseline delta", report)
+	}
+	var sarifReportObj sarif.Report
+	mustReadJSON(t, sarifReport, &sarifReportObj)
+	states := map[string]string{}
+	for _, r := range sarifReportObj.Runs[0].Results {
+		states[r.RuleID] = r.BaselineState
+	}
+	if states["systemd.PrivateNetwork"] != "new" || states["systemd.UserOrDynamicUser"] != "unchanged" {
+		t.Fatalf("SARIF baseline states = %v, want PrivateNetwork new and UserOrDynamicUser unchanged", states)
+	}
+
+	// Hardening further is fine and reported as resolved.
+	mustWrite(t, unit, "[Service]\nExecStart=/bin/true\nPrivateNetwork=yes\nProtectSystem=strict\nNoNewPrivileges=yes\n")
+	if code, out := scan("--baseline", "ssg-baseline.json"); code != 0 || !strings.Contains(out, "resolved `NoNewPrivileges`") {
+		t.Fatalf("improved run exit code = %d\n%s", code, out)
+	}
+}
+
+func TestScanJobsKeepsUnitOrder(t *testing.T) {
+	repo := t.TempDir()
+	options := []string{"PrivateNetwork=yes", "ProtectSystem=strict", "NoNewPrivileges=yes", "PrivateTmp=yes", "ProtectHome=yes"}
+	for i := 0; i < 24; i++ {
+		content := "[Service]\nExecStart=/bin/true\n"
+		for j, opt := range options {
+			if i&(1<<j) != 0 {
+				content += opt + "\n"
+			}
+		}
+		mustWrite(t, filepath.Join(repo, "deploy", fmt.Sprintf("unit%02d.service", i)), content)
+	}
+
+	scan := func(jobs string) []byte {
+		t.Helper()
+		jsonReport := filepath.Join(t.TempDir(), "ssg.json")
+		var stdout, stderr bytes.Buffer
+		code := Run([]string{
+			"ssg", "scan",
+			"--repo-root", repo,
+			"--paths", "deploy/*.service",
+			"--threshold", "10",
+			"--backend", "native",
+			"--jobs", jobs,
+			"--json-report", jsonReport,
+		}, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("jobs=%s: exit code = %d\nstderr:\n%s", jobs, code, stderr.String())
+		}
+		b, err := os.ReadFile(jsonReport)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	sequential := scan("1")
+	for i := 0; i < 3; i++ {
+		if parallel := scan("8"); !bytes.Equal(parallel, sequential) {
+			t.Fatalf("--jobs 8 report differs from --jobs 1")
+		}
+	}
+
+	var report model.ScanReport
+	if err := json.Unmarshal(sequential, &report); err != nil {
+		t.Fatal(err)
+	}
+	for i, u := range report.Units {
+		if want := fmt.Sprintf("unit%02d.service", i); u.UnitName != want {
+			t.Fatalf("units[%d] = %s, want %s", i, u.UnitName, want)
+		}
+	}
+}
+
+func TestScanSarifRegions(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Unit]\nDescription=demo\n\n[Service]\nExecStart=/bin/true\nProtectSystem=no\n")
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service.d/10-net.conf"), "[Service]\nPrivateNetwork=no\n")
+
+	stub := writeSystemdAnalyzeStub(t, repo, stubOptions{})
+	sarifReport := filepath.Join(t.TempDir(), "ssg.sarif")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/*.service",
+		"--threshold", "6.0",
+		"--systemd-analyze", stub,
+		"--sarif-report", sarifReport,
+	}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstderr:\n%s", code, stderr.String())
+	}
+
+	var r sarif.Report
+	mustReadJSON(t, sarifReport, &r)
+	got := map[string]string{}
+	for _, res := range r.Runs[0].Results {
+		loc := res.Locations[0].PhysicalLocation
+		if loc.Region == nil {
+			t.Fatalf("%s has no region", res.RuleID)
+		}
+		got[res.RuleID] = fmt.Sprintf("%s:%d", loc.ArtifactLocation.URI, loc.Region.StartLine)
+	}
+	want := map[string]string{
+		"systemd.PrivateNetwork": "deploy/myapp.service.d/10-net.conf:2",
+		"systemd.ProtectSystem":  "deploy/myapp.service:6",
+	}
+	for id, loc := range want {
+		if got[id] != loc {
+			t.Fatalf("%s at %q, want %q (all: %v)", id, got[id], loc, got)
+		}
+	}
+
+	// The suggested fix replaces the drop-in's assignment.
+	for _, res := range r.Runs[0].Results {
+		if res.RuleID != "systemd.PrivateNetwork" {
+			continue
+		}
+		if len(res.Fixes) != 1 || !strings.Contains(res.Message.Text, "Suggested: PrivateNetwork=yes") {
+			t.Fatalf("PrivateNetwork result = %#v", res)
+		}
+		change := res.Fixes[0].ArtifactChanges[0]
+		rep := change.Replacements[0]
+		if change.ArtifactLocation.URI != "deploy/myapp.service.d/10-net.conf" || rep.DeletedRegion != (sarif.Region{StartLine: 2, EndLine: 2}) || rep.InsertedContent.Text != "PrivateNetwork=yes" {
+			t.Fatalf("PrivateNetwork fix = %#v", change)
+		}
+	}
+}
+
+func TestScanSarifFixesContinuationsAndLists(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/bin/true\nProtectSystem=\\\n  full\nCapabilityBoundingSet=CAP_SYS_ADMIN \\\n  CAP_NET_ADMIN\nPrivateTmp=yes\n")
+	sarifReport := filepath.Join(t.TempDir(), "ssg.sarif")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/*.service",
+		"--threshold", "6.0",
+		"--backend", "native",
+		"--sarif-report", sarifReport,
+		"--sarif-scope", "all",
+	}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstderr:\n%s", code, stderr.String())
+	}
+
+	var r sarif.Report
+	mustReadJSON(t, sarifReport, &r)
+	results := map[string]sarif.Result{}
+	for _, res := range r.Runs[0].Results {
+		results[res.RuleID] = res
+	}
+
+	// The continued assignment is replaced as a whole.
+	protect := results["systemd.ProtectSystem"]
+	if region := protect.Locations[0].PhysicalLocation.Region; region == nil || *region != (sarif.Region{StartLine: 3, EndLine: 4}) {
+		t.Fatalf("ProtectSystem region = %#v, want lines 3-4", region)
+	}
+	if len(protect.Fixes) != 1 {
+		t.Fatalf("ProtectSystem fixes = %#v, want one", protect.Fixes)
+	}
+	if rep := protect.Fixes[0].ArtifactChanges[0].Replacements[0]; rep.DeletedRegion != (sarif.Region{StartLine: 3, EndLine: 4}) || rep.InsertedContent.Text != "ProtectSystem=strict" {
+		t.Fatalf("ProtectSystem fix = %#v", rep)
+	}
+
+	// Another entry would add to the existing capability list, not replace it.
+	if caps := results["systemd.CapabilityBoundingSet_CAP_SYS_ADMIN"]; len(caps.Fixes) != 0 || !strings.Contains(caps.Message.Text, "Suggested: CapabilityBoundingSet=~CAP_SYS_ADMIN") {
+		t.Fatalf("CapabilityBoundingSet_CAP_SYS_ADMIN = %#v, want a suggestion without a fix", caps)
+	}
+
+	// A list the unit doesn't set yet goes in after the [Service] header.
+	filter := results["systemd.SystemCallFilter_mount"]
+	if len(filter.Fixes) != 1 {
+		t.Fatalf("SystemCallFilter_mount fixes = %#v, want one", filter.Fixes)
+	}
+	if rep := filter.Fixes[0].ArtifactChanges[0].Replacements[0]; rep.DeletedRegion.StartLine != 2 || rep.InsertedContent.Text != "SystemCallFilter=~@mount\n" {
+		t.Fatalf("SystemCallFilter_mount fix = %#v", rep)
+	}
+}
+
+func TestScanJUnitReport(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/bin/true\nPrivateNetwork=no\n")
+	mustWrite(t, filepath.Join(repo, "deploy/legacy.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy/hardened.service"), "[Service]\nExecStart=/bin/true\nDynamicUser=yes\nPrivateNetwork=yes\nProtectSystem=strict\nProtectHome=yes\nPrivateDevices=yes\nCapabilityBoundingSet=\n")
+	mustWrite(t, filepath.Join(repo, "allow.json"), `{
+  "allowUnits": [
+    { "unit": "legacy.service", "ticket": "OPS-7" }
+  ]
+}`)
+	junitReport := filepath.Join(t.TempDir(), "ssg-junit.xml")
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/*.service",
+		"--threshold", "6.0",
+		"--backend", "native",
+		"--allowlist", "allow.json",
+		"--top", "2",
+		"--junit-report", junitReport,
+	}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstderr:\n%s", code, stderr.String())
+	}
+
+	b, err := os.ReadFile(junitReport)
+	if err != nil {
+		t.Fatalf("read junit report: %v", err)
+	}
+	var r junit.TestSuites
+	if err := xml.Unmarshal(b, &r); err != nil {
+		t.Fatalf("parse junit report: %v\n%s", err, b)
+	}
+	if r.Tests != 3 || r.Failures != 1 || r.Skipped != 1 || r.Errors != 0 || len(r.Suites) != 1 {
+		t.Fatalf("testsuites = %d tests, %d failures, %d skipped, %d errors; want 3, 1, 1, 0\n%s", r.Tests, r.Failures, r.Skipped, r.Errors, b)
+	}
+	cases := map[string]junit.TestCase{}
+	for _, tc := range r.Suites[0].Cases {
+		cases[tc.Name] = tc
+	}
+
+	myapp := cases["myapp.service"]
+	if myapp.File != "deploy/myapp.service" || myapp.Failure == nil || myapp.Skipped != nil {
+		t.Fatalf("myapp.service = %#v, want a failure", myapp)
+	}
+	if myapp.Failure.Type != "ThresholdExceeded" || myapp.Failure.Message != "overall exposure 9.60 exceeds threshold 6.00" {
+		t.Fatalf("myapp.service failure = %q (%s)", myapp.Failure.Message, myapp.Failure.Type)
+	}
+	if want := "PrivateNetwork exposure=0.50: Service has access to the host's network\nUserOrDynamicUser exposure=0.40: Service runs as root user\n"; myapp.Failure.Text != want {
+		t.Fatalf("myapp.service failure text = %q, want %q", myapp.Failure.Text, want)
+	}
+
+	legacy := cases["legacy.service"]
+	if legacy.Failure != nil || legacy.Skipped == nil || legacy.Skipped.Message != "allowed by allowlist: legacy.service (ticket: OPS-7)" {
+		t.Fatalf("legacy.service = %#v, want skipped by its allowlist entry", legacy)
+	}
+
+	hardened := cases["hardened.service"]
+	if hardened.File != "deploy/hardened.service" || hardened.Failure != nil || hardened.Skipped != nil || hardened.Error != nil {
+		t.Fatalf("hardened.service = %#v, want a passing test case", hardened)
+	}
+}
+
+func TestScanGitlabSastReport(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "gl-sast-report.json")
+	runCodeQualityScan(t, "--gitlab-sast-report", report)
+
+	var r gitlabsast.Report
+	mustReadJSON(t, report, &r)
+	if r.Scan.Type != "sast" || r.Scan.Status != "success" || r.Scan.Analyzer.Version != version.String() {
+		t.Fatalf("scan = %#v", r.Scan)
+	}
+	// Every exposed check of the failing unit is reported, not just the
+	// top 3, and nothing of the passing one.
+	if len(r.Vulnerabilities) <= 3 {
+		t.Fatalf("vulnerabilities = %d, want every exposed check", len(r.Vulnerabilities))
+	}
+	got := map[string]gitlabsast.Vulnerability{}
+	for _, v := range r.Vulnerabilities {
+		if v.Location.File != "deploy/myapp.service" {
+			t.Fatalf("vulnerability for %s, want only deploy/myapp.service", v.Location.File)
+		}
+		got[v.Identifiers[0].Value] = v
+	}
+	for id, want := range map[string]struct {
+		severity string
+		line     int
+	}{
+		"systemd.PrivateNetwork": {"High", 3},
+		"systemd.ProtectClock":   {"Medium", 1},
+		"systemd.UMask":          {"Low", 1},
+	} {
+		v, ok := got[id]
+		if !ok {
+			t.Fatalf("no vulnerability for %s", id)
+		}
+		fp := fingerprint(id, "deploy/myapp.service", "myapp.service")
+		wantID := fp[0:8] + "-" + fp[8:12] + "-" + fp[12:16] + "-" + fp[16:20] + "-" + fp[20:32]
+		if v.ID != wantID || v.Severity != want.severity || v.Location.StartLine != want.line {
+			t.Fatalf("%s = id %s, severity %s, line %d; want %s, %s, %d", id, v.ID, v.Severity, v.Location.StartLine, wantID, want.severity, want.line)
+		}
+	}
+}
+
+func TestScanCodeClimateReport(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "gl-code-quality-report.json")
+	runCodeQualityScan(t, "--codeclimate-report", report)
+
+	var issues []codeclimate.Issue
+	mustReadJSON(t, report, &issues)
+	if len(issues) <= 3 {
+		t.Fatalf("issues = %d, want every exposed check", len(issues))
+	}
+	got := map[string]codeclimate.Issue{}
+	for _, is := range issues {
+		if is.Location.Path != "deploy/myapp.service" {
+			t.Fatalf("issue for %s, want only deploy/myapp.service", is.Location.Path)
+		}
+		got[is.CheckName] = is
+	}
+	for id, want := range map[string]struct {
+		severity string
+		line     int
+	}{
+		"systemd.PrivateNetwork": {"critical", 3},
+		"systemd.ProtectClock":   {"major", 1},
+		"systemd.UMask":          {"minor", 1},
+	} {
+		is, ok := got[id]
+		if !ok {
+			t.Fatalf("no issue for %s", id)
+		}
+		fp := fingerprint(id, "deploy/myapp.service", "myapp.service")
+		if is.Fingerprint != fp || is.Severity != want.severity || is.Location.Lines.Begin != want.line {
+			t.Fatalf("%s = fingerprint %s, severity %s, line %d; want %s, %s, %d", id, is.Fingerprint, is.Severity, is.Location.Lines.Begin, fp, want.severity, want.line)
+		}
+	}
+}
+
+// runCodeQualityScan scans a failing and a passing unit with the native
+// backend and --top 3, writing the given report.
+func runCodeQualityScan(t *testing.T, args ...string) {
+	t.Helper()
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/myapp.service"), "[Service]\nExecStart=/bin/true\nPrivateNetwork=no\n")
+	mustWrite(t, filepath.Join(repo, "deploy/hardened.service"), "[Service]\nExecStart=/bin/true\nDynamicUser=yes\nPrivateNetwork=yes\nProtectSystem=strict\nProtectHome=yes\nPrivateDevices=yes\nCapabilityBoundingSet=\n")
+
+	var stdout, stderr bytes.Buffer
+	code := Run(append([]string{
+		"ssg", "scan",
+		"--repo-root", repo,
+		"--paths", "deploy/*.service",
+		"--threshold", "6.0",
+		"--backend", "native",
+		"--top", "3",
+	}, args...), &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("exit code = %d, want 1\nstderr:\n%s", code, stderr.String())
+	}
+}
+
+// fingerprint is the expected fingerprint of an exposed check's finding.
+func fingerprint(ruleID, repoRelPath, unitName string) string {
+	sum := sha256.Sum256([]byte(ruleID + "\x00" + repoRelPath + "\x00" + unitName))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestScanRejectsUnknownBackend(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{
+		"ssg", "scan",
+		"--paths", "*.service",
+		"--threshold", "5",
+		"--backend", "magic",
+	}, &stdout, &stderr)
+	if code != 2 || !strings.Contains(stderr.String(), "--backend") {
+		t.Fatalf("exit code = %d, stderr = %q; want 2 and a --backend error", code, stderr.String())
+	}
+}
+
+func TestParseInstances(t *testing.T) {
+	got, err := parseInstances([]string{"a@.service=x, y", "b@.service=z"})
+	if err != nil {
+		t.Fatalf("parseInstances() error = %v", err)
+	}
+	if len(got["a@.service"]) != 2 || got["a@.service"][1] != "y" || len(got["b@.service"]) != 1 {
+		t.Fatalf("parseInstances() = %#v", got)
+	}
+
+	for _, bad := range []string{"a.service=x", "a@x.service=y", "a@.service=", "a@.service"} {
+		if _, err := parseInstances([]string{bad}); err == nil {
+			t.Fatalf("parseInstances(%q) error = nil, want error", bad)
+		}
+	}
+}
+
 type stubOptions struct {
 	exposure    float64
 	rating      string
@@ -213,7 +1095,7 @@ func writeSystemdAnalyzeStub(t *testing.T, repoRoot string, opts stubOptions) st
 	}
 
 	overallBlock := `echo "→ Overall exposure level for $unit: ` + floatStr(exposure) + ` ` + rating + ` 🙂"
-awk -v e="` + floatStr(exposure) + `" -v t="$threshold" 'BEGIN{exit (e>t)?1:0}'
+awk -v e="` + floatStr(exposure) + `" -v t="$threshold" 'BEGIN{exit (e*10>t)?1:0}'
 exit $?`
 	if opts.failOverall {
 		overallBlock = `echo "boom" >&2
@@ -0,0 +1,61 @@
+package codeclimate
+
+import (
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+// Code Climate issues, as read by GitLab's Code Quality report
+// (artifacts:reports:codequality).
+
+type Issue struct {
+	Type        string   `json:"type"`
+	CheckName   string   `json:"check_name"`
+	Description string   `json:"description"`
+	Categories  []string `json:"categories"`
+	Severity    string   `json:"severity"`
+	Fingerprint string   `json:"fingerprint"`
+	Location    Location `json:"location"`
+}
+
+type Location struct {
+	Path  string `json:"path"`
+	Lines Lines  `json:"lines"`
+}
+
+type Lines struct {
+	Begin int `json:"begin"`
+}
+
+// FromScanReport returns one issue per exposed check of each failing unit,
+// and per expired exception. The report is a JSON array, so an empty scan
+// yields an empty (non-nil) slice.
+func FromScanReport(scan model.ScanReport) []Issue {
+	issues := []Issue{}
+	for _, f := range model.FailingFindings(scan) {
+		line := f.Location.Line
+		if line == 0 {
+			line = 1
+		}
+		issues = append(issues, Issue{
+			Type:        "issue",
+			CheckName:   f.RuleID,
+			Description: f.Unit.UnitName + ": " + f.Message,
+			Categories:  []string{"Security"},
+			Severity:    severities[f.Severity()],
+			Fingerprint: f.Fingerprint(),
+			Location: Location{
+				Path:  f.Location.Path,
+				Lines: Lines{Begin: line},
+			},
+		})
+	}
+	return issues
+}
+
+// severities maps finding severities to Code Climate's
+// info/minor/major/critical/blocker scale.
+var severities = map[string]string{
+	model.SeverityHigh:   "critical",
+	model.SeverityMedium: "major",
+	model.SeverityLow:    "minor",
+}
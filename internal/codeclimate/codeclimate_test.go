@@ -0,0 +1,48 @@
+package codeclimate
+
+import (
+	"testing"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+func TestFromScanReport(t *testing.T) {
+	unit := model.UnitReport{
+		UnitName:          "a.service",
+		RepoRelPath:       "deploy/a.service",
+		ThresholdExceeded: true,
+		Checks: []model.SecurityCheck{
+			{JSONField: "ProtectHome", Exposure: 0.1, Description: "home"},
+			{JSONField: "PrivateNetwork", Exposure: 0.3, Description: "net"},
+			{JSONField: "ProtectSystem", Set: true},
+		},
+	}
+	// Issues outside the top ones are reported too, or they would look fixed.
+	unit.TopIssues = model.TopIssues(unit.Checks, 1)
+	issues := FromScanReport(model.ScanReport{Units: []model.UnitReport{unit, {UnitName: "ok.service"}}})
+	if len(issues) != 2 {
+		t.Fatalf("issues len = %d, want 2", len(issues))
+	}
+	if issues[0].CheckName != "systemd.PrivateNetwork" || issues[0].Severity != "major" || issues[0].Location.Path != "deploy/a.service" {
+		t.Fatalf("unexpected issue: %#v", issues[0])
+	}
+	if issues[1].Severity != "minor" {
+		t.Fatalf("severity = %q, want minor", issues[1].Severity)
+	}
+
+	// Fingerprints survive exposure changes so GitLab tracks the finding.
+	unit.Checks[1].Exposure = 0.9
+	again := FromScanReport(model.ScanReport{Units: []model.UnitReport{unit}})
+	if again[0].Fingerprint != issues[0].Fingerprint {
+		t.Fatalf("fingerprint changed: %s != %s", again[0].Fingerprint, issues[0].Fingerprint)
+	}
+	if issues[0].Fingerprint == issues[1].Fingerprint {
+		t.Fatalf("fingerprints collide: %s", issues[0].Fingerprint)
+	}
+}
+
+func TestFromScanReportEmpty(t *testing.T) {
+	if issues := FromScanReport(model.ScanReport{}); issues == nil || len(issues) != 0 {
+		t.Fatalf("issues = %#v, want empty slice", issues)
+	}
+}
@@ -0,0 +1,233 @@
+// Package config loads the repo config file (.ssg.yaml), which sets scan
+// defaults (unit globs, allowlist, template instances, threshold or maximum
+// rating, policy, mode, required checks) and per-unit overrides of the
+// threshold, rating, policy, mode and required checks.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+
+	"github.com/teunlao/systemd-security-gate/internal/nativeanalyze"
+)
+
+// DefaultPath is loaded from the repo root when --config is not given.
+const DefaultPath = ".ssg.yaml"
+
+type Config struct {
+	// Paths and Allowlist apply when --paths and --allowlist are not given.
+	Paths     []string `yaml:"paths"`
+	Allowlist string   `yaml:"allowlist"`
+	Threshold *float64 `yaml:"threshold"`
+	// MaxRating gates on systemd's rating (e.g. MEDIUM) instead of Threshold.
+	MaxRating string `yaml:"maxRating"`
+	Policy    string `yaml:"policy"`
+	Mode      string `yaml:"mode"`
+	// RequiredChecks are checks (by json_field) that must have zero exposure,
+	// whatever the unit's overall score.
+	RequiredChecks []string `yaml:"requiredChecks"`
+	// Instances maps template units ("worker@.service") to the instance
+	// names to analyze them as.
+	Instances map[string][]string `yaml:"instances"`
+	Units     []Rule              `yaml:"units"`
+}
+
+// Rule overrides settings for units whose repo-relative path or unit name
+// matches one of its globs.
+type Rule struct {
+	Match     []string `yaml:"match"`
+	Threshold *float64 `yaml:"threshold"`
+	MaxRating string   `yaml:"maxRating"`
+	Policy    string   `yaml:"policy"`
+	Mode      string   `yaml:"mode"`
+	// RequiredChecks replaces the default list when set; an empty list
+	// requires nothing.
+	RequiredChecks *[]string `yaml:"requiredChecks"`
+}
+
+// Settings are the effective scan settings for one unit.
+type Settings struct {
+	Threshold float64
+	// MaxRating, if set, replaces Threshold as the gate.
+	MaxRating string
+	Policy    string
+	Mode      string
+	// RequiredChecks are the checks that fail the unit if exposed.
+	RequiredChecks []string
+}
+
+func LoadFile(repoRootAbs string, path string) (Config, error) {
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(repoRootAbs, path)
+	}
+	b, err := os.ReadFile(abs)
+	if err != nil {
+		return Config{}, err
+	}
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := c.validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// validate checks c and puts ratings in systemd's spelling.
+func (c *Config) validate() error {
+	if err := validateSettings(c.Threshold, &c.MaxRating, c.Mode, c.RequiredChecks); err != nil {
+		return err
+	}
+	for _, pattern := range c.Paths {
+		if !doublestar.ValidatePattern(filepath.ToSlash(pattern)) {
+			return fmt.Errorf("paths: invalid glob %q", pattern)
+		}
+	}
+	for template, names := range c.Instances {
+		ext := filepath.Ext(template)
+		if ext == "" || !strings.HasSuffix(strings.TrimSuffix(template, ext), "@") || strings.Contains(template, "/") {
+			return fmt.Errorf("instances: %q is not a template unit name (like worker@.service)", template)
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("instances: no instance names for %s", template)
+		}
+		for _, name := range names {
+			if strings.TrimSpace(name) == "" {
+				return fmt.Errorf("instances: empty instance name for %s", template)
+			}
+		}
+	}
+	for i := range c.Units {
+		r := &c.Units[i]
+		if len(r.Match) == 0 {
+			return fmt.Errorf("units[%d]: match is required", i)
+		}
+		for _, pattern := range r.Match {
+			if !doublestar.ValidatePattern(filepath.ToSlash(pattern)) {
+				return fmt.Errorf("units[%d]: invalid glob %q", i, pattern)
+			}
+		}
+		var required []string
+		if r.RequiredChecks != nil {
+			required = *r.RequiredChecks
+		}
+		if err := validateSettings(r.Threshold, &r.MaxRating, r.Mode, required); err != nil {
+			return fmt.Errorf("units[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateSettings(threshold *float64, maxRating *string, mode string, requiredChecks []string) error {
+	if threshold != nil && *threshold < 0 {
+		return fmt.Errorf("threshold must not be negative")
+	}
+	if *maxRating != "" {
+		if threshold != nil {
+			return fmt.Errorf("threshold and maxRating are mutually exclusive")
+		}
+		rating, err := ParseRating(*maxRating)
+		if err != nil {
+			return fmt.Errorf("maxRating: %w", err)
+		}
+		*maxRating = rating
+	}
+	if mode != "" && mode != "enforce" && mode != "report" {
+		return fmt.Errorf("mode must be one of: enforce, report")
+	}
+	for _, c := range requiredChecks {
+		if strings.TrimSpace(c) == "" {
+			return fmt.Errorf("requiredChecks: empty check")
+		}
+	}
+	return nil
+}
+
+// ParseRating returns rating, one of systemd's ratings in any case, as
+// systemd prints it ("medium" is MEDIUM).
+func ParseRating(rating string) (string, error) {
+	bands, _ := nativeanalyze.BandsFor(nativeanalyze.TargetMajor)
+	rating = strings.ToUpper(strings.TrimSpace(rating))
+	if _, ok := bands.Rank(rating); !ok {
+		return "", fmt.Errorf("must be one of: %s", strings.Join(bands.Names(), ", "))
+	}
+	return rating, nil
+}
+
+// Resolve returns the settings for a unit: the first rule matching the
+// unit's path, name or template name wins, and anything it leaves unset
+// falls back to defaults. A rule's threshold or maxRating replaces both
+// default gates.
+func (c Config) Resolve(defaults Settings, repoRelPath string, unitName string, template string) Settings {
+	keys := []string{filepath.ToSlash(repoRelPath), unitName}
+	if template != "" {
+		keys = append(keys, template)
+	}
+	for _, r := range c.Units {
+		if !r.matches(keys) {
+			continue
+		}
+		s := defaults
+		if r.Threshold != nil {
+			s.Threshold = *r.Threshold
+			s.MaxRating = ""
+		}
+		if r.MaxRating != "" {
+			s.MaxRating = r.MaxRating
+		}
+		if r.Policy != "" {
+			s.Policy = r.Policy
+		}
+		if r.Mode != "" {
+			s.Mode = r.Mode
+		}
+		if r.RequiredChecks != nil {
+			s.RequiredChecks = *r.RequiredChecks
+		}
+		return s
+	}
+	return defaults
+}
+
+// ResolveInstances returns the instance names to analyze each template
+// unit as: the config's, with a template's names from flags replacing those
+// the config lists for it.
+func (c Config) ResolveInstances(flags map[string][]string) map[string][]string {
+	out := map[string][]string{}
+	for template, names := range c.Instances {
+		for _, name := range names {
+			out[template] = append(out[template], strings.TrimSpace(name))
+		}
+	}
+	for template, names := range flags {
+		out[template] = names
+	}
+	return out
+}
+
+func (r Rule) matches(keys []string) bool {
+	for _, pattern := range r.Match {
+		pattern = strings.TrimPrefix(filepath.ToSlash(strings.TrimSpace(pattern)), "./")
+		for _, k := range keys {
+			if k == "" {
+				continue
+			}
+			if ok, _ := doublestar.Match(pattern, k); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
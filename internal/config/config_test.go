@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadFileAndResolve(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), `threshold: 3.0
+policy: .ci/policy.json
+requiredChecks: [PrivateNetwork]
+units:
+  - match: ["./deploy/vendor/**"]
+    threshold: 7.5
+    requiredChecks: []
+  - match: ["deploy/edge/**"]
+    maxRating: MEDIUM
+  - match: ["worker@.service", "*.socket"]
+    mode: report
+  - match: ["**"]
+    threshold: 1
+`)
+
+	c, err := LoadFile(repo, DefaultPath)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if c.Threshold == nil || *c.Threshold != 3 || c.Policy != ".ci/policy.json" || len(c.RequiredChecks) != 1 {
+		t.Fatalf("unexpected defaults: %#v", c)
+	}
+
+	defaults := Settings{Threshold: 3, Policy: ".ci/policy.json", Mode: "enforce", RequiredChecks: c.RequiredChecks}
+	required := []string{"PrivateNetwork"}
+	cases := []struct {
+		path, unit, template string
+		want                 Settings
+	}{
+		{"deploy/vendor/x/legacy.service", "legacy.service", "", Settings{7.5, "", ".ci/policy.json", "enforce", []string{}}},
+		{"deploy/edge/proxy.service", "proxy.service", "", Settings{3, "MEDIUM", ".ci/policy.json", "enforce", required}},
+		{"deploy/worker@.service", "worker@a.service", "worker@.service", Settings{3, "", ".ci/policy.json", "report", required}},
+		{"deploy/api.service", "api.service", "", Settings{1, "", ".ci/policy.json", "enforce", required}},
+	}
+	for _, tc := range cases {
+		if got := c.Resolve(defaults, tc.path, tc.unit, tc.template); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Resolve(%s) = %#v, want %#v", tc.unit, got, tc.want)
+		}
+	}
+
+	// A rule's threshold replaces a default maxRating.
+	rated := defaults
+	rated.MaxRating = "OK"
+	if got := c.Resolve(rated, "deploy/api.service", "api.service", ""); got.MaxRating != "" || got.Threshold != 1 {
+		t.Errorf("Resolve(api.service) = %#v, want threshold 1 without maxRating", got)
+	}
+
+	if got := (Config{}).Resolve(defaults, "a.service", "a.service", ""); !reflect.DeepEqual(got, defaults) {
+		t.Errorf("empty config changed defaults: %#v", got)
+	}
+}
+
+func TestLoadFileNormalisesRatings(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), `maxRating: exposed
+units:
+  - match: ["deploy/edge/**"]
+    maxRating: " Medium"
+`)
+
+	c, err := LoadFile(repo, DefaultPath)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if c.MaxRating != "EXPOSED" || c.Units[0].MaxRating != "MEDIUM" {
+		t.Fatalf("ratings = %q, %q; want EXPOSED, MEDIUM", c.MaxRating, c.Units[0].MaxRating)
+	}
+	got := c.Resolve(Settings{MaxRating: c.MaxRating}, "deploy/edge/a.service", "a.service", "")
+	if got.MaxRating != "MEDIUM" {
+		t.Fatalf("Resolve() = %#v, want MEDIUM", got)
+	}
+
+	for in, want := range map[string]string{"ok": "OK", "UNSAFE": "UNSAFE", "Dangerous": "DANGEROUS"} {
+		if got, err := ParseRating(in); err != nil || got != want {
+			t.Fatalf("ParseRating(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseRating("fine"); err == nil || !strings.Contains(err.Error(), "PERFECT") {
+		t.Fatalf("ParseRating(fine) error = %v, want the list of ratings", err)
+	}
+}
+
+func TestResolveInstances(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, ".ssg.yaml"), `instances:
+  worker@.service: [a, " b "]
+  job@.timer: [nightly]
+`)
+
+	c, err := LoadFile(repo, DefaultPath)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	want := map[string][]string{"worker@.service": {"a", "b"}, "job@.timer": {"nightly"}}
+	if got := c.ResolveInstances(nil); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ResolveInstances(nil) = %v, want %v", got, want)
+	}
+
+	// Flags replace the config's names for their template only.
+	got := c.ResolveInstances(map[string][]string{"worker@.service": {"c"}, "web@.service": {"x"}})
+	want = map[string][]string{"worker@.service": {"c"}, "job@.timer": {"nightly"}, "web@.service": {"x"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ResolveInstances(flags) = %v, want %v", got, want)
+	}
+
+	if got := (Config{}).ResolveInstances(nil); len(got) != 0 {
+		t.Fatalf("empty config instances = %v", got)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	repo := t.TempDir()
+	cases := map[string]string{
+		"unknown key":    "treshold: 3\n",
+		"no match":       "units:\n  - threshold: 3\n",
+		"bad glob":       "units:\n  - match: [\"deploy/[\"]\n",
+		"bad mode":       "mode: strict\n",
+		"negative":       "units:\n  - match: [\"*\"]\n    threshold: -1\n",
+		"wrong type":     "threshold: high\n",
+		"match not list": "units:\n  - match: \"*.service\"\n",
+		"empty required": "requiredChecks: [\"\"]\n",
+		"bad rating":     "maxRating: fine\n",
+		"both gates":     "units:\n  - match: [\"*\"]\n    threshold: 3\n    maxRating: OK\n",
+		"not a template": "instances:\n  worker.service: [a]\n",
+		"no instances":   "instances:\n  worker@.service: []\n",
+		"empty instance": "instances:\n  worker@.service: [\" \"]\n",
+	}
+	for name, content := range cases {
+		mustWrite(t, filepath.Join(repo, "c.yaml"), content)
+		if _, err := LoadFile(repo, "c.yaml"); err == nil || !strings.Contains(err.Error(), "c.yaml") {
+			t.Errorf("%s: expected error mentioning the file, got %v", name, err)
+		}
+	}
+
+	mustWrite(t, filepath.Join(repo, "empty.yaml"), "")
+	if _, err := LoadFile(repo, "empty.yaml"); err != nil {
+		t.Errorf("empty config: %v", err)
+	}
+	if _, err := LoadFile(repo, "missing.yaml"); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func mustWrite(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
@@ -10,7 +10,13 @@ import (
 	"github.com/bmatcuk/doublestar/v4"
 )
 
-func ServiceUnits(repoRootAbs string, includeGlobs []string, excludeGlobs []string) ([]string, error) {
+// UnitSuffixes are the unit types the gate understands: services are
+// analyzed directly, the others are mapped to the service they activate.
+var UnitSuffixes = []string{".service", ".socket", ".timer", ".path"}
+
+// Units returns the repo-relative paths of all supported unit files matching
+// includeGlobs and not matching excludeGlobs.
+func Units(repoRootAbs string, includeGlobs []string, excludeGlobs []string) ([]string, error) {
 	repoFS := os.DirFS(repoRootAbs)
 
 	var matches []string
@@ -28,7 +34,7 @@ func ServiceUnits(repoRootAbs string, includeGlobs []string, excludeGlobs []stri
 			return nil, fmt.Errorf("glob %q: %w", pattern, err)
 		}
 		for _, f := range files {
-			if !strings.HasSuffix(f, ".service") {
+			if !hasSuffix(f, UnitSuffixes) {
 				continue
 			}
 			rel := filepath.Clean(f)
@@ -50,6 +56,15 @@ func ServiceUnits(repoRootAbs string, includeGlobs []string, excludeGlobs []stri
 	return matches, nil
 }
 
+func hasSuffix(path string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 func isExcluded(path string, excludeGlobs []string) bool {
 	for _, pattern := range excludeGlobs {
 		pattern = strings.TrimSpace(pattern)
@@ -7,52 +7,75 @@ import (
 	"testing"
 )
 
-func TestServiceUnits(t *testing.T) {
+func TestUnits(t *testing.T) {
 	repo := t.TempDir()
 	mustWrite(t, filepath.Join(repo, "deploy/systemd/a.service"), "[Service]\nExecStart=/bin/true\n")
 	mustWrite(t, filepath.Join(repo, "deploy/systemd/b.socket"), "[Socket]\nListenStream=1234\n")
 	mustWrite(t, filepath.Join(repo, "deploy/systemd/nested/c.service"), "[Service]\nExecStart=/bin/true\n")
 
-	got, err := ServiceUnits(repo, []string{"deploy/systemd/**/*.service"}, nil)
+	got, err := Units(repo, []string{"deploy/systemd/**/*.service"}, nil)
 	if err != nil {
-		t.Fatalf("ServiceUnits() error = %v", err)
+		t.Fatalf("Units() error = %v", err)
 	}
 	want := []string{
 		"deploy/systemd/a.service",
 		"deploy/systemd/nested/c.service",
 	}
 	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("ServiceUnits() = %#v, want %#v", got, want)
+		t.Fatalf("Units() = %#v, want %#v", got, want)
 	}
 }
 
-func TestServiceUnitsExclude(t *testing.T) {
+func TestUnitsExclude(t *testing.T) {
 	repo := t.TempDir()
 	mustWrite(t, filepath.Join(repo, "deploy/systemd/a.service"), "[Service]\n")
 	mustWrite(t, filepath.Join(repo, "deploy/systemd/skip.service"), "[Service]\n")
 
-	got, err := ServiceUnits(repo, []string{"deploy/systemd/*.service"}, []string{"**/skip.service"})
+	got, err := Units(repo, []string{"deploy/systemd/*.service"}, []string{"**/skip.service"})
 	if err != nil {
-		t.Fatalf("ServiceUnits() error = %v", err)
+		t.Fatalf("Units() error = %v", err)
 	}
 	want := []string{"deploy/systemd/a.service"}
 	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("ServiceUnits() = %#v, want %#v", got, want)
+		t.Fatalf("Units() = %#v, want %#v", got, want)
 	}
 }
 
-func TestServiceUnitsNormalizesPatterns(t *testing.T) {
+func TestUnitsNormalizesPatterns(t *testing.T) {
 	repo := t.TempDir()
 	mustWrite(t, filepath.Join(repo, "deploy/systemd/a.service"), "[Service]\n")
 	mustWrite(t, filepath.Join(repo, "deploy/systemd/b.service"), "[Service]\n")
 
-	got, err := ServiceUnits(repo, []string{"./deploy/systemd/*.service"}, []string{"./deploy/systemd/b.service"})
+	got, err := Units(repo, []string{"./deploy/systemd/*.service"}, []string{"./deploy/systemd/b.service"})
 	if err != nil {
-		t.Fatalf("ServiceUnits() error = %v", err)
+		t.Fatalf("Units() error = %v", err)
 	}
 	want := []string{"deploy/systemd/a.service"}
 	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("ServiceUnits() = %#v, want %#v", got, want)
+		t.Fatalf("Units() = %#v, want %#v", got, want)
+	}
+}
+
+func TestUnitsIncludesActivators(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/a.service"), "[Service]\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/a.socket"), "[Socket]\nListenStream=1234\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/b.timer"), "[Timer]\nOnCalendar=daily\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/c.path"), "[Path]\nPathExists=/tmp/x\n")
+	mustWrite(t, filepath.Join(repo, "deploy/systemd/d.target"), "[Unit]\n")
+
+	got, err := Units(repo, []string{"deploy/systemd/*"}, nil)
+	if err != nil {
+		t.Fatalf("Units() error = %v", err)
+	}
+	want := []string{
+		"deploy/systemd/a.service",
+		"deploy/systemd/a.socket",
+		"deploy/systemd/b.timer",
+		"deploy/systemd/c.path",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Units() = %#v, want %#v", got, want)
 	}
 }
 
@@ -0,0 +1,120 @@
+package gitlabsast
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/version"
+)
+
+// Minimal GitLab security report (SAST) structures, schema version 15.
+
+const schemaVersion = "15.0.7"
+
+// timeFormat is the schema's start_time/end_time format (UTC, no zone).
+const timeFormat = "2006-01-02T15:04:05"
+
+type Report struct {
+	Version         string          `json:"version"`
+	Scan            Scan            `json:"scan"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+type Scan struct {
+	Analyzer  Tool   `json:"analyzer"`
+	Scanner   Tool   `json:"scanner"`
+	Type      string `json:"type"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Status    string `json:"status"`
+}
+
+type Tool struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Vendor  Vendor `json:"vendor"`
+}
+
+type Vendor struct {
+	Name string `json:"name"`
+}
+
+type Vulnerability struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Severity    string       `json:"severity"`
+	Location    Location     `json:"location"`
+	Identifiers []Identifier `json:"identifiers"`
+}
+
+type Location struct {
+	File      string `json:"file"`
+	StartLine int    `json:"start_line,omitempty"`
+}
+
+type Identifier struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// FromScanReport returns a SAST report with one vulnerability per exposed
+// check of each failing unit, and per expired exception. Vulnerability IDs
+// derive from the finding fingerprint, so GitLab matches them across
+// pipelines. The scan status is "failure" if any unit failed analysis.
+func FromScanReport(scan model.ScanReport, start, end time.Time) Report {
+	tool := Tool{
+		ID:      "systemd-security-gate",
+		Name:    "systemd-security-gate",
+		Version: version.String(),
+		Vendor:  Vendor{Name: "systemd-security-gate"},
+	}
+
+	status := "success"
+	for _, u := range scan.Units {
+		if u.Error != "" {
+			status = "failure"
+		}
+	}
+
+	vulns := []Vulnerability{}
+	for _, f := range model.FailingFindings(scan) {
+		vulns = append(vulns, Vulnerability{
+			ID:          uuid(f.Fingerprint()),
+			Name:        fmt.Sprintf("%s in %s", f.RuleName, f.Unit.UnitName),
+			Description: f.Message,
+			Severity:    severities[f.Severity()],
+			Location:    Location{File: f.Location.Path, StartLine: f.Location.Line},
+			Identifiers: []Identifier{{Type: "ssg_rule", Name: f.RuleName, Value: f.RuleID}},
+		})
+	}
+
+	return Report{
+		Version: schemaVersion,
+		Scan: Scan{
+			Analyzer:  tool,
+			Scanner:   tool,
+			Type:      "sast",
+			StartTime: start.UTC().Format(timeFormat),
+			EndTime:   end.UTC().Format(timeFormat),
+			Status:    status,
+		},
+		Vulnerabilities: vulns,
+	}
+}
+
+// severities maps finding severities to GitLab's.
+var severities = map[string]string{
+	model.SeverityHigh:   "High",
+	model.SeverityMedium: "Medium",
+	model.SeverityLow:    "Low",
+}
+
+// uuid formats the first 128 bits of a hex fingerprint as a UUID.
+func uuid(fingerprint string) string {
+	h := fingerprint[:32]
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
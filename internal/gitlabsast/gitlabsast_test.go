@@ -0,0 +1,53 @@
+package gitlabsast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/version"
+)
+
+func TestFromScanReport(t *testing.T) {
+	scan := model.ScanReport{
+		SystemdVersion: "systemd 252",
+		Units: []model.UnitReport{
+			{
+				UnitName:          "a.service",
+				RepoRelPath:       "deploy/a.service",
+				ThresholdExceeded: true,
+				ExceptionExpired:  true,
+				Exceptions:        []model.Exception{{Unit: "a.service", Expires: "2026-01-31"}},
+				TopIssues:         []model.SecurityCheck{{JSONField: "ProtectSystem", Exposure: 0.2}},
+				Checks:            []model.SecurityCheck{{JSONField: "ProtectSystem", Exposure: 0.2}, {JSONField: "ProtectHome", Exposure: 0.1}},
+			},
+			{UnitName: "b.service", RepoRelPath: "deploy/b.service", Error: "boom"},
+		},
+	}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	r := FromScanReport(scan, start, start.Add(time.Minute))
+	if r.Scan.StartTime != "2026-03-01T12:00:00" || r.Scan.EndTime != "2026-03-01T12:01:00" {
+		t.Fatalf("unexpected times: %s %s", r.Scan.StartTime, r.Scan.EndTime)
+	}
+	if r.Scan.Status != "failure" {
+		t.Fatalf("status = %q, want failure", r.Scan.Status)
+	}
+	if r.Scan.Analyzer.Version != version.String() || r.Scan.Scanner.Version == "systemd 252" {
+		t.Fatalf("tool = %#v, want ssg's version", r.Scan.Analyzer)
+	}
+	// Every issue of the failing unit, not only its top ones.
+	if len(r.Vulnerabilities) != 3 || r.Vulnerabilities[2].Identifiers[0].Name != "ProtectHome" {
+		t.Fatalf("vulnerabilities = %#v, want the expired exception and both issues", r.Vulnerabilities)
+	}
+	expired, issue := r.Vulnerabilities[0], r.Vulnerabilities[1]
+	if expired.Severity != "High" || expired.Identifiers[0].Value != "ssg.expired-exception" {
+		t.Fatalf("unexpected expired exception: %#v", expired)
+	}
+	if issue.Severity != "Medium" || issue.Location.File != "deploy/a.service" || len(issue.ID) != 36 {
+		t.Fatalf("unexpected vulnerability: %#v", issue)
+	}
+	if again := FromScanReport(scan, start, start); again.Vulnerabilities[1].ID != issue.ID {
+		t.Fatalf("id not stable: %s != %s", again.Vulnerabilities[1].ID, issue.ID)
+	}
+}
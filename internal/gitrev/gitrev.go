@@ -0,0 +1,164 @@
+// Package gitrev reads unit files at another revision straight from the
+// local git object store, without touching the working tree.
+package gitrev
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/discover"
+)
+
+// MergeBase resolves the merge base of ref and HEAD for the repository
+// containing dir.
+func MergeBase(dir string, ref string) (string, error) {
+	out, err := git(dir, "merge-base", ref, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("merge base of %s and HEAD: %w", ref, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Materialize copies the unit files and unit drop-in directories of commit
+// into a new temporary directory and returns it. Paths are relative to dir,
+// which may be a subdirectory of the repository.
+func Materialize(dir string, commit string) (string, error) {
+	out, err := git(dir, "ls-tree", "-r", "-z", "--name-only", commit)
+	if err != nil {
+		return "", fmt.Errorf("list files at %s: %w", commit, err)
+	}
+	var paths []string
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" && IsUnitPath(p) {
+			paths = append(paths, p)
+		}
+	}
+
+	tree, err := os.MkdirTemp("", "ssg-base-*")
+	if err != nil {
+		return "", fmt.Errorf("mkdtemp: %w", err)
+	}
+	if len(paths) == 0 {
+		return tree, nil
+	}
+
+	// The names are matched literally, not as globs or pathspec magic, and
+	// passed in batches to stay below the argument size limit.
+	for _, batch := range batches(paths, archiveBatchBytes) {
+		archive, err := git(dir, append([]string{"--literal-pathspecs", "archive", "--format=tar", commit, "--"}, batch...)...)
+		if err != nil {
+			_ = os.RemoveAll(tree)
+			return "", fmt.Errorf("archive %s: %w", commit, err)
+		}
+		if err := extract(tree, bytes.NewReader(archive)); err != nil {
+			_ = os.RemoveAll(tree)
+			return "", fmt.Errorf("extract %s: %w", commit, err)
+		}
+	}
+	return tree, nil
+}
+
+// archiveBatchBytes bounds the paths passed to one "git archive", well
+// below ARG_MAX on every platform.
+const archiveBatchBytes = 64 << 10
+
+// batches splits paths into runs of at most limit bytes, counting a
+// terminator per path. A longer path gets a batch of its own.
+func batches(paths []string, limit int) [][]string {
+	var out [][]string
+	var cur []string
+	size := 0
+	for _, p := range paths {
+		if len(cur) > 0 && size+len(p)+1 > limit {
+			out = append(out, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, p)
+		size += len(p) + 1
+	}
+	if len(cur) > 0 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+// IsUnitPath reports whether p is a unit file or lives in a unit's ".d"
+// drop-in directory.
+func IsUnitPath(p string) bool {
+	parts := strings.Split(filepath.ToSlash(p), "/")
+	for i, part := range parts {
+		for _, suffix := range discover.UnitSuffixes {
+			if i == len(parts)-1 && strings.HasSuffix(part, suffix) {
+				return true
+			}
+			if i < len(parts)-1 && strings.HasSuffix(part, suffix+".d") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func extract(dest string, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("unexpected path %q", hdr.Name)
+		}
+		target := filepath.Join(dest, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			b, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(target, b, 0o644); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "LANG=C")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,131 @@
+package gitrev
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsUnitPath(t *testing.T) {
+	cases := map[string]bool{
+		"deploy/a.service":                 true,
+		"a.socket":                         true,
+		"deploy/a.service.d/override.conf": true,
+		"deploy/worker@.service.d/x.conf":  true,
+		"deploy/a.service.d":               false,
+		"README.md":                        false,
+		"deploy/a.service.bak":             false,
+		"deploy/policy.json":               false,
+	}
+	for p, want := range cases {
+		if got := IsUnitPath(p); got != want {
+			t.Errorf("IsUnitPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestMaterializeMergeBase(t *testing.T) {
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "-q", "-b", "main")
+	mustWrite(t, filepath.Join(repo, "svc/deploy/a.service"), "[Service]\nExecStart=/bin/a\n")
+	mustWrite(t, filepath.Join(repo, "svc/deploy/a.service.d/override.conf"), "[Service]\nPrivateTmp=yes\n")
+	mustWrite(t, filepath.Join(repo, "svc/README.md"), "docs\n")
+	if err := os.Symlink("a.service", filepath.Join(repo, "svc/deploy/alias.service")); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, repo, "add", "-A")
+	gitRun(t, repo, "commit", "-q", "-m", "base")
+
+	gitRun(t, repo, "checkout", "-q", "-b", "feature")
+	mustWrite(t, filepath.Join(repo, "svc/deploy/a.service"), "[Service]\nExecStart=/bin/changed\n")
+	gitRun(t, repo, "commit", "-q", "-am", "change")
+
+	dir := filepath.Join(repo, "svc")
+	commit, err := MergeBase(dir, "main")
+	if err != nil {
+		t.Fatalf("MergeBase: %v", err)
+	}
+	tree, err := Materialize(dir, commit)
+	if err != nil {
+		t.Fatalf("Materialize: %v", err)
+	}
+	defer os.RemoveAll(tree)
+
+	if b, err := os.ReadFile(filepath.Join(tree, "deploy/a.service")); err != nil || string(b) != "[Service]\nExecStart=/bin/a\n" {
+		t.Fatalf("a.service at base = %q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(tree, "deploy/a.service.d/override.conf")); err != nil {
+		t.Fatalf("expected drop-in: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(tree, "deploy/alias.service")); err != nil || target != "a.service" {
+		t.Fatalf("alias.service = %q, %v", target, err)
+	}
+	if _, err := os.Stat(filepath.Join(tree, "README.md")); !os.IsNotExist(err) {
+		t.Fatalf("did not expect non-unit files, got %v", err)
+	}
+
+	if _, err := MergeBase(dir, "no-such-ref"); err == nil {
+		t.Fatalf("expected error for unknown ref")
+	}
+}
+
+func TestMaterializeLiteralNames(t *testing.T) {
+	repo := t.TempDir()
+	gitRun(t, repo, "init", "-q", "-b", "main")
+	files := map[string]string{
+		"deploy/[ab].service":       "[Service]\nExecStart=/bin/brackets\n",
+		"deploy/a.service":          "[Service]\nExecStart=/bin/a\n",
+		"deploy/*.service.d/x.conf": "[Service]\nPrivateTmp=yes\n",
+		":!other.service":           "[Service]\nExecStart=/bin/magic\n",
+		"other.service":             "[Service]\nExecStart=/bin/other\n",
+	}
+	for name, content := range files {
+		mustWrite(t, filepath.Join(repo, name), content)
+	}
+	gitRun(t, repo, "add", "-A")
+	gitRun(t, repo, "commit", "-q", "-m", "base")
+
+	tree, err := Materialize(repo, "HEAD")
+	if err != nil {
+		t.Fatalf("Materialize: %v", err)
+	}
+	defer os.RemoveAll(tree)
+
+	for name, content := range files {
+		if b, err := os.ReadFile(filepath.Join(tree, name)); err != nil || string(b) != content {
+			t.Fatalf("%s = %q, %v; want %q", name, b, err, content)
+		}
+	}
+}
+
+func TestBatches(t *testing.T) {
+	paths := []string{"aaaa", "bbbb", "cc", "dddddddd", "e"}
+	got := batches(paths, 10)
+	want := [][]string{{"aaaa", "bbbb"}, {"cc"}, {"dddddddd"}, {"e"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("batches() = %q, want %q", got, want)
+	}
+	if got := batches(nil, 10); len(got) != 0 {
+		t.Fatalf("batches(nil) = %q, want none", got)
+	}
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func mustWrite(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+// Package hardening turns exposed security checks into the [Service]
+// directives that close them, as a drop-in for "ssg fix".
+package hardening
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/unitfile"
+)
+
+// DropInName is the drop-in "ssg fix" writes next to each unit.
+const DropInName = "50-ssg-hardening.conf"
+
+// settings are the directives that close a check outright.
+var settings = map[string]string{
+	"UserOrDynamicUser":       "DynamicUser=yes",
+	"SupplementaryGroups":     "SupplementaryGroups=",
+	"PrivateDevices":          "PrivateDevices=yes",
+	"PrivateMounts":           "PrivateMounts=yes",
+	"PrivateNetwork":          "PrivateNetwork=yes",
+	"PrivateTmp":              "PrivateTmp=yes",
+	"PrivateUsers":            "PrivateUsers=yes",
+	"ProtectControlGroups":    "ProtectControlGroups=yes",
+	"ProtectKernelModules":    "ProtectKernelModules=yes",
+	"ProtectKernelTunables":   "ProtectKernelTunables=yes",
+	"ProtectKernelLogs":       "ProtectKernelLogs=yes",
+	"ProtectClock":            "ProtectClock=yes",
+	"ProtectHome":             "ProtectHome=yes",
+	"ProtectHostname":         "ProtectHostname=yes",
+	"ProtectSystem":           "ProtectSystem=strict",
+	"LockPersonality":         "LockPersonality=yes",
+	"MemoryDenyWriteExecute":  "MemoryDenyWriteExecute=yes",
+	"NoNewPrivileges":         "NoNewPrivileges=yes",
+	"UMask":                   "UMask=0077",
+	"KeyringMode":             "KeyringMode=private",
+	"ProtectProc":             "ProtectProc=invisible",
+	"ProcSubset":              "ProcSubset=pid",
+	"NotifyAccess":            "NotifyAccess=main",
+	"RemoveIPC":               "RemoveIPC=yes",
+	"Delegate":                "Delegate=no",
+	"RestrictRealtime":        "RestrictRealtime=yes",
+	"RestrictSUIDSGID":        "RestrictSUIDSGID=yes",
+	"SystemCallArchitectures": "SystemCallArchitectures=native",
+	"IPAddressDeny":           "IPAddressDeny=any",
+	"DeviceAllow":             "DevicePolicy=closed",
+	"AmbientCapabilities":     "AmbientCapabilities=",
+}
+
+// capabilities are the capabilities behind each CapabilityBoundingSet check.
+var capabilities = map[string][]string{
+	"CapabilityBoundingSet_CAP_SYS_ADMIN":                       {"CAP_SYS_ADMIN"},
+	"CapabilityBoundingSet_CAP_SET_UID_GID_PCAP":                {"CAP_SETUID", "CAP_SETGID", "CAP_SETPCAP"},
+	"CapabilityBoundingSet_CAP_SYS_PTRACE":                      {"CAP_SYS_PTRACE"},
+	"CapabilityBoundingSet_CAP_SYS_TIME":                        {"CAP_SYS_TIME"},
+	"CapabilityBoundingSet_CAP_NET_ADMIN":                       {"CAP_NET_ADMIN"},
+	"CapabilityBoundingSet_CAP_SYS_RAWIO":                       {"CAP_SYS_RAWIO"},
+	"CapabilityBoundingSet_CAP_SYS_MODULE":                      {"CAP_SYS_MODULE"},
+	"CapabilityBoundingSet_CAP_AUDIT":                           {"CAP_AUDIT_CONTROL", "CAP_AUDIT_READ", "CAP_AUDIT_WRITE"},
+	"CapabilityBoundingSet_CAP_SYSLOG":                          {"CAP_SYSLOG"},
+	"CapabilityBoundingSet_CAP_SYS_NICE_RESOURCE":               {"CAP_SYS_NICE", "CAP_SYS_RESOURCE"},
+	"CapabilityBoundingSet_CAP_MKNOD":                           {"CAP_MKNOD"},
+	"CapabilityBoundingSet_CAP_CHOWN_FSETID_SETFCAP":            {"CAP_CHOWN", "CAP_FSETID", "CAP_SETFCAP"},
+	"CapabilityBoundingSet_CAP_DAC_FOWNER_IPC_OWNER":            {"CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER", "CAP_IPC_OWNER"},
+	"CapabilityBoundingSet_CAP_KILL":                            {"CAP_KILL"},
+	"CapabilityBoundingSet_CAP_NET_BIND_SERVICE_BROADCAST_RAW)": {"CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST", "CAP_NET_RAW"},
+	"CapabilityBoundingSet_CAP_SYS_BOOT":                        {"CAP_SYS_BOOT"},
+	"CapabilityBoundingSet_CAP_MAC":                             {"CAP_MAC_ADMIN", "CAP_MAC_OVERRIDE"},
+	"CapabilityBoundingSet_CAP_LINUX_IMMUTABLE":                 {"CAP_LINUX_IMMUTABLE"},
+	"CapabilityBoundingSet_CAP_IPC_LOCK":                        {"CAP_IPC_LOCK"},
+	"CapabilityBoundingSet_CAP_SYS_CHROOT":                      {"CAP_SYS_CHROOT"},
+	"CapabilityBoundingSet_CAP_BLOCK_SUSPEND":                   {"CAP_BLOCK_SUSPEND"},
+	"CapabilityBoundingSet_CAP_WAKE_ALARM":                      {"CAP_WAKE_ALARM"},
+	"CapabilityBoundingSet_CAP_LEASE":                           {"CAP_LEASE"},
+	"CapabilityBoundingSet_CAP_SYS_TTY_CONFIG":                  {"CAP_SYS_TTY_CONFIG"},
+	"CapabilityBoundingSet_CAP_SYS_PACCT":                       {"CAP_SYS_PACCT"},
+	"CapabilityBoundingSet_CAP_BPF":                             {"CAP_BPF"},
+}
+
+// addressFamilies are the families RestrictAddressFamilies= keeps unless
+// their own check is exposed too.
+var addressFamilies = []struct {
+	check    string
+	families []string
+}{
+	{"RestrictAddressFamilies_AF_UNIX", []string{"AF_UNIX"}},
+	{"RestrictAddressFamilies_AF_INET_INET6", []string{"AF_INET", "AF_INET6"}},
+}
+
+// Needed picks the most exposed of issues until closing them would bring
+// overall to threshold or below. A check's exposure is its share of the
+// overall exposure, so the estimate is their difference.
+func Needed(issues []model.SecurityCheck, overall, threshold float64) []model.SecurityCheck {
+	issues = model.Issues(issues)
+	var out []model.SecurityCheck
+	for _, c := range issues {
+		if overall <= threshold {
+			break
+		}
+		if !Fixable(c) {
+			continue
+		}
+		out = append(out, c)
+		overall -= c.Exposure
+	}
+	return out
+}
+
+// Fixable reports whether Directives has a fix for the check.
+func Fixable(c model.SecurityCheck) bool {
+	id := c.JSONField
+	return settings[id] != "" || capabilities[id] != nil ||
+		strings.HasPrefix(id, "SystemCallFilter_") ||
+		strings.HasPrefix(id, "RestrictNamespaces_") ||
+		strings.HasPrefix(id, "RestrictAddressFamilies_")
+}
+
+// Suggest returns the directive that closes the exposed check c, if there
+// is a mechanical one.
+func Suggest(c model.SecurityCheck) (string, bool) {
+	if c.Exposure <= 0 || !Fixable(c) {
+		return "", false
+	}
+	d := Directives([]model.SecurityCheck{c})
+	if len(d) == 0 {
+		return "", false
+	}
+	return d[0], true
+}
+
+// listKeys are the directives whose assignments add to the earlier ones
+// instead of replacing them; Directives suggests an entry for the list.
+var listKeys = map[string]bool{
+	"CapabilityBoundingSet":   true,
+	"SystemCallFilter":        true,
+	"RestrictNamespaces":      true,
+	"RestrictAddressFamilies": true,
+}
+
+// IsList reports whether assignments of key add to the earlier ones, so a
+// suggested assignment of key only means what it says in a unit that
+// doesn't set key yet.
+func IsList(key string) bool {
+	return listKeys[key]
+}
+
+// Directives returns the [Service] assignments that close the given exposed
+// checks, most exposed first. Deny lists (capabilities, system calls,
+// namespaces) are combined into one assignment each. Checks without a
+// mechanical fix, such as RootDirectory=/RootImage=, are skipped.
+func Directives(issues []model.SecurityCheck) []string {
+	issues = append([]model.SecurityCheck(nil), issues...)
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Exposure > issues[j].Exposure })
+
+	exposed := map[string]bool{}
+	for _, c := range issues {
+		exposed[c.JSONField] = true
+	}
+
+	var out []string
+	lists := map[string]int{}
+	appendList := func(key string, values ...string) {
+		i, ok := lists[key]
+		if !ok {
+			lists[key] = len(out)
+			out = append(out, key+"=~"+strings.Join(values, " "))
+			return
+		}
+		out[i] += " " + strings.Join(values, " ")
+	}
+	seen := map[string]bool{}
+	for _, c := range issues {
+		id := c.JSONField
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		switch {
+		case settings[id] != "":
+			out = append(out, settings[id])
+		case capabilities[id] != nil:
+			appendList("CapabilityBoundingSet", capabilities[id]...)
+		case strings.HasPrefix(id, "SystemCallFilter_"):
+			// The check name carries the group: "SystemCallFilter=~@raw-io".
+			_, group, ok := strings.Cut(c.Name, "=~")
+			if ok {
+				appendList("SystemCallFilter", group)
+			}
+		case strings.HasPrefix(id, "RestrictNamespaces_"):
+			appendList("RestrictNamespaces", strings.TrimPrefix(id, "RestrictNamespaces_"))
+		case strings.HasPrefix(id, "RestrictAddressFamilies_"):
+			if _, ok := lists["RestrictAddressFamilies"]; ok {
+				continue
+			}
+			// An allow-list closes the family checks at once.
+			var keep []string
+			for _, f := range addressFamilies {
+				if !exposed[f.check] {
+					keep = append(keep, f.families...)
+				}
+			}
+			line := "RestrictAddressFamilies=none"
+			if len(keep) > 0 {
+				line = "RestrictAddressFamilies=" + strings.Join(keep, " ")
+			}
+			lists["RestrictAddressFamilies"] = len(out)
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// Render returns the drop-in with directives added to existing (the current
+// drop-in, "" for none). Directives the drop-in already has are left out, so
+// rerunning "ssg fix" only appends what is still missing; with nothing
+// missing, existing is returned as is.
+func Render(existing string, directives []string) (string, error) {
+	have := map[string]bool{}
+	lastSection := ""
+	if existing != "" {
+		f, err := unitfile.Parse(strings.NewReader(existing))
+		if err != nil {
+			return "", err
+		}
+		for _, e := range f.Entries {
+			have[e.Section+"\x00"+e.Key+"="+e.Value] = true
+		}
+		if n := len(f.Headers); n > 0 {
+			lastSection = f.Headers[n-1].Section
+		}
+	}
+
+	var missing []string
+	for _, d := range directives {
+		if !have["Service\x00"+d] {
+			missing = append(missing, d)
+		}
+	}
+	if len(missing) == 0 {
+		return existing, nil
+	}
+
+	var b strings.Builder
+	if existing == "" {
+		b.WriteString("# Generated by ssg fix: review before committing, some settings may\n")
+		b.WriteString("# break services that rely on the access they remove.\n")
+	} else {
+		b.WriteString(existing)
+		if !strings.HasSuffix(existing, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	header := lastSection != "Service"
+	for _, d := range missing {
+		if header {
+			if existing != "" {
+				b.WriteString("\n")
+			}
+			b.WriteString("[Service]\n")
+			header = false
+		}
+		b.WriteString(d + "\n")
+	}
+	return b.String(), nil
+}
+
+// Diff renders the change from old to new, where new only appends to old, as
+// a unified diff of path.
+func Diff(path, old, new string) string {
+	oldLines := splitLines(old)
+	newLines := splitLines(new)
+	added := newLines[len(oldLines):]
+	if len(added) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	from := "a/" + path
+	if old == "" {
+		from = "/dev/null"
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ b/%s\n", from, path)
+	fmt.Fprintf(&b, "@@ -%d,0 +%d,%d @@\n", len(oldLines), len(oldLines)+1, len(added))
+	for _, l := range added {
+		b.WriteString("+" + l + "\n")
+	}
+	return b.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
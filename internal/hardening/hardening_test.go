@@ -0,0 +1,86 @@
+package hardening
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+func TestDirectives(t *testing.T) {
+	got := Directives([]model.SecurityCheck{
+		{JSONField: "ProtectSystem", Exposure: 0.2},
+		{JSONField: "PrivateNetwork", Exposure: 0.5},
+		{JSONField: "CapabilityBoundingSet_CAP_SYS_ADMIN", Exposure: 0.3},
+		{JSONField: "CapabilityBoundingSet_CAP_KILL", Exposure: 0.1},
+		{Name: "SystemCallFilter=~@raw-io", JSONField: "SystemCallFilter_raw_io", Exposure: 0.1},
+		{JSONField: "RestrictAddressFamilies_OTHER", Exposure: 0.1},
+		{JSONField: "RestrictAddressFamilies_AF_PACKET", Exposure: 0.1},
+		{JSONField: "RootDirectoryOrRootImage", Exposure: 0.1},
+	})
+	want := []string{
+		"PrivateNetwork=yes",
+		"CapabilityBoundingSet=~CAP_SYS_ADMIN CAP_KILL",
+		"ProtectSystem=strict",
+		"SystemCallFilter=~@raw-io",
+		"RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Directives() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestNeeded(t *testing.T) {
+	checks := []model.SecurityCheck{
+		{JSONField: "PrivateTmp", Exposure: 0.2},
+		{JSONField: "RootDirectoryOrRootImage", Exposure: 0.6},
+		{JSONField: "PrivateNetwork", Exposure: 0.5},
+		{JSONField: "ProtectHome", Exposure: 0.2},
+	}
+	got := Needed(checks, 6.6, 6)
+	if len(got) != 2 || got[0].JSONField != "PrivateNetwork" || got[1].JSONField != "PrivateTmp" {
+		t.Fatalf("Needed() = %#v, want PrivateNetwork, PrivateTmp", got)
+	}
+	if got := Needed(checks, 5, 6); len(got) != 0 {
+		t.Fatalf("Needed() under threshold = %#v", got)
+	}
+}
+
+func TestRenderAppendsMissingDirectives(t *testing.T) {
+	first, err := Render("", []string{"PrivateTmp=yes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(first, "# Generated by ssg fix") || !strings.HasSuffix(first, "[Service]\nPrivateTmp=yes\n") {
+		t.Fatalf("unexpected new drop-in:\n%s", first)
+	}
+
+	second, err := Render(first, []string{"PrivateTmp=yes", "ProtectHome=yes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first+"ProtectHome=yes\n" {
+		t.Fatalf("unexpected updated drop-in:\n%s", second)
+	}
+
+	diff := Diff("a.service.d/50-ssg-hardening.conf", first, second)
+	want := "--- a/a.service.d/50-ssg-hardening.conf\n+++ b/a.service.d/50-ssg-hardening.conf\n@@ -4,0 +5,1 @@\n+ProtectHome=yes\n"
+	if diff != want {
+		t.Fatalf("Diff() =\n%s\nwant:\n%s", diff, want)
+	}
+
+	if same, err := Render(second, []string{"ProtectHome=yes"}); err != nil || same != second {
+		t.Fatalf("Render() with nothing missing = %q, %v; want the drop-in unchanged", same, err)
+	}
+	if empty, err := Render("", nil); err != nil || empty != "" {
+		t.Fatalf("Render() without directives = %q, %v; want no drop-in", empty, err)
+	}
+
+	other, err := Render("[Unit]\nDescription=x", []string{"PrivateTmp=yes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != "[Unit]\nDescription=x\n\n[Service]\nPrivateTmp=yes\n" {
+		t.Fatalf("unexpected drop-in without [Service]:\n%s", other)
+	}
+}
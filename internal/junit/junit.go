@@ -0,0 +1,181 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+// Minimal JUnit XML structures, as read by GitLab test reports and most CI
+// dashboards.
+
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+type TestSuite struct {
+	Name       string     `xml:"name,attr"`
+	Tests      int        `xml:"tests,attr"`
+	Failures   int        `xml:"failures,attr"`
+	Errors     int        `xml:"errors,attr"`
+	Skipped    int        `xml:"skipped,attr"`
+	Properties []Property `xml:"properties>property,omitempty"`
+	Cases      []TestCase `xml:"testcase"`
+}
+
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type TestCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	File      string   `xml:"file,attr,omitempty"`
+	Failure   *Result  `xml:"failure,omitempty"`
+	Error     *Result  `xml:"error,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+}
+
+type Result struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type Skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+const suiteName = "systemd-security-gate"
+
+// FromScanReport renders the scan as a single test suite with one test case
+// per unit: units that trip the gate or expose a required check fail,
+// allowlisted ones are skipped and analysis errors are reported as errors.
+func FromScanReport(scan model.ScanReport) TestSuites {
+	suite := TestSuite{Name: suiteName}
+	for _, kv := range [][2]string{
+		{"threshold", fmt.Sprintf("%.2f", scan.Threshold)},
+		{"maxRating", scan.MaxRating},
+		{"mode", scan.Mode},
+		{"backend", scan.Backend},
+		{"systemdVersion", scan.SystemdVersion},
+		{"policy", scan.PolicyPath},
+		{"baseline", scan.BaselinePath},
+		{"base", scan.Base},
+	} {
+		if kv[1] != "" {
+			suite.Properties = append(suite.Properties, Property{Name: kv[0], Value: kv[1]})
+		}
+	}
+
+	for _, u := range scan.Units {
+		tc := TestCase{Name: u.UnitName, ClassName: suiteName, File: u.RepoRelPath}
+		switch {
+		case u.Error != "":
+			tc.Error = &Result{Message: u.Error, Type: "AnalysisError", Text: u.Error}
+			suite.Errors++
+		case u.Failing():
+			tc.Failure = failure(u)
+			suite.Failures++
+		case u.Flagged() && u.Allowed:
+			tc.Skipped = &Skipped{Message: allowedMessage(u)}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+
+	return TestSuites{
+		Name:     suiteName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Suites:   []TestSuite{suite},
+	}
+}
+
+// Marshal renders the report as an indented XML document.
+func Marshal(r TestSuites) ([]byte, error) {
+	b, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+func allowedMessage(u model.UnitReport) string {
+	if len(u.Exceptions) == 0 {
+		return "allowed by allowlist"
+	}
+	var parts []string
+	for _, e := range u.Exceptions {
+		parts = append(parts, e.Describe())
+	}
+	return "allowed by allowlist: " + strings.Join(parts, "; ")
+}
+
+func failure(u model.UnitReport) *Result {
+	var msg, typ string
+	switch {
+	case !u.Flagged() || u.Allowed:
+		msg = fmt.Sprintf("exposes required checks: %s", strings.Join(u.RequiredChecksFailed, ", "))
+		typ = "RequiredCheckFailed"
+	case u.Baseline != nil && u.Baseline.Regressed:
+		msg = fmt.Sprintf("overall exposure %.2f regressed from baseline %.2f (%+.2f)", u.OverallExposure, u.Baseline.PreviousExposure, u.Baseline.Delta)
+		typ = "Regression"
+	case u.MaxRating != "":
+		msg = fmt.Sprintf("overall rating %s (%.2f) is worse than %s", u.OverallRating, u.OverallExposure, u.MaxRating)
+		typ = "RatingExceeded"
+	default:
+		msg = fmt.Sprintf("overall exposure %.2f exceeds threshold %.2f", u.OverallExposure, u.Threshold)
+		typ = "ThresholdExceeded"
+	}
+	switch {
+	case typ == "RequiredCheckFailed":
+	case u.ExceptionExpired:
+		msg += " and its allowlist exception has expired"
+		typ = "ExpiredException"
+	case u.OverBudget:
+		msg += " and is over the budget of its allowlist exception"
+		typ = "BudgetExceeded"
+	}
+
+	var b strings.Builder
+	if len(u.RequiredChecksFailed) > 0 {
+		b.WriteString(fmt.Sprintf("Required checks exposed: %s\n", strings.Join(u.RequiredChecksFailed, ", ")))
+	}
+	if u.ExceptionExpired || u.OverBudget {
+		label := "Expired exception"
+		if u.OverBudget {
+			label = "Exceeded exception budget"
+		}
+		for _, e := range u.Exceptions {
+			b.WriteString(fmt.Sprintf("%s: %s\n", label, e.Describe()))
+		}
+	}
+	if u.Baseline != nil && len(u.Baseline.IntroducedChecks) > 0 {
+		b.WriteString(fmt.Sprintf("Introduced checks: %s\n", strings.Join(u.Baseline.IntroducedChecks, ", ")))
+	}
+	for _, c := range u.TopIssues {
+		id := model.CheckID(c)
+		if id == "" {
+			id = "(unknown)"
+		}
+		desc := c.Description
+		if desc == "" {
+			desc = c.Name
+		}
+		b.WriteString(fmt.Sprintf("%s exposure=%.2f: %s\n", id, c.Exposure, desc))
+	}
+	return &Result{Message: msg, Type: typ, Text: b.String()}
+}
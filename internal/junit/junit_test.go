@@ -0,0 +1,117 @@
+package junit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+func TestFromScanReport(t *testing.T) {
+	scan := model.ScanReport{
+		Threshold: 6,
+		Mode:      "enforce",
+		Units: []model.UnitReport{
+			{UnitName: "ok.service", RepoRelPath: "deploy/ok.service", Threshold: 6, OverallExposure: 2},
+			{
+				UnitName:          "bad.service",
+				RepoRelPath:       "deploy/bad.service",
+				Threshold:         6,
+				OverallExposure:   7.2,
+				ThresholdExceeded: true,
+				TopIssues:         []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5, Description: "Service has access to the host's network"}},
+			},
+			{
+				UnitName:          "allowed.service",
+				RepoRelPath:       "deploy/allowed.service",
+				ThresholdExceeded: true,
+				Allowed:           true,
+				Exceptions:        []model.Exception{{Unit: "allowed.service", Ticket: "OPS-1"}},
+			},
+			{UnitName: "broken.service", RepoRelPath: "deploy/broken.service", Error: "systemd-analyze failed"},
+		},
+	}
+
+	r := FromScanReport(scan)
+	if len(r.Suites) != 1 {
+		t.Fatalf("suites len = %d, want 1", len(r.Suites))
+	}
+	if r.Tests != 4 || r.Failures != 1 || r.Skipped != 1 || r.Errors != 1 {
+		t.Fatalf("unexpected counts: tests=%d failures=%d skipped=%d errors=%d", r.Tests, r.Failures, r.Skipped, r.Errors)
+	}
+	cases := r.Suites[0].Cases
+	if cases[0].Failure != nil || cases[0].Skipped != nil || cases[0].Error != nil {
+		t.Fatalf("passing unit has a result: %#v", cases[0])
+	}
+	if cases[1].Failure == nil || !strings.Contains(cases[1].Failure.Text, "PrivateNetwork exposure=0.50: Service has access") {
+		t.Fatalf("unexpected failure: %#v", cases[1].Failure)
+	}
+	if cases[2].Skipped == nil || !strings.Contains(cases[2].Skipped.Message, "ticket: OPS-1") {
+		t.Fatalf("unexpected skipped: %#v", cases[2].Skipped)
+	}
+	if cases[3].Error == nil || cases[3].Error.Message != "systemd-analyze failed" {
+		t.Fatalf("unexpected error: %#v", cases[3].Error)
+	}
+}
+
+func TestFromScanReportRequiredChecks(t *testing.T) {
+	scan := model.ScanReport{Units: []model.UnitReport{
+		{
+			UnitName:             "api.service",
+			OverallExposure:      4.1,
+			RequiredChecksFailed: []string{"PrivateNetwork"},
+		},
+		{
+			UnitName:             "allowed.service",
+			ThresholdExceeded:    true,
+			Allowed:              true,
+			RequiredChecksFailed: []string{"PrivateNetwork"},
+		},
+	}}
+
+	r := FromScanReport(scan)
+	if r.Failures != 2 || r.Skipped != 0 {
+		t.Fatalf("unexpected counts: failures=%d skipped=%d", r.Failures, r.Skipped)
+	}
+	for _, tc := range r.Suites[0].Cases {
+		if tc.Failure.Type != "RequiredCheckFailed" || tc.Failure.Message != "exposes required checks: PrivateNetwork" {
+			t.Fatalf("unexpected failure for %s: %#v", tc.Name, tc.Failure)
+		}
+	}
+}
+
+func TestFromScanReportExpiredRegression(t *testing.T) {
+	scan := model.ScanReport{Units: []model.UnitReport{{
+		UnitName:         "api.service",
+		RepoRelPath:      "deploy/api.service",
+		Threshold:        6,
+		OverallExposure:  5.1,
+		Baseline:         &model.BaselineDelta{PreviousExposure: 4.8, Delta: 0.3, Regressed: true},
+		ExceptionExpired: true,
+		Exceptions:       []model.Exception{{Unit: "api.service", Expires: "2026-01-01"}},
+	}}}
+
+	f := FromScanReport(scan).Suites[0].Cases[0].Failure
+	if f == nil || f.Type != "ExpiredException" || f.Message != "overall exposure 5.10 regressed from baseline 4.80 (+0.30) and its allowlist exception has expired" {
+		t.Fatalf("unexpected failure: %#v", f)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	b, err := Marshal(FromScanReport(model.ScanReport{
+		Units: []model.UnitReport{{UnitName: "a.service", RepoRelPath: "deploy/a.service", Error: "bad <unit>"}},
+	}))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	out := string(b)
+	if !strings.HasPrefix(out, "<?xml") {
+		t.Fatalf("missing XML header:\n%s", out)
+	}
+	if !strings.Contains(out, `<testcase name="a.service" classname="systemd-security-gate" file="deploy/a.service">`) {
+		t.Fatalf("missing testcase:\n%s", out)
+	}
+	if !strings.Contains(out, `<error message="bad &lt;unit&gt;" type="AnalysisError">`) {
+		t.Fatalf("error not escaped:\n%s", out)
+	}
+}
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// ExpiredExceptionRuleID reports units whose allowlist exception has expired.
+const ExpiredExceptionRuleID = "ssg.expired-exception"
+
+// Finding is one reportable problem of a unit: an exposed check, or an
+// expired allowlist exception.
+type Finding struct {
+	RuleID   string
+	RuleName string
+	Unit     UnitReport
+	// Check is set for exposed checks, Exception for expired exceptions.
+	Check     *SecurityCheck
+	Exception *Exception
+	Message   string
+	// Location is the check's location, or the unit file without a line.
+	Location Location
+	// Passing is set for findings of units that pass the gate (or are
+	// allowlisted); only AllFindings returns those.
+	Passing bool
+}
+
+// Findings lists the findings of the units that fail the gate, in unit
+// order: their exposed required checks, top issues and expired exceptions.
+// Units that failed analysis have none.
+func Findings(scan ScanReport) []Finding {
+	return failingFindings(scan, false)
+}
+
+// FailingFindings is Findings with every exposed check of the units that
+// fail the gate rather than their top issues, for reports that track issues
+// across pipelines: a check dropping out of the top issues is not fixed.
+func FailingFindings(scan ScanReport) []Finding {
+	return failingFindings(scan, true)
+}
+
+func failingFindings(scan ScanReport, allIssues bool) []Finding {
+	var out []Finding
+	for _, u := range scan.Units {
+		if u.Error != "" || !u.Failing() {
+			continue
+		}
+		issues := u.RequiredIssues()
+		if u.Flagged() && !u.Allowed {
+			top := u.TopIssues
+			if allIssues {
+				top = Issues(u.Checks)
+			}
+			for _, c := range top {
+				if !u.requires(c) {
+					issues = append(issues, c)
+				}
+			}
+		}
+		out = append(out, unitFindings(u, issues, false)...)
+	}
+	return out
+}
+
+// AllFindings lists every exposed check of every analyzed unit, in unit
+// order, plus the expired exceptions of failing units. Exposed required
+// checks are never passing.
+func AllFindings(scan ScanReport) []Finding {
+	var out []Finding
+	for _, u := range scan.Units {
+		if u.Error != "" {
+			continue
+		}
+		out = append(out, unitFindings(u, Issues(u.Checks), u.Allowed || !u.Flagged())...)
+	}
+	return out
+}
+
+func unitFindings(u UnitReport, issues []SecurityCheck, passing bool) []Finding {
+	var out []Finding
+	if u.ExceptionExpired && !passing {
+		for i := range u.Exceptions {
+			e := u.Exceptions[i]
+			out = append(out, Finding{
+				RuleID:    ExpiredExceptionRuleID,
+				RuleName:  "ExpiredException",
+				Unit:      u,
+				Exception: &e,
+				Location:  Location{Path: u.RepoRelPath},
+				Message:   fmt.Sprintf("%s %s and its allowlist exception has expired: %s", u.UnitName, u.flagReason(), e.Describe()),
+			})
+		}
+	}
+	for i := range issues {
+		c := issues[i]
+		id := CheckID(c)
+		if id == "" {
+			continue
+		}
+		loc := Location{Path: u.RepoRelPath}
+		if c.Location != nil {
+			loc = *c.Location
+		}
+		msg := fmt.Sprintf("%s exposure=%.2f: %s", id, c.Exposure, c.Description)
+		required := u.requires(c)
+		if required {
+			msg = "required check " + msg
+		}
+		out = append(out, Finding{
+			RuleID:   "systemd." + id,
+			RuleName: id,
+			Unit:     u,
+			Check:    &c,
+			Message:  msg,
+			Location: loc,
+			Passing:  passing && !required,
+		})
+	}
+	return out
+}
+
+// Finding severities, from the exposure of the check. systemd 252 gives a
+// fully exposed check 0.5 (PrivateNetwork=) down to 0.1 or less, so
+// SeverityHigh covers the heaviest checks: running as root, network access.
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// Severity rates the finding by the exposure of its check. Expired
+// exceptions are always SeverityHigh.
+func (f Finding) Severity() string {
+	switch {
+	case f.Check == nil || f.Check.Exposure >= 0.4:
+		return SeverityHigh
+	case f.Check.Exposure >= 0.2:
+		return SeverityMedium
+	default:
+		return SeverityLow
+	}
+}
+
+// Fingerprint identifies the finding across scans. It only depends on the
+// rule and the unit (and the allowlist entry for expired exceptions), so it
+// stays the same while the exposure changes.
+func (f Finding) Fingerprint() string {
+	key := f.RuleID + "\x00" + f.Unit.RepoRelPath + "\x00" + f.Unit.UnitName
+	if f.Exception != nil {
+		key += "\x00" + f.Exception.Unit + "\x00" + f.Exception.Test
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
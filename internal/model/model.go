@@ -2,6 +2,8 @@ package model
 
 import (
 	"sort"
+	"strconv"
+	"strings"
 )
 
 type SecurityCheck struct {
@@ -10,42 +12,252 @@ type SecurityCheck struct {
 	JSONField   string  `json:"json_field"`
 	Description string  `json:"description"`
 	Exposure    float64 `json:"exposure"`
+	// Location is where the unit configures the check, or the [Service]
+	// header when it doesn't. It is only set for exposed checks.
+	Location *Location `json:"location,omitempty"`
+}
+
+// Location is a line in a repo file. For a unit setting it is the
+// assignment of Key, which runs to EndLine, or the section header when Key
+// is empty.
+type Location struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	EndLine int    `json:"endLine,omitempty"`
+	Key     string `json:"key,omitempty"`
 }
 
 type UnitFile struct {
 	UnitName    string
 	RepoRelPath string
+	// ActivatedBy lists the .socket/.timer/.path units that trigger this service.
+	ActivatedBy []string
+	// Template is set for instances of a template unit ("foo@.service").
+	Template string
+	// Error is set for units that can't be analyzed, such as an activator
+	// whose service is missing.
+	Error string
 }
 
 type UnitReport struct {
-	UnitName    string `json:"unitName"`
-	RepoRelPath string `json:"repoRelPath"`
+	UnitName    string   `json:"unitName"`
+	RepoRelPath string   `json:"repoRelPath"`
+	ActivatedBy []string `json:"activatedBy,omitempty"`
+	Template    string   `json:"template,omitempty"`
+
+	// Threshold, PolicyPath and Mode are the settings resolved for this unit
+	// from the flags and the repo config file. With MaxRating, the unit is
+	// gated on its rating and Threshold is the highest exposure rated
+	// MaxRating.
+	Threshold  float64 `json:"threshold"`
+	MaxRating  string  `json:"maxRating,omitempty"`
+	PolicyPath string  `json:"policyPath,omitempty"`
+	Mode       string  `json:"mode,omitempty"`
 
 	OverallExposure   float64 `json:"overallExposure,omitempty"`
 	OverallRating     string  `json:"overallRating,omitempty"`
 	ThresholdExceeded bool    `json:"thresholdExceeded,omitempty"`
 	Allowed           bool    `json:"allowed,omitempty"`
+	// ExceptionExpired is set when only expired allowlist entries cover the unit.
+	ExceptionExpired bool `json:"exceptionExpired,omitempty"`
+	// OverBudget is set when allowlist entries cover the unit but it exceeds
+	// their maxExposure.
+	OverBudget bool        `json:"overBudget,omitempty"`
+	Exceptions []Exception `json:"exceptions,omitempty"`
+	// RequiredChecksFailed lists the required checks the unit exposes. They
+	// fail the unit whatever its overall exposure, and can't be allowlisted.
+	RequiredChecksFailed []string `json:"requiredChecksFailed,omitempty"`
+
+	Baseline *BaselineDelta `json:"baseline,omitempty"`
 
 	Checks    []SecurityCheck `json:"checks,omitempty"`
 	TopIssues []SecurityCheck `json:"topIssues,omitempty"`
 
 	Error string `json:"error,omitempty"`
+	// Warnings are about the unit's files, such as suppression comments that
+	// expire soon.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 type ScanReport struct {
 	RepoRoot        string   `json:"repoRoot"`
+	Backend         string   `json:"backend,omitempty"`
 	SystemdAnalyze  string   `json:"systemdAnalyze"`
 	SystemdVersion  string   `json:"systemdVersion,omitempty"`
 	Threshold       float64  `json:"threshold"`
+	MaxRating       string   `json:"maxRating,omitempty"`
 	PolicyPath      string   `json:"policyPath,omitempty"`
 	AllowlistPath   string   `json:"allowlistPath,omitempty"`
+	ConfigPath      string   `json:"configPath,omitempty"`
+	BaselinePath    string   `json:"baselinePath,omitempty"`
 	Mode            string   `json:"mode"`
+	RequiredChecks  []string `json:"requiredChecks,omitempty"`
 	MatchedServices []string `json:"matchedServices"`
 
-	Units []UnitReport `json:"units"`
+	Units     []UnitReport     `json:"units"`
+	Templates []TemplateReport `json:"templates,omitempty"`
+	Warnings  []string         `json:"warnings,omitempty"`
+	// StaleExceptions are the allowlist entries no scanned unit uses.
+	StaleExceptions []StaleException `json:"staleExceptions,omitempty"`
+
+	// Base and BaseCommit are set by "ssg diff": Units then only holds units
+	// that changed since BaseCommit, and DeletedUnits their removed peers as
+	// analyzed at BaseCommit.
+	Base         string       `json:"base,omitempty"`
+	BaseCommit   string       `json:"baseCommit,omitempty"`
+	DeletedUnits []UnitReport `json:"deletedUnits,omitempty"`
+}
+
+// BaselineDelta compares a unit with its entry in a baseline report.
+type BaselineDelta struct {
+	// New is set when the baseline has no (successfully analyzed) entry for
+	// the unit; such units are gated by the threshold instead.
+	New              bool     `json:"new,omitempty"`
+	PreviousExposure float64  `json:"previousExposure,omitempty"`
+	Delta            float64  `json:"delta,omitempty"`
+	IntroducedChecks []string `json:"introducedChecks,omitempty"`
+	ResolvedChecks   []string `json:"resolvedChecks,omitempty"`
+	Regressed        bool     `json:"regressed,omitempty"`
+}
+
+// Changed reports whether the delta records any difference from the baseline.
+func (d BaselineDelta) Changed() bool {
+	return d.New || d.Delta != 0 || len(d.IntroducedChecks) > 0 || len(d.ResolvedChecks) > 0
+}
+
+// Flagged reports whether the unit trips the gate before allowlisting: it
+// regressed against the baseline or, without a baseline entry, exceeds its
+// threshold.
+func (u UnitReport) Flagged() bool {
+	if u.Baseline != nil && !u.Baseline.New {
+		return u.Baseline.Regressed
+	}
+	return u.ThresholdExceeded
+}
+
+// flagReason says why the unit is flagged, to follow its name.
+func (u UnitReport) flagReason() string {
+	switch {
+	case u.Baseline != nil && !u.Baseline.New:
+		return "regressed against its baseline"
+	case u.MaxRating != "":
+		return "is rated worse than " + u.MaxRating
+	}
+	return "exceeds its threshold"
+}
+
+// Failing reports whether the unit fails the gate: it is flagged and not
+// allowlisted, or exposes a required check.
+func (u UnitReport) Failing() bool {
+	return (u.Flagged() && !u.Allowed) || len(u.RequiredChecksFailed) > 0
+}
+
+// RequiredIssues returns the checks named by RequiredChecksFailed.
+func (u UnitReport) RequiredIssues() []SecurityCheck {
+	var issues []SecurityCheck
+	for _, c := range u.Checks {
+		if u.requires(c) {
+			issues = append(issues, c)
+		}
+	}
+	return issues
+}
+
+func (u UnitReport) requires(c SecurityCheck) bool {
+	for _, id := range u.RequiredChecksFailed {
+		if id == CheckID(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Exception is an allowlist entry applied to (or expired for) a unit.
+type Exception struct {
+	Unit    string `json:"unit"`
+	Test    string `json:"test,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Owner   string `json:"owner,omitempty"`
+	Ticket  string `json:"ticket,omitempty"`
+	Expires string `json:"expires,omitempty"`
+	// MaxExposure is the entry's exposure budget, if it has one.
+	MaxExposure *float64 `json:"maxExposure,omitempty"`
+	// Location is set for suppression comments in unit files.
+	Location *Location `json:"location,omitempty"`
+	// MatchedUnit and MatchedTests are what the entry's patterns matched, if
+	// Unit or Test is a pattern.
+	MatchedUnit  string   `json:"matchedUnit,omitempty"`
+	MatchedTests []string `json:"matchedTests,omitempty"`
+}
+
+// Describe renders the exception for summaries and messages.
+func (e Exception) Describe() string {
+	s := e.Unit
+	if e.MatchedUnit != "" {
+		s += " [" + e.MatchedUnit + "]"
+	}
+	if e.Test != "" {
+		s += " / " + e.Test
+	}
+	if len(e.MatchedTests) > 0 {
+		s += " [" + strings.Join(e.MatchedTests, ", ") + "]"
+	}
+	var meta []string
+	maxExposure := ""
+	if e.MaxExposure != nil {
+		maxExposure = strconv.FormatFloat(*e.MaxExposure, 'f', 2, 64)
+	}
+	source := ""
+	if e.Location != nil {
+		source = e.Location.Path + ":" + strconv.Itoa(e.Location.Line)
+	}
+	for _, kv := range [][2]string{{"max exposure", maxExposure}, {"expires", e.Expires}, {"owner", e.Owner}, {"ticket", e.Ticket}, {"reason", e.Reason}, {"source", source}} {
+		if kv[1] != "" {
+			meta = append(meta, kv[0]+": "+kv[1])
+		}
+	}
+	if len(meta) > 0 {
+		s += " (" + strings.Join(meta, ", ") + ")"
+	}
+	return s
+}
+
+// StaleException is an allowlist entry that no longer applies to any unit.
+type StaleException struct {
+	Exception
+	Cause string `json:"cause"`
+}
+
+// TemplateReport groups the analyzed instances of a template unit.
+type TemplateReport struct {
+	Template    string   `json:"template"`
+	RepoRelPath string   `json:"repoRelPath"`
+	Instances   []string `json:"instances"`
+}
+
+// GroupTemplates collects the instance units in units under their template,
+// sorted by template name.
+func GroupTemplates(units []UnitReport) []TemplateReport {
+	var groups []TemplateReport
+	index := map[string]int{}
+	for _, u := range units {
+		if u.Template == "" {
+			continue
+		}
+		i, ok := index[u.Template]
+		if !ok {
+			i = len(groups)
+			index[u.Template] = i
+			groups = append(groups, TemplateReport{Template: u.Template, RepoRelPath: u.RepoRelPath})
+		}
+		groups[i].Instances = append(groups[i].Instances, u.UnitName)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Template < groups[j].Template })
+	return groups
 }
 
-func checkID(c SecurityCheck) string {
+// CheckID identifies a check by its JSON field, falling back to its name.
+func CheckID(c SecurityCheck) string {
 	if c.JSONField != "" {
 		return c.JSONField
 	}
@@ -61,7 +273,7 @@ func Issues(checks []SecurityCheck) []SecurityCheck {
 	}
 	sort.SliceStable(issues, func(i, j int) bool {
 		if issues[i].Exposure == issues[j].Exposure {
-			return checkID(issues[i]) < checkID(issues[j])
+			return CheckID(issues[i]) < CheckID(issues[j])
 		}
 		return issues[i].Exposure > issues[j].Exposure
 	})
@@ -1,6 +1,9 @@
 package model
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestIssuesSortAndFilter(t *testing.T) {
 	checks := []SecurityCheck{
@@ -29,3 +32,43 @@ func TestIssuesSortAndFilter(t *testing.T) {
 		t.Fatalf("TopIssues() = %#v, want C then A", top)
 	}
 }
+
+func TestGroupTemplates(t *testing.T) {
+	units := []UnitReport{
+		{UnitName: "plain.service"},
+		{UnitName: "web@b.service", Template: "web@.service", RepoRelPath: "deploy/web@.service"},
+		{UnitName: "api@x.service", Template: "api@.service", RepoRelPath: "deploy/api@.service"},
+		{UnitName: "web@a.service", Template: "web@.service", RepoRelPath: "deploy/web@.service"},
+	}
+
+	got := GroupTemplates(units)
+	if len(got) != 2 {
+		t.Fatalf("GroupTemplates() len = %d, want 2: %#v", len(got), got)
+	}
+	if got[0].Template != "api@.service" || got[1].Template != "web@.service" {
+		t.Fatalf("GroupTemplates() order = %#v", got)
+	}
+	if len(got[1].Instances) != 2 || got[1].Instances[0] != "web@b.service" || got[1].RepoRelPath != "deploy/web@.service" {
+		t.Fatalf("GroupTemplates()[1] = %#v", got[1])
+	}
+}
+
+func TestFindingsExpiredExceptionReason(t *testing.T) {
+	for _, tc := range []struct {
+		unit UnitReport
+		want string
+	}{
+		{UnitReport{ThresholdExceeded: true}, "a.service exceeds its threshold and its allowlist exception has expired"},
+		{UnitReport{ThresholdExceeded: true, MaxRating: "MEDIUM"}, "a.service is rated worse than MEDIUM and its allowlist exception has expired"},
+		{UnitReport{Baseline: &BaselineDelta{Regressed: true}}, "a.service regressed against its baseline and its allowlist exception has expired"},
+	} {
+		u := tc.unit
+		u.UnitName = "a.service"
+		u.ExceptionExpired = true
+		u.Exceptions = []Exception{{Unit: "a.service", Expires: "2026-01-01"}}
+		findings := Findings(ScanReport{Units: []UnitReport{u}})
+		if len(findings) != 1 || findings[0].RuleID != ExpiredExceptionRuleID || !strings.HasPrefix(findings[0].Message, tc.want+": ") {
+			t.Fatalf("findings = %#v, want message %q", findings, tc.want)
+		}
+	}
+}
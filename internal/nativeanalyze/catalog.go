@@ -0,0 +1,74 @@
+package nativeanalyze
+
+import "strings"
+
+// TargetMajor is the major systemd version of TargetVersion.
+const TargetMajor = 252
+
+// Check is one row of the check table of TargetVersion, with any security
+// policy overrides applied.
+type Check struct {
+	Name           string
+	JSONField      string
+	DescriptionBad string
+	Weight         uint64
+	Range          uint64
+
+	defaultRange uint64
+	levels       []level
+}
+
+// Catalog returns the checks in systemd's table order, with the weights,
+// ranges and texts of the policy at policyPath ("" for none).
+func Catalog(policyPath string) ([]Check, error) {
+	policy, err := loadPolicy(policyPath)
+	if err != nil {
+		return nil, err
+	}
+	checks := make([]Check, 0, len(assessors))
+	for _, a := range assessors {
+		c := Check{
+			Name:           a.name,
+			JSONField:      a.jsonField,
+			DescriptionBad: a.descBad,
+			Weight:         a.weight,
+			Range:          a.rng,
+			defaultRange:   a.rng,
+			levels:         a.levels,
+		}
+		p := policy[a.jsonField]
+		if p.Weight != nil {
+			c.Weight = *p.Weight
+		}
+		if p.Range != nil {
+			c.Range = *p.Range
+		}
+		if p.DescriptionBad != nil {
+			c.DescriptionBad = *p.DescriptionBad
+		}
+		checks = append(checks, c)
+	}
+	return checks, nil
+}
+
+// Badness returns the badness behind the description of an exposed check.
+// ok is false for descriptions the catalog doesn't know.
+func (c Check) Badness(description string) (uint64, bool) {
+	if c.defaultRange == 1 {
+		return 1, true
+	}
+	if c.DescriptionBad != "" && description == c.DescriptionBad {
+		return c.Range, true
+	}
+	for _, l := range c.levels {
+		// Without a description of its own, the level is only known for
+		// the default range.
+		if l.prefix == "" && c.Range != c.defaultRange {
+			continue
+		}
+		if strings.HasPrefix(description, l.prefix) {
+			return l.badness, true
+		}
+	}
+	return 0, false
+}
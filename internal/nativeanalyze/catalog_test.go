@@ -0,0 +1,67 @@
+package nativeanalyze
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalogBadness(t *testing.T) {
+	root := t.TempDir()
+	policy := filepath.Join(root, "policy.json")
+	mustWrite(t, policy, `{
+  "UserOrDynamicUser": { "weight": 5000, "description_bad": "Runs as root" },
+  "ProtectSystem": { "range": 20 },
+  "ProtectProc": { "range": 5 }
+}`)
+	catalog, err := Catalog(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != len(assessors) {
+		t.Fatalf("catalog has %d checks, want %d", len(catalog), len(assessors))
+	}
+	checks := map[string]Check{}
+	for _, c := range catalog {
+		checks[c.JSONField] = c
+	}
+	if c := checks["UserOrDynamicUser"]; c.Weight != 5000 || c.Range != 10 {
+		t.Fatalf("UserOrDynamicUser weight/range = %d/%d, want 5000/10", c.Weight, c.Range)
+	}
+
+	cases := []struct {
+		field       string
+		description string
+		want        uint64
+		ok          bool
+	}{
+		{"NoNewPrivileges", "Service processes may acquire new privileges", 1, true},
+		{"UserOrDynamicUser", "Runs as root", 10, true},
+		{"UserOrDynamicUser", "Service runs under as 'nobody' user, which should not be used for services", 9, true},
+		{"UserOrDynamicUser", "Service runs as root user", 0, false},
+		{"ProtectSystem", "Service has very limited write access to the OS file hierarchy", 3, true},
+		{"ProtectSystem", "Service has full access to the OS file hierarchy", 10, true},
+		{"ProtectProc", "Service has full access to process tree (/proc hidepid=)", 5, true},
+		{"ProtectProc", "", 0, false},
+		{"UMask", "Files created by service are group-writable by default", 2, true},
+		{"SystemCallFilter_mount", "System call allow list defined for service, and @mount is included (e.g. mount is allowed)", 9, true},
+		{"DeviceAllow", "Service has a device ACL with some special devices: /dev/null rw", 5, true},
+	}
+	for _, tc := range cases {
+		got, ok := checks[tc.field].Badness(tc.description)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s.Badness(%q) = %d, %v; want %d, %v", tc.field, tc.description, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	defaults, err := Catalog("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range defaults {
+		if c.JSONField == "ProtectProc" {
+			if got, ok := c.Badness(""); got != 1 || !ok {
+				t.Fatalf("ProtectProc.Badness(\"\") = %d, %v; want 1, true", got, ok)
+			}
+		}
+	}
+}
@@ -0,0 +1,677 @@
+package nativeanalyze
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// badnessNA marks a check that does not apply to the unit.
+const badnessNA = math.MaxUint64
+
+// assessor is one row of systemd's security table. The order, names, texts,
+// weights and ranges follow systemd 252's analyze-security.c.
+type assessor struct {
+	name      string
+	jsonField string
+	descGood  string
+	descBad   string
+	descNA    string
+	weight    uint64
+	rng       uint64
+	// defaultDependenciesOnly checks don't apply to DefaultDependencies=no units.
+	defaultDependenciesOnly bool
+	// assess returns the badness (0..rng, or badnessNA) and an optional
+	// description overriding descGood/descBad.
+	assess func(i *securityInfo) (uint64, string)
+	// levels map the descriptions assess reports for a non-zero badness
+	// back to it, for reading systemd-analyze's JSON table.
+	levels []level
+}
+
+// level is a badness that assess reports with descriptions starting with
+// prefix.
+type level struct {
+	prefix  string
+	badness uint64
+}
+
+func boolCheck(ok func(i *securityInfo) bool) func(i *securityInfo) (uint64, string) {
+	return func(i *securityInfo) (uint64, string) {
+		if ok(i) {
+			return 0, ""
+		}
+		return 1, ""
+	}
+}
+
+func capabilityCheck(names ...string) func(i *securityInfo) (uint64, string) {
+	var mask uint64
+	for _, n := range names {
+		c, ok := capabilityFromName(n)
+		if !ok {
+			panic("unknown capability " + n)
+		}
+		mask |= 1 << c
+	}
+	return boolCheck(func(i *securityInfo) bool { return i.capabilityBoundingSet&mask == 0 })
+}
+
+func namespaceCheck(flag uint64) func(i *securityInfo) (uint64, string) {
+	return boolCheck(func(i *securityInfo) bool { return i.restrictNamespaces&flag == 0 })
+}
+
+func syscallFilterCheck(group string) func(i *securityInfo) (uint64, string) {
+	return func(i *securityInfo) (uint64, string) {
+		if !i.syscallAllowList && len(i.syscallFilter) == 0 {
+			return 10, "Service does not filter system calls"
+		}
+		offender, bad := syscallOffender(i.syscallFilter, i.syscallAllowList, group)
+		switch {
+		case i.syscallAllowList && bad:
+			return 9, fmt.Sprintf("System call allow list defined for service, and %s is included (e.g. %s is allowed)", group, offender)
+		case i.syscallAllowList:
+			return 0, fmt.Sprintf("System call allow list defined for service, and %s is not included", group)
+		case bad:
+			return 10, fmt.Sprintf("System call deny list defined for service, and %s is not included (e.g. %s is allowed)", group, offender)
+		default:
+			return 0, fmt.Sprintf("System call deny list defined for service, and %s is included", group)
+		}
+	}
+}
+
+// syscallFilterLevels are the exposed descriptions of syscallFilterCheck.
+var syscallFilterLevels = []level{
+	{"Service does not filter system calls", 10},
+	{"System call allow list defined for service", 9},
+	{"System call deny list defined for service", 10},
+}
+
+func assessUser(i *securityInfo) (uint64, string) {
+	switch {
+	case i.user == "nobody":
+		return 9, "Service runs under as 'nobody' user, which should not be used for services"
+	case i.dynamicUser && !isRootUser(i.user):
+		return 0, "Service runs under a transient non-root user identity"
+	case isRootUser(i.user) || i.user == "":
+		return 10, ""
+	default:
+		return 0, "Service runs under a static non-root user identity"
+	}
+}
+
+func assessSupplementaryGroups(i *securityInfo) (uint64, string) {
+	if i.runsPrivileged() {
+		return badnessNA, ""
+	}
+	if len(i.supplementaryGroups) > 0 {
+		return 1, ""
+	}
+	return 0, ""
+}
+
+func assessRemoveIPC(i *securityInfo) (uint64, string) {
+	if i.runsPrivileged() {
+		return badnessNA, ""
+	}
+	if i.removeIPC {
+		return 0, ""
+	}
+	return 1, ""
+}
+
+func assessProtectHome(i *securityInfo) (uint64, string) {
+	switch i.protectHome {
+	case "yes":
+		return 0, "Service has no access to home directories"
+	case "tmpfs":
+		return 1, "Service has access to fake empty home directories"
+	case "read-only":
+		return 5, "Service has read-only access to home directories"
+	default:
+		return 10, "Service has full access to home directories"
+	}
+}
+
+func assessProtectSystem(i *securityInfo) (uint64, string) {
+	switch i.protectSystem {
+	case "strict":
+		return 0, "Service has strict read-only access to the OS file hierarchy"
+	case "full":
+		return 3, "Service has very limited write access to the OS file hierarchy"
+	case "yes":
+		return 5, "Service has limited write access to the OS file hierarchy"
+	default:
+		return 10, "Service has full access to the OS file hierarchy"
+	}
+}
+
+func assessRootDirectory(i *securityInfo) (uint64, string) {
+	if (i.rootDirectory != "" && i.rootDirectory != "/") || i.rootImage != "" {
+		return 0, ""
+	}
+	return 1, ""
+}
+
+func assessUMask(i *securityInfo) (uint64, string) {
+	switch {
+	case i.umask&0o002 == 0:
+		return 10, "Files created by service are world-writable by default"
+	case i.umask&0o004 == 0:
+		return 5, "Files created by service are world-readable by default"
+	case i.umask&0o020 == 0:
+		return 2, "Files created by service are group-writable by default"
+	case i.umask&0o040 == 0:
+		return 1, "Files created by service are group-readable by default"
+	default:
+		return 0, "Files created by service are accessible only by service's own user by default"
+	}
+}
+
+func assessProtectProc(i *securityInfo) (uint64, string) {
+	switch i.protectProc {
+	case "invisible", "ptraceable":
+		return 0, ""
+	case "noaccess":
+		return 1, ""
+	default:
+		return 3, ""
+	}
+}
+
+func assessSystemCallArchitectures(i *securityInfo) (uint64, string) {
+	switch {
+	case len(i.syscallArchitectures) == 0:
+		return 10, "Service may execute system calls with all ABIs"
+	case len(i.syscallArchitectures) == 1 && i.syscallArchitectures["native"]:
+		return 0, "Service may execute system calls only with native ABI"
+	default:
+		return 8, "Service may execute system calls with multiple ABIs"
+	}
+}
+
+func assessIPAddressDeny(i *securityInfo) (uint64, string) {
+	switch {
+	case i.ipFiltersCustom:
+		return 0, "Service defines custom ingress/egress IP filters with BPF programs"
+	case !i.ipDenyAll():
+		return 10, "Service does not define an IP address allow list"
+	case i.ipAllowOther:
+		return 5, "Service defines IP address allow list with non-localhost entries"
+	case i.ipAllowLocalhost:
+		return 2, "Service defines IP address allow list with only localhost entries"
+	default:
+		return 0, "Service blocks all IP address ranges"
+	}
+}
+
+func assessDeviceAllow(i *securityInfo) (uint64, string) {
+	if i.devicePolicy != "strict" && i.devicePolicy != "closed" {
+		return 10, "Service has no device ACL"
+	}
+	if len(i.deviceAllow) == 0 {
+		return 0, "Service has a minimal device ACL"
+	}
+	// systemd prepends entries, so they are listed newest first.
+	entries := make([]string, 0, len(i.deviceAllow))
+	for j := len(i.deviceAllow) - 1; j >= 0; j-- {
+		entries = append(entries, i.deviceAllow[j])
+	}
+	return 5, "Service has a device ACL with some special devices: " + strings.Join(entries, " ")
+}
+
+var assessors = []assessor{
+	{
+		name: "User=/DynamicUser=", jsonField: "UserOrDynamicUser",
+		descBad: "Service runs as root user",
+		weight:  2000, rng: 10, assess: assessUser,
+		levels: []level{{"Service runs under as 'nobody'", 9}},
+	},
+	{
+		name: "SupplementaryGroups=", jsonField: "SupplementaryGroups",
+		descGood: "Service has no supplementary groups",
+		descBad:  "Service runs with supplementary groups",
+		descNA:   "Service runs as root, option does not matter",
+		weight:   200, rng: 1, assess: assessSupplementaryGroups,
+	},
+	{
+		name: "PrivateDevices=", jsonField: "PrivateDevices",
+		descGood: "Service has no access to hardware devices",
+		descBad:  "Service potentially has access to hardware devices",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.privateDevices }),
+	},
+	{
+		name: "PrivateMounts=", jsonField: "PrivateMounts",
+		descGood: "Service cannot install system mounts",
+		descBad:  "Service may install system mounts",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.privateMounts }),
+	},
+	{
+		name: "PrivateNetwork=", jsonField: "PrivateNetwork",
+		descGood: "Service has no access to the host's network",
+		descBad:  "Service has access to the host's network",
+		weight:   2500, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.privateNetwork }),
+	},
+	{
+		name: "PrivateTmp=", jsonField: "PrivateTmp",
+		descGood: "Service has no access to other software's temporary files",
+		descBad:  "Service has access to other software's temporary files",
+		weight:   1000, rng: 1, defaultDependenciesOnly: true,
+		assess: boolCheck(func(i *securityInfo) bool { return i.privateTmp }),
+	},
+	{
+		name: "PrivateUsers=", jsonField: "PrivateUsers",
+		descGood: "Service does not have access to other users",
+		descBad:  "Service has access to other users",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.privateUsers }),
+	},
+	{
+		name: "ProtectControlGroups=", jsonField: "ProtectControlGroups",
+		descGood: "Service cannot modify the control group file system",
+		descBad:  "Service may modify the control group file system",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.protectControlGroups }),
+	},
+	{
+		name: "ProtectKernelModules=", jsonField: "ProtectKernelModules",
+		descGood: "Service cannot load or read kernel modules",
+		descBad:  "Service may load or read kernel modules",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.protectKernelModules }),
+	},
+	{
+		name: "ProtectKernelTunables=", jsonField: "ProtectKernelTunables",
+		descGood: "Service cannot alter kernel tunables (/proc/sys, …)",
+		descBad:  "Service may alter kernel tunables",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.protectKernelTunables }),
+	},
+	{
+		name: "ProtectKernelLogs=", jsonField: "ProtectKernelLogs",
+		descGood: "Service cannot read from or write to the kernel log ring buffer",
+		descBad:  "Service may read from or write to the kernel log ring buffer",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.protectKernelLogs }),
+	},
+	{
+		name: "ProtectClock=", jsonField: "ProtectClock",
+		descGood: "Service cannot write to the hardware clock or system clock",
+		descBad:  "Service may write to the hardware clock or system clock",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.protectClock }),
+	},
+	{
+		name: "ProtectHome=", jsonField: "ProtectHome",
+		weight: 1000, rng: 10, defaultDependenciesOnly: true, assess: assessProtectHome,
+		levels: []level{
+			{"Service has access to fake empty home directories", 1},
+			{"Service has read-only access to home directories", 5},
+			{"Service has full access to home directories", 10},
+		},
+	},
+	{
+		name: "ProtectHostname=", jsonField: "ProtectHostname",
+		descGood: "Service cannot change system host/domainname",
+		descBad:  "Service may change system host/domainname",
+		weight:   50, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.protectHostname }),
+	},
+	{
+		name: "ProtectSystem=", jsonField: "ProtectSystem",
+		weight: 1000, rng: 10, defaultDependenciesOnly: true, assess: assessProtectSystem,
+		levels: []level{
+			{"Service has very limited write access to the OS file hierarchy", 3},
+			{"Service has limited write access to the OS file hierarchy", 5},
+			{"Service has full access to the OS file hierarchy", 10},
+		},
+	},
+	{
+		name: "RootDirectory=/RootImage=", jsonField: "RootDirectoryOrRootImage",
+		descGood: "Service has its own root directory/image",
+		descBad:  "Service runs within the host's root directory",
+		weight:   200, rng: 1, defaultDependenciesOnly: true, assess: assessRootDirectory,
+	},
+	{
+		name: "LockPersonality=", jsonField: "LockPersonality",
+		descGood: "Service cannot change ABI personality",
+		descBad:  "Service may change ABI personality",
+		weight:   100, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.lockPersonality }),
+	},
+	{
+		name: "MemoryDenyWriteExecute=", jsonField: "MemoryDenyWriteExecute",
+		descGood: "Service cannot create writable executable memory mappings",
+		descBad:  "Service may create writable executable memory mappings",
+		weight:   100, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.memoryDenyWriteExecute }),
+	},
+	{
+		name: "NoNewPrivileges=", jsonField: "NoNewPrivileges",
+		descGood: "Service processes cannot acquire new privileges",
+		descBad:  "Service processes may acquire new privileges",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.noNewPrivileges }),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_ADMIN", jsonField: "CapabilityBoundingSet_CAP_SYS_ADMIN",
+		descGood: "Service has no administrator privileges",
+		descBad:  "Service has administrator privileges",
+		weight:   1500, rng: 1, assess: capabilityCheck("CAP_SYS_ADMIN"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SET(UID|GID|PCAP)", jsonField: "CapabilityBoundingSet_CAP_SET_UID_GID_PCAP",
+		descGood: "Service cannot change UID/GID identities/capabilities",
+		descBad:  "Service may change UID/GID identities/capabilities",
+		weight:   1500, rng: 1, assess: capabilityCheck("CAP_SETUID", "CAP_SETGID", "CAP_SETPCAP"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_PTRACE", jsonField: "CapabilityBoundingSet_CAP_SYS_PTRACE",
+		descGood: "Service has no ptrace() debugging abilities",
+		descBad:  "Service has ptrace() debugging abilities",
+		weight:   1500, rng: 1, assess: capabilityCheck("CAP_SYS_PTRACE"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_TIME", jsonField: "CapabilityBoundingSet_CAP_SYS_TIME",
+		descGood: "Service processes cannot change the system clock",
+		descBad:  "Service processes may change the system clock",
+		weight:   1000, rng: 1, assess: capabilityCheck("CAP_SYS_TIME"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_NET_ADMIN", jsonField: "CapabilityBoundingSet_CAP_NET_ADMIN",
+		descGood: "Service has no network configuration privileges",
+		descBad:  "Service has network configuration privileges",
+		weight:   1000, rng: 1, assess: capabilityCheck("CAP_NET_ADMIN"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_RAWIO", jsonField: "CapabilityBoundingSet_CAP_SYS_RAWIO",
+		descGood: "Service has no raw I/O access",
+		descBad:  "Service has raw I/O access",
+		weight:   1000, rng: 1, assess: capabilityCheck("CAP_SYS_RAWIO"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_MODULE", jsonField: "CapabilityBoundingSet_CAP_SYS_MODULE",
+		descGood: "Service cannot load kernel modules",
+		descBad:  "Service may load kernel modules",
+		weight:   1000, rng: 1, assess: capabilityCheck("CAP_SYS_MODULE"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_AUDIT_*", jsonField: "CapabilityBoundingSet_CAP_AUDIT",
+		descGood: "Service has no audit subsystem access",
+		descBad:  "Service has audit subsystem access",
+		weight:   500, rng: 1, assess: capabilityCheck("CAP_AUDIT_CONTROL", "CAP_AUDIT_READ", "CAP_AUDIT_WRITE"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYSLOG", jsonField: "CapabilityBoundingSet_CAP_SYSLOG",
+		descGood: "Service has no access to kernel logging",
+		descBad:  "Service has access to kernel logging",
+		weight:   500, rng: 1, assess: capabilityCheck("CAP_SYSLOG"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_(NICE|RESOURCE)", jsonField: "CapabilityBoundingSet_CAP_SYS_NICE_RESOURCE",
+		descGood: "Service has no privileges to change resource use parameters",
+		descBad:  "Service has privileges to change resource use parameters",
+		weight:   500, rng: 1, assess: capabilityCheck("CAP_SYS_NICE", "CAP_SYS_RESOURCE"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_MKNOD", jsonField: "CapabilityBoundingSet_CAP_MKNOD",
+		descGood: "Service cannot create device nodes",
+		descBad:  "Service may create device nodes",
+		weight:   500, rng: 1, assess: capabilityCheck("CAP_MKNOD"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_(CHOWN|FSETID|SETFCAP)", jsonField: "CapabilityBoundingSet_CAP_CHOWN_FSETID_SETFCAP",
+		descGood: "Service cannot change file ownership/access mode/capabilities",
+		descBad:  "Service may change file ownership/access mode/capabilities unrestricted",
+		weight:   1000, rng: 1, assess: capabilityCheck("CAP_CHOWN", "CAP_FSETID", "CAP_SETFCAP"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_(DAC_*|FOWNER|IPC_OWNER)", jsonField: "CapabilityBoundingSet_CAP_DAC_FOWNER_IPC_OWNER",
+		descGood: "Service cannot override UNIX file/IPC permission checks",
+		descBad:  "Service may override UNIX file/IPC permission checks",
+		weight:   1000, rng: 1, assess: capabilityCheck("CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER", "CAP_IPC_OWNER"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_KILL", jsonField: "CapabilityBoundingSet_CAP_KILL",
+		descGood: "Service cannot send UNIX signals to arbitrary processes",
+		descBad:  "Service may send UNIX signals to arbitrary processes",
+		weight:   500, rng: 1, assess: capabilityCheck("CAP_KILL"),
+	},
+	{
+		// The stray ")" in the JSON field name is systemd's.
+		name: "CapabilityBoundingSet=~CAP_NET_(BIND_SERVICE|BROADCAST|RAW)", jsonField: "CapabilityBoundingSet_CAP_NET_BIND_SERVICE_BROADCAST_RAW)",
+		descGood: "Service has no elevated networking privileges",
+		descBad:  "Service has elevated networking privileges",
+		weight:   500, rng: 1, assess: capabilityCheck("CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST", "CAP_NET_RAW"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_BOOT", jsonField: "CapabilityBoundingSet_CAP_SYS_BOOT",
+		descGood: "Service cannot issue reboot()",
+		descBad:  "Service may issue reboot()",
+		weight:   100, rng: 1, assess: capabilityCheck("CAP_SYS_BOOT"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_MAC_*", jsonField: "CapabilityBoundingSet_CAP_MAC",
+		descGood: "Service cannot adjust SMACK MAC",
+		descBad:  "Service may adjust SMACK MAC",
+		weight:   100, rng: 1, assess: capabilityCheck("CAP_MAC_ADMIN", "CAP_MAC_OVERRIDE"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_LINUX_IMMUTABLE", jsonField: "CapabilityBoundingSet_CAP_LINUX_IMMUTABLE",
+		descGood: "Service cannot mark files immutable",
+		descBad:  "Service may mark files immutable",
+		weight:   75, rng: 1, assess: capabilityCheck("CAP_LINUX_IMMUTABLE"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_IPC_LOCK", jsonField: "CapabilityBoundingSet_CAP_IPC_LOCK",
+		descGood: "Service cannot lock memory into RAM",
+		descBad:  "Service may lock memory into RAM",
+		weight:   50, rng: 1, assess: capabilityCheck("CAP_IPC_LOCK"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_CHROOT", jsonField: "CapabilityBoundingSet_CAP_SYS_CHROOT",
+		descGood: "Service cannot issue chroot()",
+		descBad:  "Service may issue chroot()",
+		weight:   50, rng: 1, assess: capabilityCheck("CAP_SYS_CHROOT"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_BLOCK_SUSPEND", jsonField: "CapabilityBoundingSet_CAP_BLOCK_SUSPEND",
+		descGood: "Service cannot establish wake locks",
+		descBad:  "Service may establish wake locks",
+		weight:   25, rng: 1, assess: capabilityCheck("CAP_BLOCK_SUSPEND"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_WAKE_ALARM", jsonField: "CapabilityBoundingSet_CAP_WAKE_ALARM",
+		descGood: "Service cannot program timers that wake up the system",
+		descBad:  "Service may program timers that wake up the system",
+		weight:   25, rng: 1, assess: capabilityCheck("CAP_WAKE_ALARM"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_LEASE", jsonField: "CapabilityBoundingSet_CAP_LEASE",
+		descGood: "Service cannot create file leases",
+		descBad:  "Service may create file leases",
+		weight:   25, rng: 1, assess: capabilityCheck("CAP_LEASE"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_TTY_CONFIG", jsonField: "CapabilityBoundingSet_CAP_SYS_TTY_CONFIG",
+		descGood: "Service cannot issue vhangup()",
+		descBad:  "Service may issue vhangup()",
+		weight:   25, rng: 1, assess: capabilityCheck("CAP_SYS_TTY_CONFIG"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_SYS_PACCT", jsonField: "CapabilityBoundingSet_CAP_SYS_PACCT",
+		descGood: "Service cannot use acct()",
+		descBad:  "Service may use acct()",
+		weight:   25, rng: 1, assess: capabilityCheck("CAP_SYS_PACCT"),
+	},
+	{
+		name: "CapabilityBoundingSet=~CAP_BPF", jsonField: "CapabilityBoundingSet_CAP_BPF",
+		descGood: "Service may not load BPF programs",
+		descBad:  "Service may load BPF programs",
+		weight:   25, rng: 1, assess: capabilityCheck("CAP_BPF"),
+	},
+	{
+		name: "UMask=", jsonField: "UMask",
+		weight: 100, rng: 10, assess: assessUMask,
+		levels: []level{
+			{"Files created by service are world-writable by default", 10},
+			{"Files created by service are world-readable by default", 5},
+			{"Files created by service are group-writable by default", 2},
+			{"Files created by service are group-readable by default", 1},
+		},
+	},
+	{
+		name: "KeyringMode=", jsonField: "KeyringMode",
+		descGood: "Service doesn't share key material with other services",
+		descBad:  "Service shares key material with other service",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.keyringMode == "private" }),
+	},
+	{
+		name: "ProtectProc=", jsonField: "ProtectProc",
+		descGood: "Service has restricted access to process tree (/proc hidepid=)",
+		descBad:  "Service has full access to process tree (/proc hidepid=)",
+		weight:   1000, rng: 3, assess: assessProtectProc,
+		levels: []level{{"", 1}},
+	},
+	{
+		name: "ProcSubset=", jsonField: "ProcSubset",
+		descGood: "Service has no access to non-process /proc files (/proc subset=)",
+		descBad:  "Service has full access to non-process /proc files (/proc subset=)",
+		weight:   10, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.procSubset == "pid" }),
+	},
+	{
+		name: "NotifyAccess=", jsonField: "NotifyAccess",
+		descGood: "Service child processes cannot alter service state",
+		descBad:  "Service child processes may alter service state",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.notifyAccess != "all" }),
+	},
+	{
+		name: "RemoveIPC=", jsonField: "RemoveIPC",
+		descGood: "Service user cannot leave SysV IPC objects around",
+		descBad:  "Service user may leave SysV IPC objects around",
+		descNA:   "Service runs as root, option does not apply",
+		weight:   100, rng: 1, assess: assessRemoveIPC,
+	},
+	{
+		name: "Delegate=", jsonField: "Delegate",
+		descGood: "Service does not maintain its own delegated control group subtree",
+		descBad:  "Service maintains its own delegated control group subtree",
+		weight:   100, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return !i.delegate }),
+	},
+	{
+		name: "RestrictRealtime=", jsonField: "RestrictRealtime",
+		descGood: "Service realtime scheduling access is restricted",
+		descBad:  "Service may acquire realtime scheduling",
+		weight:   500, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.restrictRealtime }),
+	},
+	{
+		name: "RestrictSUIDSGID=", jsonField: "RestrictSUIDSGID",
+		descGood: "SUID/SGID file creation by service is restricted",
+		descBad:  "Service may create SUID/SGID files",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.restrictSUIDSGID }),
+	},
+	{
+		name: "RestrictNamespaces=~user", jsonField: "RestrictNamespaces_user",
+		descGood: "Service cannot create user namespaces",
+		descBad:  "Service may create user namespaces",
+		weight:   1500, rng: 1, assess: namespaceCheck(namespaceFlags["user"]),
+	},
+	{
+		name: "RestrictNamespaces=~mnt", jsonField: "RestrictNamespaces_mnt",
+		descGood: "Service cannot create file system namespaces",
+		descBad:  "Service may create file system namespaces",
+		weight:   500, rng: 1, assess: namespaceCheck(namespaceFlags["mnt"]),
+	},
+	{
+		name: "RestrictNamespaces=~ipc", jsonField: "RestrictNamespaces_ipc",
+		descGood: "Service cannot create IPC namespaces",
+		descBad:  "Service may create IPC namespaces",
+		weight:   500, rng: 1, assess: namespaceCheck(namespaceFlags["ipc"]),
+	},
+	{
+		name: "RestrictNamespaces=~pid", jsonField: "RestrictNamespaces_pid",
+		descGood: "Service cannot create process namespaces",
+		descBad:  "Service may create process namespaces",
+		weight:   500, rng: 1, assess: namespaceCheck(namespaceFlags["pid"]),
+	},
+	{
+		name: "RestrictNamespaces=~cgroup", jsonField: "RestrictNamespaces_cgroup",
+		descGood: "Service cannot create cgroup namespaces",
+		descBad:  "Service may create cgroup namespaces",
+		weight:   500, rng: 1, assess: namespaceCheck(namespaceFlags["cgroup"]),
+	},
+	{
+		name: "RestrictNamespaces=~net", jsonField: "RestrictNamespaces_net",
+		descGood: "Service cannot create network namespaces",
+		descBad:  "Service may create network namespaces",
+		weight:   500, rng: 1, assess: namespaceCheck(namespaceFlags["net"]),
+	},
+	{
+		name: "RestrictNamespaces=~uts", jsonField: "RestrictNamespaces_uts",
+		descGood: "Service cannot create hostname namespaces",
+		descBad:  "Service may create hostname namespaces",
+		weight:   100, rng: 1, assess: namespaceCheck(namespaceFlags["uts"]),
+	},
+	{
+		name: "RestrictAddressFamilies=~AF_(INET|INET6)", jsonField: "RestrictAddressFamilies_AF_INET_INET6",
+		descGood: "Service cannot allocate Internet sockets",
+		descBad:  "Service may allocate Internet sockets",
+		weight:   1500, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.restrictAF.inet }),
+	},
+	{
+		name: "RestrictAddressFamilies=~AF_UNIX", jsonField: "RestrictAddressFamilies_AF_UNIX",
+		descGood: "Service cannot allocate local sockets",
+		descBad:  "Service may allocate local sockets",
+		weight:   25, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.restrictAF.unix }),
+	},
+	{
+		name: "RestrictAddressFamilies=~AF_NETLINK", jsonField: "RestrictAddressFamilies_AF_NETLINK",
+		descGood: "Service cannot allocate netlink sockets",
+		descBad:  "Service may allocate netlink sockets",
+		weight:   200, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.restrictAF.netlink }),
+	},
+	{
+		name: "RestrictAddressFamilies=~AF_PACKET", jsonField: "RestrictAddressFamilies_AF_PACKET",
+		descGood: "Service cannot allocate packet sockets",
+		descBad:  "Service may allocate packet sockets",
+		weight:   1000, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.restrictAF.packet }),
+	},
+	{
+		name: "RestrictAddressFamilies=~…", jsonField: "RestrictAddressFamilies_OTHER",
+		descGood: "Service cannot allocate exotic sockets",
+		descBad:  "Service may allocate exotic sockets",
+		weight:   1250, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.restrictAF.other }),
+	},
+	{
+		name: "SystemCallArchitectures=", jsonField: "SystemCallArchitectures",
+		weight: 1000, rng: 10, assess: assessSystemCallArchitectures,
+		levels: []level{
+			{"Service may execute system calls with all ABIs", 10},
+			{"Service may execute system calls with multiple ABIs", 8},
+		},
+	},
+	{name: "SystemCallFilter=~@swap", jsonField: "SystemCallFilter_swap", weight: 1000, rng: 10, assess: syscallFilterCheck("@swap"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@obsolete", jsonField: "SystemCallFilter_obsolete", weight: 250, rng: 10, assess: syscallFilterCheck("@obsolete"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@clock", jsonField: "SystemCallFilter_clock", weight: 1000, rng: 10, assess: syscallFilterCheck("@clock"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@cpu-emulation", jsonField: "SystemCallFilter_cpu_emulation", weight: 250, rng: 10, assess: syscallFilterCheck("@cpu-emulation"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@debug", jsonField: "SystemCallFilter_debug", weight: 1000, rng: 10, assess: syscallFilterCheck("@debug"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@mount", jsonField: "SystemCallFilter_mount", weight: 1000, rng: 10, assess: syscallFilterCheck("@mount"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@module", jsonField: "SystemCallFilter_module", weight: 1000, rng: 10, assess: syscallFilterCheck("@module"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@raw-io", jsonField: "SystemCallFilter_raw_io", weight: 1000, rng: 10, assess: syscallFilterCheck("@raw-io"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@reboot", jsonField: "SystemCallFilter_reboot", weight: 1000, rng: 10, assess: syscallFilterCheck("@reboot"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@privileged", jsonField: "SystemCallFilter_privileged", weight: 700, rng: 10, assess: syscallFilterCheck("@privileged"), levels: syscallFilterLevels},
+	{name: "SystemCallFilter=~@resources", jsonField: "SystemCallFilter_resources", weight: 700, rng: 10, assess: syscallFilterCheck("@resources"), levels: syscallFilterLevels},
+	{
+		name: "IPAddressDeny=", jsonField: "IPAddressDeny",
+		weight: 1000, rng: 10, assess: assessIPAddressDeny,
+		levels: []level{
+			{"Service does not define an IP address allow list", 10},
+			{"Service defines IP address allow list with non-localhost entries", 5},
+			{"Service defines IP address allow list with only localhost entries", 2},
+		},
+	},
+	{
+		name: "DeviceAllow=", jsonField: "DeviceAllow",
+		weight: 1000, rng: 10, assess: assessDeviceAllow,
+		levels: []level{
+			{"Service has no device ACL", 10},
+			{"Service has a device ACL with some special devices", 5},
+		},
+	},
+	{
+		name: "AmbientCapabilities=", jsonField: "AmbientCapabilities",
+		descGood: "Service process does not receive ambient capabilities",
+		descBad:  "Service process receives ambient capabilities",
+		weight:   500, rng: 1, assess: boolCheck(func(i *securityInfo) bool { return i.ambientCapabilities == 0 }),
+	},
+}
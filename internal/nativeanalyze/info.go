@@ -0,0 +1,637 @@
+package nativeanalyze
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/unitfile"
+)
+
+// unitDirs are searched in order, like systemd's system unit path.
+var unitDirs = []string{
+	"etc/systemd/system",
+	"run/systemd/system",
+	"usr/lib/systemd/system",
+	"lib/systemd/system",
+}
+
+// securityInfo is the effective security-relevant configuration of a service,
+// after systemd's implied settings have been applied.
+type securityInfo struct {
+	defaultDependencies bool
+
+	user                string
+	dynamicUser         bool
+	supplementaryGroups []string
+
+	privateDevices        bool
+	privateMounts         bool
+	privateNetwork        bool
+	privateTmp            bool
+	privateUsers          bool
+	protectControlGroups  bool
+	protectKernelModules  bool
+	protectKernelTunables bool
+	protectKernelLogs     bool
+	protectClock          bool
+	protectHostname       bool
+	protectHome           string
+	protectSystem         string
+	rootDirectory         string
+	rootImage             string
+
+	lockPersonality        bool
+	memoryDenyWriteExecute bool
+	noNewPrivileges        bool
+	restrictRealtime       bool
+	restrictSUIDSGID       bool
+	removeIPC              bool
+	delegate               bool
+
+	capabilityBoundingSet uint64
+	ambientCapabilities   uint64
+
+	umask        uint64
+	keyringMode  string
+	protectProc  string
+	procSubset   string
+	notifyAccess string
+
+	restrictNamespaces uint64
+
+	// addressFamilies is nil until RestrictAddressFamilies= is first set.
+	addressFamilies          map[int]bool
+	addressFamiliesAllowList bool
+	restrictAF               struct{ inet, unix, netlink, packet, other bool }
+
+	syscallArchitectures map[string]bool
+	// syscallFilter is nil until SystemCallFilter= is first set.
+	syscallFilter    map[string]bool
+	syscallAllowList bool
+
+	ipFiltersCustom  bool
+	ipDeny           []netip.Prefix
+	ipAllowLocalhost bool
+	ipAllowOther     bool
+
+	devicePolicy string
+	deviceAllow  []string
+}
+
+func newSecurityInfo() *securityInfo {
+	return &securityInfo{
+		defaultDependencies:   true,
+		protectHome:           "no",
+		protectSystem:         "no",
+		capabilityBoundingSet: capAll,
+		umask:                 0o022,
+		keyringMode:           "private",
+		protectProc:           "default",
+		procSubset:            "all",
+		notifyAccess:          "none",
+		restrictNamespaces:    namespaceInitial,
+		devicePolicy:          "auto",
+	}
+}
+
+func isRootUser(user string) bool {
+	return user == "root" || user == "0"
+}
+
+// runsPrivileged reports whether the service runs as root, in which case the
+// user-scoped checks don't apply.
+func (i *securityInfo) runsPrivileged() bool {
+	if isRootUser(i.user) {
+		return true
+	}
+	if i.dynamicUser {
+		return false
+	}
+	return i.user == ""
+}
+
+func (i *securityInfo) ipDenyAll() bool {
+	var v4, v6 bool
+	for _, p := range i.ipDeny {
+		if p.Bits() != 0 {
+			continue
+		}
+		if p.Addr().Is4() {
+			v4 = true
+		} else {
+			v6 = true
+		}
+	}
+	return v4 && v6
+}
+
+// loadUnit reads unitName (and its drop-ins) from the offline root. Instances
+// fall back to their template and also pick up the template's drop-ins.
+func loadUnit(root, unitName string) (unitfile.File, error) {
+	names := []string{unitName}
+	if at := strings.Index(unitName, "@"); at >= 0 {
+		ext := filepath.Ext(unitName)
+		if template := unitName[:at+1] + ext; template != unitName {
+			names = append(names, template)
+		}
+	}
+
+	var main string
+	for _, name := range names {
+		for _, dir := range unitDirs {
+			p := filepath.Join(root, dir, name)
+			if _, err := os.Stat(p); err == nil {
+				main = p
+				break
+			}
+		}
+		if main != "" {
+			break
+		}
+	}
+	if main == "" {
+		return unitfile.File{}, fmt.Errorf("unit %s not found in %s", unitName, root)
+	}
+
+	// Drop-ins are applied ordered by file name across all directories; for
+	// the same file name, the instance and the earlier directory win.
+	var dirs []string
+	for _, name := range names {
+		for _, dir := range unitDirs {
+			dirs = append(dirs, filepath.Join(root, dir, name+".d"))
+		}
+	}
+	return unitfile.ParseWithDropInDirs(main, dirs...)
+}
+
+// buildSecurityInfo applies the unit's [Unit] and [Service] assignments in
+// order, then the settings systemd implies from them.
+func buildSecurityInfo(unitName string, f unitfile.File) *securityInfo {
+	i := newSecurityInfo()
+	for _, e := range f.Entries {
+		value := expandSpecifiers(e.Value, unitName)
+		switch e.Section {
+		case "Unit":
+			if e.Key == "DefaultDependencies" {
+				setBool(&i.defaultDependencies, value)
+			}
+		case "Service":
+			i.apply(e.Key, value)
+		}
+	}
+	i.patch(unitName)
+	return i
+}
+
+func (i *securityInfo) apply(key, value string) {
+	switch key {
+	case "User":
+		i.user = value
+	case "DynamicUser":
+		setBool(&i.dynamicUser, value)
+	case "SupplementaryGroups":
+		if value == "" {
+			i.supplementaryGroups = nil
+			return
+		}
+		i.supplementaryGroups = append(i.supplementaryGroups, splitWords(value)...)
+	case "PrivateDevices":
+		setBool(&i.privateDevices, value)
+	case "PrivateMounts":
+		setBool(&i.privateMounts, value)
+	case "PrivateNetwork":
+		setBool(&i.privateNetwork, value)
+	case "PrivateTmp":
+		setBool(&i.privateTmp, value)
+	case "PrivateUsers":
+		setBool(&i.privateUsers, value)
+	case "ProtectControlGroups":
+		setBool(&i.protectControlGroups, value)
+	case "ProtectKernelModules":
+		setBool(&i.protectKernelModules, value)
+	case "ProtectKernelTunables":
+		setBool(&i.protectKernelTunables, value)
+	case "ProtectKernelLogs":
+		setBool(&i.protectKernelLogs, value)
+	case "ProtectClock":
+		setBool(&i.protectClock, value)
+	case "ProtectHostname":
+		setBool(&i.protectHostname, value)
+	case "ProtectHome":
+		setEnumOrBool(&i.protectHome, value, "read-only", "tmpfs")
+	case "ProtectSystem":
+		setEnumOrBool(&i.protectSystem, value, "full", "strict")
+	case "RootDirectory":
+		i.rootDirectory = value
+	case "RootImage":
+		i.rootImage = value
+	case "LockPersonality":
+		setBool(&i.lockPersonality, value)
+	case "MemoryDenyWriteExecute":
+		setBool(&i.memoryDenyWriteExecute, value)
+	case "NoNewPrivileges":
+		setBool(&i.noNewPrivileges, value)
+	case "RestrictRealtime":
+		setBool(&i.restrictRealtime, value)
+	case "RestrictSUIDSGID":
+		setBool(&i.restrictSUIDSGID, value)
+	case "RemoveIPC":
+		setBool(&i.removeIPC, value)
+	case "Delegate":
+		// An empty value or a controller list enables delegation.
+		if b, ok := parseBool(value); ok {
+			i.delegate = b
+		} else {
+			i.delegate = true
+		}
+	case "CapabilityBoundingSet":
+		applyCapabilities(&i.capabilityBoundingSet, capAll, value)
+	case "AmbientCapabilities":
+		applyCapabilities(&i.ambientCapabilities, 0, value)
+	case "UMask":
+		if m, err := strconv.ParseUint(value, 8, 32); err == nil && m <= 0o7777 {
+			i.umask = m
+		}
+	case "KeyringMode":
+		setEnum(&i.keyringMode, value, "inherit", "private", "shared")
+	case "ProtectProc":
+		setEnum(&i.protectProc, value, "default", "noaccess", "invisible", "ptraceable")
+	case "ProcSubset":
+		setEnum(&i.procSubset, value, "all", "pid")
+	case "NotifyAccess":
+		setEnum(&i.notifyAccess, value, "none", "main", "exec", "all")
+	case "RestrictNamespaces":
+		i.applyRestrictNamespaces(value)
+	case "RestrictAddressFamilies":
+		i.applyAddressFamilies(value)
+	case "SystemCallArchitectures":
+		if value == "" {
+			i.syscallArchitectures = nil
+			return
+		}
+		for _, w := range splitWords(value) {
+			if !seccompArchitectures[w] {
+				continue
+			}
+			if i.syscallArchitectures == nil {
+				i.syscallArchitectures = map[string]bool{}
+			}
+			i.syscallArchitectures[w] = true
+		}
+	case "SystemCallFilter":
+		i.applySyscallFilter(value)
+	case "IPAddressDeny":
+		if value == "" {
+			i.ipDeny = nil
+			return
+		}
+		i.ipDeny = append(i.ipDeny, parseIPPrefixes(value)...)
+	case "IPAddressAllow":
+		if value == "" {
+			i.ipAllowLocalhost, i.ipAllowOther = false, false
+			return
+		}
+		for _, p := range parseIPPrefixes(value) {
+			if isLocalhost(p.Addr()) {
+				i.ipAllowLocalhost = true
+			} else {
+				i.ipAllowOther = true
+			}
+		}
+	case "IPIngressFilterPath", "IPEgressFilterPath":
+		if value != "" {
+			i.ipFiltersCustom = true
+		}
+	case "DevicePolicy":
+		setEnum(&i.devicePolicy, value, "auto", "closed", "strict")
+	case "DeviceAllow":
+		i.applyDeviceAllow(value)
+	}
+}
+
+// patch applies the settings systemd derives from others when loading a
+// service (unit_patch_contexts).
+func (i *securityInfo) patch(unitName string) {
+	if i.dynamicUser {
+		if i.user == "" {
+			i.user = strings.TrimSuffix(unitName, filepath.Ext(unitName))
+		}
+		i.privateTmp = true
+		i.removeIPC = true
+		i.protectSystem = "strict"
+		if i.protectHome == "no" {
+			i.protectHome = "read-only"
+		}
+		i.noNewPrivileges = true
+		i.restrictSUIDSGID = true
+	}
+
+	if i.privateDevices {
+		i.capabilityBoundingSet &^= capBit("CAP_MKNOD") | capBit("CAP_SYS_RAWIO")
+	}
+	if i.protectKernelModules {
+		i.capabilityBoundingSet &^= capBit("CAP_SYS_MODULE")
+	}
+	if i.protectKernelLogs {
+		i.capabilityBoundingSet &^= capBit("CAP_SYSLOG")
+	}
+	if i.protectClock {
+		i.capabilityBoundingSet &^= capBit("CAP_SYS_TIME") | capBit("CAP_WAKE_ALARM")
+	}
+
+	if i.privateDevices && i.devicePolicy == "auto" {
+		i.devicePolicy = "closed"
+	}
+	if i.rootImage != "" && (i.devicePolicy != "auto" || len(i.deviceAllow) > 0) {
+		i.deviceAllow = append(i.deviceAllow,
+			"/dev/loop-control:rw", "/dev/mapper/control:rw",
+			"block-loop:rwm", "block-blkext:rwm", "block-device-mapper:rwm")
+	}
+	if i.protectClock && (i.devicePolicy != "auto" || len(i.deviceAllow) > 0) {
+		i.deviceAllow = append(i.deviceAllow, "char-rtc:r")
+	}
+
+	// On an allow list every family starts out restricted and listed ones are
+	// allowed; on a deny list it's the other way around.
+	if i.addressFamilies != nil {
+		r := i.addressFamiliesAllowList
+		i.restrictAF.inet, i.restrictAF.unix, i.restrictAF.netlink, i.restrictAF.packet, i.restrictAF.other = r, r, r, r, r
+	}
+	for af := range i.addressFamilies {
+		restricted := !i.addressFamiliesAllowList
+		switch af {
+		case 0:
+		case afInet, afInet6:
+			i.restrictAF.inet = restricted
+		case afUnix:
+			i.restrictAF.unix = restricted
+		case afNetlink:
+			i.restrictAF.netlink = restricted
+		case afPacket:
+			i.restrictAF.packet = restricted
+		default:
+			i.restrictAF.other = restricted
+		}
+	}
+}
+
+func (i *securityInfo) applyRestrictNamespaces(value string) {
+	if value == "" {
+		i.restrictNamespaces = namespaceInitial
+		return
+	}
+	if b, ok := parseBool(value); ok {
+		if b {
+			i.restrictNamespaces = 0
+		} else {
+			i.restrictNamespaces = namespaceAll
+		}
+		return
+	}
+	invert := strings.HasPrefix(value, "~")
+	value = strings.TrimPrefix(value, "~")
+
+	var flags uint64
+	for _, w := range splitWords(value) {
+		f, ok := namespaceFlags[w]
+		if !ok {
+			return
+		}
+		flags |= f
+	}
+	switch {
+	case i.restrictNamespaces == namespaceInitial && invert:
+		i.restrictNamespaces = namespaceAll &^ flags
+	case i.restrictNamespaces == namespaceInitial:
+		i.restrictNamespaces = flags
+	case invert:
+		i.restrictNamespaces &^= flags
+	default:
+		i.restrictNamespaces |= flags
+	}
+}
+
+// applyAddressFamilies tracks the family set the way systemd does: an
+// assignment to an empty set decides between allow and deny list, later ones
+// add to or remove from it.
+func (i *securityInfo) applyAddressFamilies(value string) {
+	if value == "" {
+		i.addressFamilies = nil
+		i.addressFamiliesAllowList = false
+		return
+	}
+	if value == "none" {
+		i.addressFamilies = map[int]bool{}
+		i.addressFamiliesAllowList = true
+		return
+	}
+	invert := strings.HasPrefix(value, "~")
+	value = strings.TrimPrefix(value, "~")
+	if len(i.addressFamilies) == 0 {
+		i.addressFamilies = map[int]bool{}
+		i.addressFamiliesAllowList = !invert
+	}
+	for _, w := range splitWords(value) {
+		af, ok := addressFamilies[w]
+		if !ok {
+			continue
+		}
+		if !invert == i.addressFamiliesAllowList {
+			i.addressFamilies[af] = true
+		} else {
+			delete(i.addressFamilies, af)
+		}
+	}
+}
+
+func (i *securityInfo) applySyscallFilter(value string) {
+	if value == "" {
+		i.syscallFilter = nil
+		i.syscallAllowList = false
+		return
+	}
+	invert := strings.HasPrefix(value, "~")
+	value = strings.TrimPrefix(value, "~")
+	if i.syscallFilter == nil {
+		i.syscallFilter = map[string]bool{}
+		i.syscallAllowList = !invert
+		if !invert {
+			// An allow list always permits the @default set.
+			addSyscalls(i.syscallFilter, "@default", true)
+		}
+	}
+	add := !invert == i.syscallAllowList
+	for _, w := range splitWords(value) {
+		name, _, _ := strings.Cut(w, ":")
+		addSyscalls(i.syscallFilter, name, add)
+	}
+}
+
+func (i *securityInfo) applyDeviceAllow(value string) {
+	if value == "" {
+		i.deviceAllow = nil
+		return
+	}
+	words := splitWords(value)
+	path := words[0]
+	perms := strings.Join(words[1:], "")
+	if !strings.HasPrefix(path, "block-") && !strings.HasPrefix(path, "char-") && !strings.HasPrefix(path, "/dev/") {
+		return
+	}
+	if strings.Trim(perms, "rwm") != "" {
+		return
+	}
+	var p string
+	for _, c := range "rwm" {
+		if perms == "" || strings.ContainsRune(perms, c) {
+			p += string(c)
+		}
+	}
+	i.deviceAllow = append(i.deviceAllow, path+":"+p)
+}
+
+func parseIPPrefixes(value string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, w := range splitWords(value) {
+		switch w {
+		case "any":
+			out = append(out, netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0"))
+			continue
+		case "localhost":
+			out = append(out, netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128"))
+			continue
+		case "link-local":
+			out = append(out, netip.MustParsePrefix("169.254.0.0/16"), netip.MustParsePrefix("fe80::/64"))
+			continue
+		case "multicast":
+			out = append(out, netip.MustParsePrefix("224.0.0.0/4"), netip.MustParsePrefix("ff00::/8"))
+			continue
+		}
+		if p, err := netip.ParsePrefix(w); err == nil {
+			out = append(out, p)
+		} else if a, err := netip.ParseAddr(w); err == nil {
+			out = append(out, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	return out
+}
+
+func isLocalhost(a netip.Addr) bool {
+	if a.Is4() {
+		return a.As4()[0] == 127
+	}
+	return a == netip.IPv6Loopback()
+}
+
+// parseBool mirrors systemd's parse_boolean.
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "1", "yes", "y", "true", "t", "on":
+		return true, true
+	case "0", "no", "n", "false", "f", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// setBool updates dst unless value is not a boolean, which systemd ignores.
+func setBool(dst *bool, value string) {
+	if b, ok := parseBool(value); ok {
+		*dst = b
+	}
+}
+
+func setEnum(dst *string, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			*dst = value
+			return
+		}
+	}
+}
+
+// setEnumOrBool handles settings like ProtectSystem= that take a boolean or
+// one of a few extra values.
+func setEnumOrBool(dst *string, value string, extra ...string) {
+	if b, ok := parseBool(value); ok {
+		if b {
+			*dst = "yes"
+		} else {
+			*dst = "no"
+		}
+		return
+	}
+	setEnum(dst, value, extra...)
+}
+
+// splitWords splits a whitespace-separated list, honoring quotes.
+func splitWords(s string) []string {
+	var words []string
+	var cur strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words
+}
+
+// expandSpecifiers resolves the unit-name specifiers that matter for
+// security settings (e.g. User=%i).
+func expandSpecifiers(value, unitName string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+	base := strings.TrimSuffix(unitName, filepath.Ext(unitName))
+	prefix, instance, _ := strings.Cut(base, "@")
+	var b strings.Builder
+	for j := 0; j < len(value); j++ {
+		if value[j] != '%' || j+1 == len(value) {
+			b.WriteByte(value[j])
+			continue
+		}
+		j++
+		switch value[j] {
+		case 'i', 'I':
+			b.WriteString(instance)
+		case 'n':
+			b.WriteString(unitName)
+		case 'N':
+			b.WriteString(base)
+		case 'p', 'P':
+			b.WriteString(prefix)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(value[j])
+		}
+	}
+	return b.String()
+}
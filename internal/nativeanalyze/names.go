@@ -0,0 +1,123 @@
+package nativeanalyze
+
+import (
+	"strconv"
+	"strings"
+)
+
+// capabilityNames lists the Linux capabilities by number.
+var capabilityNames = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER",
+	"CAP_FSETID", "CAP_KILL", "CAP_SETGID", "CAP_SETUID",
+	"CAP_SETPCAP", "CAP_LINUX_IMMUTABLE", "CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_IPC_LOCK", "CAP_IPC_OWNER",
+	"CAP_SYS_MODULE", "CAP_SYS_RAWIO", "CAP_SYS_CHROOT", "CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT", "CAP_SYS_ADMIN", "CAP_SYS_BOOT", "CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE", "CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_MKNOD",
+	"CAP_LEASE", "CAP_AUDIT_WRITE", "CAP_AUDIT_CONTROL", "CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE", "CAP_MAC_ADMIN", "CAP_SYSLOG", "CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND", "CAP_AUDIT_READ", "CAP_PERFMON", "CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// capAll is the unrestricted capability bounding set.
+const capAll = ^uint64(0)
+
+// capabilityFromName accepts a capability name (any case) or number.
+func capabilityFromName(s string) (uint, bool) {
+	for i, n := range capabilityNames {
+		if strings.EqualFold(s, n) {
+			return uint(i), true
+		}
+	}
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil && n < 63 {
+		return uint(n), true
+	}
+	return 0, false
+}
+
+func capBit(name string) uint64 {
+	c, ok := capabilityFromName(name)
+	if !ok {
+		panic("unknown capability " + name)
+	}
+	return 1 << c
+}
+
+// applyCapabilities mirrors config_parse_capability_set: an assignment
+// replaces the initial (or emptied) set, later ones add or, with "~", remove.
+func applyCapabilities(set *uint64, initial uint64, value string) {
+	invert := strings.HasPrefix(value, "~")
+	value = strings.TrimPrefix(value, "~")
+	var sum uint64
+	for _, w := range splitWords(value) {
+		if c, ok := capabilityFromName(w); ok {
+			sum |= 1 << c
+		}
+	}
+	switch {
+	case sum == 0 || *set == initial:
+		if invert {
+			*set = ^sum
+		} else {
+			*set = sum
+		}
+	case invert:
+		*set &^= sum
+	default:
+		*set |= sum
+	}
+}
+
+// namespaceFlags maps RestrictNamespaces= names to their CLONE_NEW* flags.
+var namespaceFlags = map[string]uint64{
+	"cgroup": 0x02000000,
+	"ipc":    0x08000000,
+	"net":    0x40000000,
+	"mnt":    0x00020000,
+	"pid":    0x20000000,
+	"user":   0x10000000,
+	"uts":    0x04000000,
+}
+
+const (
+	namespaceAll = uint64(0x02000000 | 0x08000000 | 0x40000000 | 0x00020000 | 0x20000000 | 0x10000000 | 0x04000000)
+	// namespaceInitial is the unset value; like systemd's it allows everything.
+	namespaceInitial = ^uint64(0)
+)
+
+const (
+	afUnix    = 1
+	afInet    = 2
+	afInet6   = 10
+	afNetlink = 16
+	afPacket  = 17
+)
+
+// addressFamilies maps the AF_* names systemd accepts to their numbers.
+var addressFamilies = map[string]int{
+	"AF_UNSPEC": 0, "AF_UNIX": afUnix, "AF_LOCAL": afUnix, "AF_FILE": afUnix,
+	"AF_INET": afInet, "AF_AX25": 3, "AF_IPX": 4, "AF_APPLETALK": 5,
+	"AF_NETROM": 6, "AF_BRIDGE": 7, "AF_ATMPVC": 8, "AF_X25": 9,
+	"AF_INET6": afInet6, "AF_ROSE": 11, "AF_DECnet": 12, "AF_NETBEUI": 13,
+	"AF_SECURITY": 14, "AF_KEY": 15, "AF_NETLINK": afNetlink, "AF_ROUTE": afNetlink,
+	"AF_PACKET": afPacket, "AF_ASH": 18, "AF_ECONET": 19, "AF_ATMSVC": 20,
+	"AF_RDS": 21, "AF_SNA": 22, "AF_IRDA": 23, "AF_PPPOX": 24,
+	"AF_WANPIPE": 25, "AF_LLC": 26, "AF_IB": 27, "AF_MPLS": 28,
+	"AF_CAN": 29, "AF_TIPC": 30, "AF_BLUETOOTH": 31, "AF_IUCV": 32,
+	"AF_RXRPC": 33, "AF_ISDN": 34, "AF_PHONET": 35, "AF_IEEE802154": 36,
+	"AF_CAIF": 37, "AF_ALG": 38, "AF_NFC": 39, "AF_VSOCK": 40,
+	"AF_KCM": 41, "AF_QIPCRTR": 42, "AF_SMC": 43, "AF_XDP": 44,
+	"AF_MCTP": 45,
+}
+
+// seccompArchitectures are the SystemCallArchitectures= values systemd knows.
+var seccompArchitectures = map[string]bool{
+	"native": true, "x86": true, "x86-64": true, "x32": true,
+	"arm": true, "arm64": true, "loongarch64": true,
+	"mips": true, "mips64": true, "mips64-n32": true,
+	"mips-le": true, "mips64-le": true, "mips64-le-n32": true,
+	"parisc": true, "parisc64": true,
+	"ppc": true, "ppc64": true, "ppc64-le": true,
+	"riscv64": true, "s390": true, "s390x": true,
+}
@@ -0,0 +1,91 @@
+package nativeanalyze
+
+// RatingBand is a rating and the lowest exposure, on systemd's 0..100 scale,
+// that it covers.
+type RatingBand struct {
+	Name string
+	Min  uint64
+}
+
+// RatingBands are the rating bands of a systemd version, highest first.
+type RatingBands []RatingBand
+
+// ratingBands lists the bands by the first systemd version using them, newest
+// first. They have not changed since "systemd-analyze security" was added in
+// systemd 240.
+var ratingBands = []struct {
+	since int
+	bands RatingBands
+}{
+	{240, RatingBands{
+		{"DANGEROUS", 100},
+		{"UNSAFE", 90},
+		{"EXPOSED", 75},
+		{"MEDIUM", 50},
+		{"OK", 10},
+		{"SAFE", 1},
+		{"PERFECT", 0},
+	}},
+}
+
+// BandsFor returns the rating bands of systemd major version major; ok is
+// false for versions without "systemd-analyze security".
+func BandsFor(major int) (bands RatingBands, ok bool) {
+	for _, v := range ratingBands {
+		if major >= v.since {
+			return v.bands, true
+		}
+	}
+	return nil, false
+}
+
+// Rating maps an exposure on systemd's 0..100 scale to its rating band in
+// TargetVersion.
+func Rating(exposure uint64) string {
+	bands, _ := BandsFor(TargetMajor)
+	return bands.Name(exposure)
+}
+
+// Name maps an exposure on systemd's 0..100 scale to its rating.
+func (b RatingBands) Name(exposure uint64) string {
+	for _, r := range b {
+		if exposure >= r.Min {
+			return r.Name
+		}
+	}
+	return b[len(b)-1].Name
+}
+
+// Rank orders ratings from the best (0) up; ok is false for unknown ones.
+func (b RatingBands) Rank(name string) (rank int, ok bool) {
+	for i, r := range b {
+		if r.Name == name {
+			return len(b) - 1 - i, true
+		}
+	}
+	return 0, false
+}
+
+// MaxExposure is the highest exposure, on systemd's 0..100 scale, rated name
+// or better.
+func (b RatingBands) MaxExposure(name string) (uint64, bool) {
+	for i, r := range b {
+		if r.Name != name {
+			continue
+		}
+		if i == 0 {
+			return 100, true
+		}
+		return b[i-1].Min - 1, true
+	}
+	return 0, false
+}
+
+// Names lists the ratings from the best up.
+func (b RatingBands) Names() []string {
+	names := make([]string, len(b))
+	for i, r := range b {
+		names[len(b)-1-i] = r.Name
+	}
+	return names
+}
@@ -0,0 +1,159 @@
+// Package nativeanalyze reimplements "systemd-analyze security" in Go: it reads
+// a service's unit file and drop-ins from an offline root and scores it with
+// the checks, weights and rating bands of the systemd version it targets.
+package nativeanalyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+// TargetVersion is the systemd release (and architecture, for system call
+// tables) whose scoring this package reproduces.
+const TargetVersion = "systemd 252 (x86-64)"
+
+type Args struct {
+	Root       string
+	UnitName   string
+	PolicyPath string
+	Threshold  float64
+}
+
+type Result struct {
+	OverallExposure   float64
+	OverallRating     string
+	ThresholdExceeded bool
+	Checks            []model.SecurityCheck
+}
+
+// policyEntry is one check override in a --security-policy JSON file.
+type policyEntry struct {
+	Weight          *uint64 `json:"weight"`
+	Range           *uint64 `json:"range"`
+	DescriptionGood *string `json:"description_good"`
+	DescriptionBad  *string `json:"description_bad"`
+	DescriptionNA   *string `json:"description_na"`
+}
+
+func Security(args Args) (Result, error) {
+	if args.Root == "" || args.UnitName == "" {
+		return Result{}, fmt.Errorf("Root and UnitName are required")
+	}
+
+	policy, err := loadPolicy(args.PolicyPath)
+	if err != nil {
+		return Result{}, err
+	}
+
+	f, err := loadUnit(args.Root, args.UnitName)
+	if err != nil {
+		return Result{}, err
+	}
+	info := buildSecurityInfo(args.UnitName, f)
+
+	type row struct {
+		a           assessor
+		weight, rng uint64
+		badness     uint64
+		description string
+	}
+	rows := make([]row, 0, len(assessors))
+	var badnessSum, weightSum uint64
+	for _, a := range assessors {
+		r := row{a: a, weight: a.weight, rng: a.rng}
+		p := policy[a.jsonField]
+		if p.Weight != nil {
+			r.weight = *p.Weight
+		}
+		if p.Range != nil {
+			r.rng = *p.Range
+		}
+		good, bad, na := a.descGood, a.descBad, a.descNA
+		if p.DescriptionGood != nil {
+			good = *p.DescriptionGood
+		}
+		if p.DescriptionBad != nil {
+			bad = *p.DescriptionBad
+		}
+		if p.DescriptionNA != nil {
+			na = *p.DescriptionNA
+		}
+
+		switch {
+		case a.defaultDependenciesOnly && !info.defaultDependencies:
+			r.badness = badnessNA
+			r.description = "Service runs in special boot phase, option is not appropriate"
+		case r.weight == 0:
+			r.badness = badnessNA
+			r.description = "Option excluded by policy, skipping"
+		default:
+			r.badness, r.description = a.assess(info)
+			switch {
+			case r.description != "":
+			case r.badness == badnessNA:
+				r.description = na
+			case r.badness == r.rng:
+				r.description = bad
+			case r.badness == 0:
+				r.description = good
+			}
+		}
+
+		if r.badness != badnessNA {
+			if r.rng == 0 {
+				return Result{}, fmt.Errorf("security policy sets range 0 for %s", a.jsonField)
+			}
+			badnessSum += divRoundUp(r.badness*r.weight, r.rng)
+			weightSum += r.weight
+		}
+		rows = append(rows, r)
+	}
+	if weightSum == 0 {
+		return Result{}, fmt.Errorf("no checks left to score for %s (all excluded by policy)", args.UnitName)
+	}
+
+	checks := make([]model.SecurityCheck, 0, len(rows))
+	for _, r := range rows {
+		c := model.SecurityCheck{
+			Set:         r.badness == 0,
+			Name:        r.a.name,
+			JSONField:   r.a.jsonField,
+			Description: r.description,
+		}
+		if r.badness != badnessNA && r.badness != 0 {
+			c.Exposure = float64(divRoundUp(r.badness*r.weight*100, r.rng*weightSum)) / 10
+		}
+		checks = append(checks, c)
+	}
+
+	exposure := divRoundUp(badnessSum*100, weightSum)
+	overall := float64(exposure) / 10
+	return Result{
+		OverallExposure:   overall,
+		OverallRating:     Rating(exposure),
+		ThresholdExceeded: overall > args.Threshold,
+		Checks:            checks,
+	}, nil
+}
+
+func divRoundUp(x, y uint64) uint64 {
+	return (x + y - 1) / y
+}
+
+func loadPolicy(path string) (map[string]policyEntry, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read security policy: %w", err)
+	}
+	var policy map[string]policyEntry
+	if err := json.Unmarshal(b, &policy); err != nil {
+		return nil, fmt.Errorf("parse security policy %s: %w", path, err)
+	}
+	return policy, nil
+}
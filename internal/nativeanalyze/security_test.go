@@ -0,0 +1,181 @@
+package nativeanalyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParity compares the native analyzer with "systemd-analyze security"
+// output recorded by testdata/parity/record.sh.
+func TestParity(t *testing.T) {
+	cases, err := filepath.Glob(filepath.Join("testdata", "parity", "*", "unit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("no parity cases found")
+	}
+
+	for _, unitPath := range cases {
+		dir := filepath.Dir(unitPath)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			unitName := strings.TrimSpace(string(mustRead(t, unitPath)))
+
+			systemDir, err := filepath.Abs(filepath.Join(dir, "system"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			root := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(root, "etc", "systemd"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(systemDir, filepath.Join(root, "etc", "systemd", "system")); err != nil {
+				t.Fatal(err)
+			}
+
+			args := Args{Root: root, UnitName: unitName, Threshold: 5}
+			if _, err := os.Stat(filepath.Join(dir, "policy.json")); err == nil {
+				args.PolicyPath = filepath.Join(dir, "policy.json")
+			}
+			res, err := Security(args)
+			if err != nil {
+				t.Fatalf("Security: %v", err)
+			}
+
+			wantOverall := strings.TrimSpace(string(mustRead(t, filepath.Join(dir, "overall.txt"))))
+			if got := fmt.Sprintf("%.1f %s", res.OverallExposure, res.OverallRating); got != wantOverall {
+				t.Errorf("overall = %q, want %q", got, wantOverall)
+			}
+
+			var want []struct {
+				Set         *bool   `json:"set"`
+				Name        string  `json:"name"`
+				JSONField   string  `json:"json_field"`
+				Description *string `json:"description"`
+				Exposure    *string `json:"exposure"`
+			}
+			if err := json.Unmarshal(mustRead(t, filepath.Join(dir, "security.json")), &want); err != nil {
+				t.Fatalf("parse security.json: %v", err)
+			}
+			if len(res.Checks) != len(want) {
+				t.Fatalf("got %d checks, want %d", len(res.Checks), len(want))
+			}
+
+			got := map[string]int{}
+			for i, c := range res.Checks {
+				got[c.JSONField] = i
+			}
+			for _, w := range want {
+				i, ok := got[w.JSONField]
+				if !ok {
+					t.Errorf("missing check %s", w.JSONField)
+					continue
+				}
+				c := res.Checks[i]
+				if c.Name != w.Name {
+					t.Errorf("%s: name = %q, want %q", w.JSONField, c.Name, w.Name)
+				}
+				if wantSet := w.Set != nil && *w.Set; c.Set != wantSet {
+					t.Errorf("%s: set = %v, want %v", w.JSONField, c.Set, wantSet)
+				}
+				if w.Description == nil {
+					w.Description = new(string)
+				}
+				if c.Description != *w.Description {
+					t.Errorf("%s: description = %q, want %q", w.JSONField, c.Description, *w.Description)
+				}
+				gotExposure := ""
+				if c.Exposure > 0 {
+					gotExposure = fmt.Sprintf("%.1f", c.Exposure)
+				}
+				if w.Exposure == nil {
+					w.Exposure = new(string)
+				}
+				if gotExposure != *w.Exposure {
+					t.Errorf("%s: exposure = %q, want %q", w.JSONField, gotExposure, *w.Exposure)
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityThresholdAndErrors(t *testing.T) {
+	root := t.TempDir()
+	mustWrite(t, filepath.Join(root, "etc", "systemd", "system", "a.service"), "[Service]\nExecStart=/bin/true\n")
+
+	res, err := Security(Args{Root: root, UnitName: "a.service", Threshold: 9.6})
+	if err != nil {
+		t.Fatalf("Security: %v", err)
+	}
+	if res.OverallExposure != 9.6 || res.OverallRating != "UNSAFE" {
+		t.Fatalf("unexpected overall: %v %s", res.OverallExposure, res.OverallRating)
+	}
+	if res.ThresholdExceeded {
+		t.Fatalf("9.6 must not exceed threshold 9.6")
+	}
+	res, _ = Security(Args{Root: root, UnitName: "a.service", Threshold: 9.5})
+	if !res.ThresholdExceeded {
+		t.Fatalf("9.6 must exceed threshold 9.5")
+	}
+
+	if _, err := Security(Args{Root: root, UnitName: "missing.service"}); err == nil {
+		t.Fatalf("expected error for missing unit")
+	}
+
+	policy := filepath.Join(root, "policy.json")
+	mustWrite(t, policy, `{"PrivateNetwork": {"weight": "heavy"}}`)
+	if _, err := Security(Args{Root: root, UnitName: "a.service", PolicyPath: policy}); err == nil || !strings.Contains(err.Error(), "parse security policy") {
+		t.Fatalf("expected policy parse error, got %v", err)
+	}
+}
+
+func TestRating(t *testing.T) {
+	cases := map[uint64]string{0: "PERFECT", 1: "SAFE", 9: "SAFE", 10: "OK", 49: "OK", 50: "MEDIUM", 75: "EXPOSED", 90: "UNSAFE", 100: "DANGEROUS"}
+	for exposure, want := range cases {
+		if got := Rating(exposure); got != want {
+			t.Errorf("Rating(%d) = %s, want %s", exposure, got, want)
+		}
+	}
+
+	if _, ok := BandsFor(239); ok {
+		t.Errorf("BandsFor(239): want no bands before systemd-analyze security")
+	}
+	bands, ok := BandsFor(257)
+	if !ok {
+		t.Fatalf("BandsFor(257): no bands")
+	}
+	for name, want := range map[string]uint64{"PERFECT": 0, "SAFE": 9, "MEDIUM": 74, "UNSAFE": 99, "DANGEROUS": 100} {
+		if got, ok := bands.MaxExposure(name); !ok || got != want {
+			t.Errorf("MaxExposure(%s) = %d, %v, want %d", name, got, ok, want)
+		}
+	}
+	if low, _ := bands.Rank("OK"); low != 2 {
+		t.Errorf("Rank(OK) = %d, want 2", low)
+	}
+	if _, ok := bands.Rank("medium"); ok {
+		t.Errorf("Rank(medium): ratings are upper case")
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func mustWrite(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
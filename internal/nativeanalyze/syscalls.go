@@ -0,0 +1,87 @@
+package nativeanalyze
+
+import (
+	_ "embed"
+	"strings"
+)
+
+// syscall-groups.txt is the output of systemd 252's
+// "systemd-analyze syscall-filter" (without @known); syscalls-x86_64.txt
+// lists the system calls libseccomp resolves on x86-64.
+var (
+	//go:embed data/syscall-groups.txt
+	syscallGroupsData string
+	//go:embed data/syscalls-x86_64.txt
+	nativeSyscallsData string
+)
+
+var (
+	syscallGroups  = parseSyscallGroups(syscallGroupsData)
+	nativeSyscalls = parseSyscallList(nativeSyscallsData)
+)
+
+// parseSyscallGroups reads "@group" headers followed by indented members,
+// keeping the member order (it decides which offender systemd reports).
+func parseSyscallGroups(data string) map[string][]string {
+	groups := map[string][]string{}
+	var cur string
+	for _, line := range strings.Split(data, "\n") {
+		s := strings.TrimSpace(line)
+		switch {
+		case s == "" || strings.HasPrefix(s, "#"):
+		case !strings.HasPrefix(line, " "):
+			cur = s
+			groups[cur] = nil
+		default:
+			groups[cur] = append(groups[cur], s)
+		}
+	}
+	return groups
+}
+
+func parseSyscallList(data string) map[string]bool {
+	out := map[string]bool{}
+	for _, s := range strings.Fields(data) {
+		out[s] = true
+	}
+	return out
+}
+
+// addSyscalls adds (or removes) a system call or "@group" to the filter set.
+// Unknown names and calls that don't exist on x86-64 are ignored.
+func addSyscalls(set map[string]bool, name string, add bool) {
+	if strings.HasPrefix(name, "@") {
+		for _, member := range syscallGroups[name] {
+			addSyscalls(set, member, add)
+		}
+		return
+	}
+	if !nativeSyscalls[name] {
+		return
+	}
+	if add {
+		set[name] = true
+	} else {
+		delete(set, name)
+	}
+}
+
+// syscallOffender reports the first native system call of group that the
+// filter fails to block, if any.
+func syscallOffender(filter map[string]bool, allowList bool, group string) (string, bool) {
+	for _, name := range syscallGroups[group] {
+		if strings.HasPrefix(name, "@") {
+			if offender, ok := syscallOffender(filter, allowList, name); ok {
+				return offender, true
+			}
+			continue
+		}
+		if !nativeSyscalls[name] {
+			continue
+		}
+		if filter[name] == allowList {
+			return name, true
+		}
+	}
+	return "", false
+}
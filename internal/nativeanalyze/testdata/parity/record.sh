@@ -0,0 +1,26 @@
+#!/bin/sh
+# Re-records the expected output of every case with a real systemd-analyze.
+# The fixtures were recorded with systemd 252 on x86-64 (Debian 12).
+#
+#   usage: ./record.sh [path/to/systemd-analyze]
+set -eu
+
+analyze=${1:-systemd-analyze}
+cd "$(dirname "$0")"
+
+for dir in */; do
+	dir=${dir%/}
+	unit=$(cat "$dir/unit")
+	root=$(mktemp -d)
+	mkdir -p "$root/etc/systemd"
+	cp -a "$dir/system" "$root/etc/systemd/system"
+
+	set -- security --offline=yes --root="$root" --no-pager
+	if [ -f "$dir/policy.json" ]; then
+		set -- "$@" --security-policy="$dir/policy.json"
+	fi
+
+	"$analyze" "$@" --json=short "$unit" >"$dir/security.json"
+	"$analyze" "$@" "$unit" | sed -n 's/.*Overall exposure level for [^:]*: \([0-9.]*\) \([A-Z]*\).*/\1 \2/p' >"$dir/overall.txt"
+	rm -rf "$root"
+done
@@ -5,15 +5,32 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/unitfile"
 )
 
 type Builder struct {
 	RepoRootAbs string
+	// Instances maps template units ("foo@.service") to the instance names
+	// they are analyzed as ("a" -> "foo@a.service"). Templates that are not
+	// built are ignored, so it may cover the whole repo.
+	Instances map[string][]string
 }
 
-func (b Builder) Build(repoRelServicePaths []string) (root string, units []model.UnitFile, err error) {
+// activatorSections maps unit types that only activate a service to the
+// section holding their Unit= setting.
+var activatorSections = map[string]string{
+	".socket": "Socket",
+	".timer":  "Timer",
+	".path":   "Path",
+}
+
+// UnitDir is where Build installs units, relative to the root.
+const UnitDir = "etc/systemd/system"
+
+func (b Builder) Build(repoRelUnitPaths []string) (root string, units []model.UnitFile, err error) {
 	if b.RepoRootAbs == "" {
 		return "", nil, fmt.Errorf("RepoRootAbs is required")
 	}
@@ -28,42 +45,225 @@ func (b Builder) Build(repoRelServicePaths []string) (root string, units []model
 		}
 	}()
 
-	unitDir := filepath.Join(root, "etc", "systemd", "system")
+	unitDir := filepath.Join(root, filepath.FromSlash(UnitDir))
 	if err := os.MkdirAll(unitDir, 0o755); err != nil {
 		return "", nil, fmt.Errorf("mkdir %s: %w", unitDir, err)
 	}
 
-	seenUnitNames := map[string]string{}
-	for _, rel := range repoRelServicePaths {
-		unitName := filepath.Base(rel)
-		if prev, ok := seenUnitNames[unitName]; ok {
-			return "", nil, fmt.Errorf("unit name collision for %q: %q and %q (rename or narrow --paths)", unitName, prev, rel)
+	var services, activators []string
+	servicePaths := map[string]string{}
+	for _, rel := range repoRelUnitPaths {
+		if _, ok := activatorSections[filepath.Ext(rel)]; ok {
+			activators = append(activators, rel)
+			continue
 		}
-		seenUnitNames[unitName] = rel
+		services = append(services, rel)
+		servicePaths[filepath.Base(rel)] = rel
+	}
 
-		src := filepath.Join(b.RepoRootAbs, rel)
-		dst := filepath.Join(unitDir, unitName)
-		if err := copyFile(src, dst); err != nil {
+	instances := map[string][]string{}
+	for template, names := range b.Instances {
+		instances[template] = append(instances[template], names...)
+	}
+
+	// Resolve activators first: the service they trigger may live next to
+	// them rather than in --paths, and may name a template instance. An
+	// activator whose service can't be resolved is reported as a unit with
+	// an error, so the rest still gets analyzed.
+	activatorTargets := make([]string, len(activators))
+	var broken []model.UnitFile
+	for i, rel := range activators {
+		target, err := b.activatedService(rel)
+		if err != nil {
+			broken = append(broken, unitError(rel, err))
+			continue
+		}
+
+		file, instance := target, ""
+		if template, name, ok := SplitInstance(target); ok && name != "" {
+			file, instance = template, name
+		}
+		if _, ok := servicePaths[file]; !ok {
+			// Not matched by --paths: look for the service next to the activator.
+			fileRel := filepath.Join(filepath.Dir(rel), file)
+			if _, statErr := os.Stat(filepath.Join(b.RepoRootAbs, fileRel)); statErr != nil {
+				broken = append(broken, unitError(rel, fmt.Errorf("%s activates %q, which was not found next to it or in --paths", filepath.ToSlash(rel), target)))
+				continue
+			}
+			services = append(services, fileRel)
+			servicePaths[file] = fileRel
+		}
+		if instance != "" {
+			instances[file] = append(instances[file], instance)
+		}
+		activatorTargets[i] = target
+	}
+
+	seenUnitNames := map[string]string{}
+	unitIndex := map[string][]int{}
+	for _, rel := range services {
+		if err := b.install(rel, unitDir, seenUnitNames); err != nil {
 			return "", nil, err
 		}
+		unitName := filepath.Base(rel)
+
+		if _, instance, ok := SplitInstance(unitName); !ok || instance != "" {
+			unitIndex[unitName] = append(unitIndex[unitName], len(units))
+			units = append(units, model.UnitFile{
+				UnitName:    unitName,
+				RepoRelPath: filepath.ToSlash(rel),
+			})
+			continue
+		}
 
-		units = append(units, model.UnitFile{
-			UnitName:    unitName,
-			RepoRelPath: filepath.ToSlash(rel),
-		})
+		names := dedupe(instances[unitName])
+		if len(names) == 0 {
+			// E.g. the service of an Accept=yes socket.
+			unitIndex[unitName] = append(unitIndex[unitName], len(units))
+			units = append(units, unitError(rel, fmt.Errorf("template unit %q cannot be analyzed without instance names (use --instances %s=NAME[,NAME...] or the config's instances)", filepath.ToSlash(rel), unitName)))
+			continue
+		}
+		for _, name := range names {
+			instanceName, err := b.installInstance(rel, name, unitDir, seenUnitNames)
+			if err != nil {
+				return "", nil, err
+			}
+			idx := len(units)
+			unitIndex[unitName] = append(unitIndex[unitName], idx)
+			unitIndex[instanceName] = append(unitIndex[instanceName], idx)
+			units = append(units, model.UnitFile{
+				UnitName:    instanceName,
+				RepoRelPath: filepath.ToSlash(rel),
+				Template:    unitName,
+			})
+		}
+	}
 
-		if err := b.copyDropIns(rel, unitDir); err != nil {
+	for i, rel := range activators {
+		if activatorTargets[i] == "" {
+			continue
+		}
+		if err := b.install(rel, unitDir, seenUnitNames); err != nil {
 			return "", nil, err
 		}
+		for _, idx := range unitIndex[activatorTargets[i]] {
+			units[idx].ActivatedBy = append(units[idx].ActivatedBy, filepath.Base(rel))
+		}
 	}
 
+	units = append(units, broken...)
+	for i := range units {
+		sort.Strings(units[i].ActivatedBy)
+	}
 	sort.SliceStable(units, func(i, j int) bool { return units[i].UnitName < units[j].UnitName })
 	return root, units, nil
 }
 
-func (b Builder) copyDropIns(repoRelServicePath string, unitDir string) error {
-	unitName := filepath.Base(repoRelServicePath)
-	dropInDirRel := repoRelServicePath + ".d"
+// SplitInstance splits "foo@bar.service" into the template "foo@.service" and
+// the instance "bar". Templates themselves return an empty instance; ok is
+// false for units that are neither.
+func SplitInstance(unitName string) (template string, instance string, ok bool) {
+	at := strings.Index(unitName, "@")
+	if at < 0 {
+		return "", "", false
+	}
+	ext := filepath.Ext(unitName)
+	return unitName[:at+1] + ext, strings.TrimSuffix(unitName[at+1:], ext), true
+}
+
+// installInstance links "foo@name.service" to the already installed template
+// and copies the per-instance drop-ins ("foo@name.service.d/") next to it.
+func (b Builder) installInstance(templateRel string, name string, unitDir string, seenUnitNames map[string]string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/@") {
+		return "", fmt.Errorf("invalid instance name %q for %s", name, filepath.Base(templateRel))
+	}
+	templateName := filepath.Base(templateRel)
+	instanceName := strings.TrimSuffix(templateName, filepath.Ext(templateName)) + name + filepath.Ext(templateName)
+	instanceRel := filepath.Join(filepath.Dir(templateRel), instanceName)
+
+	if prev, ok := seenUnitNames[instanceName]; ok {
+		return "", fmt.Errorf("unit name collision for %q: %q and instance of %q (rename or narrow --paths)", instanceName, prev, templateRel)
+	}
+	seenUnitNames[instanceName] = instanceRel
+
+	if err := os.Symlink(templateName, filepath.Join(unitDir, instanceName)); err != nil {
+		return "", fmt.Errorf("link instance %s: %w", instanceName, err)
+	}
+	if err := b.copyDropIns(instanceRel, unitDir); err != nil {
+		return "", err
+	}
+	return instanceName, nil
+}
+
+func dedupe(in []string) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, s := range in {
+		s = strings.TrimSpace(s)
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	return out
+}
+
+func (b Builder) install(rel string, unitDir string, seenUnitNames map[string]string) error {
+	unitName := filepath.Base(rel)
+	if prev, ok := seenUnitNames[unitName]; ok {
+		return fmt.Errorf("unit name collision for %q: %q and %q (rename or narrow --paths)", unitName, prev, rel)
+	}
+	seenUnitNames[unitName] = rel
+
+	src := filepath.Join(b.RepoRootAbs, rel)
+	dst := filepath.Join(unitDir, unitName)
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return b.copyDropIns(rel, unitDir)
+}
+
+// unitError reports the unit file at rel as one that can't be analyzed.
+func unitError(rel string, err error) model.UnitFile {
+	return model.UnitFile{UnitName: filepath.Base(rel), RepoRelPath: filepath.ToSlash(rel), Error: err.Error()}
+}
+
+// activatedService resolves the service a .socket/.timer/.path unit triggers:
+// its Unit= setting (drop-ins included) or the implicit same-name service.
+func (b Builder) activatedService(repoRelPath string) (string, error) {
+	ext := filepath.Ext(repoRelPath)
+	f, err := unitfile.ParseWithDropIns(filepath.Join(b.RepoRootAbs, repoRelPath))
+	if err != nil {
+		return "", err
+	}
+
+	target, ok := f.Lookup(activatorSections[ext], "Unit")
+	if !ok {
+		prefix := strings.TrimSuffix(filepath.Base(repoRelPath), ext)
+		target = prefix + ".service"
+		if accept, _ := f.Lookup("Socket", "Accept"); ext == ".socket" && parseBool(accept) {
+			target = prefix + "@.service"
+		}
+	}
+	if filepath.Ext(target) != ".service" {
+		return "", fmt.Errorf("%s activates %q, which is not a .service unit", filepath.ToSlash(repoRelPath), target)
+	}
+	return target, nil
+}
+
+func parseBool(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "yes", "y", "true", "t", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+func (b Builder) copyDropIns(repoRelUnitPath string, unitDir string) error {
+	unitName := filepath.Base(repoRelUnitPath)
+	dropInDirRel := repoRelUnitPath + ".d"
 	dropInDirAbs := filepath.Join(b.RepoRootAbs, dropInDirRel)
 
 	entries, err := os.ReadDir(dropInDirAbs)
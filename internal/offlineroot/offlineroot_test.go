@@ -3,7 +3,10 @@ package offlineroot
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
 )
 
 func TestBuilderCopiesServiceAndDropIns(t *testing.T) {
@@ -48,6 +51,151 @@ func TestBuilderDetectsCollisions(t *testing.T) {
 	}
 }
 
+func TestBuilderMapsActivatorsToServices(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy", "web.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "web.socket"), "[Socket]\nListenStream=80\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "nightly.timer"), "[Timer]\nOnCalendar=daily\nUnit=cleanup.service\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "cleanup.service"), "[Service]\nExecStart=/bin/true\n")
+
+	b := Builder{RepoRootAbs: repo}
+	root, units, err := b.Build([]string{"deploy/nightly.timer", "deploy/web.service", "deploy/web.socket"})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(root) })
+
+	if len(units) != 2 {
+		t.Fatalf("units len = %d, want 2: %#v", len(units), units)
+	}
+	if units[0].UnitName != "cleanup.service" || units[0].RepoRelPath != "deploy/cleanup.service" {
+		t.Fatalf("units[0] = %#v, want cleanup.service found next to the timer", units[0])
+	}
+	if len(units[0].ActivatedBy) != 1 || units[0].ActivatedBy[0] != "nightly.timer" {
+		t.Fatalf("units[0].ActivatedBy = %#v, want nightly.timer", units[0].ActivatedBy)
+	}
+	if units[1].UnitName != "web.service" || len(units[1].ActivatedBy) != 1 || units[1].ActivatedBy[0] != "web.socket" {
+		t.Fatalf("units[1] = %#v, want web.service activated by web.socket", units[1])
+	}
+
+	for _, name := range []string{"web.socket", "nightly.timer", "cleanup.service"} {
+		if _, err := os.Stat(filepath.Join(root, "etc", "systemd", "system", name)); err != nil {
+			t.Fatalf("expected %s copied: %v", name, err)
+		}
+	}
+}
+
+func TestBuilderActivatorErrorsArePerUnit(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy", "orphan.socket"), "[Socket]\nListenStream=80\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "odd.timer"), "[Timer]\nUnit=odd.target\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "conn.socket"), "[Socket]\nListenStream=81\nAccept=yes\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "conn@.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "web.service"), "[Service]\nExecStart=/bin/true\n")
+
+	b := Builder{RepoRootAbs: repo}
+	root, units, err := b.Build([]string{"deploy/conn.socket", "deploy/odd.timer", "deploy/orphan.socket", "deploy/web.service"})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(root) })
+
+	got := map[string]model.UnitFile{}
+	for _, u := range units {
+		got[u.UnitName] = u
+	}
+	if len(units) != 4 {
+		t.Fatalf("units = %#v, want conn@.service, odd.timer, orphan.socket and web.service", units)
+	}
+	for name, want := range map[string]string{
+		"orphan.socket": `activates "orphan.service", which was not found`,
+		"odd.timer":     "not a .service unit",
+		"conn@.service": "without instance names",
+	} {
+		if u := got[name]; !strings.Contains(u.Error, want) || u.RepoRelPath != "deploy/"+name {
+			t.Fatalf("%s = %#v, want error %q", name, u, want)
+		}
+	}
+	if u := got["conn@.service"]; len(u.ActivatedBy) != 1 || u.ActivatedBy[0] != "conn.socket" {
+		t.Fatalf("conn@.service ActivatedBy = %#v, want conn.socket", u.ActivatedBy)
+	}
+	if u := got["web.service"]; u.Error != "" {
+		t.Fatalf("web.service = %#v, want no error", u)
+	}
+	if _, err := os.Stat(filepath.Join(root, UnitDir, "web.service")); err != nil {
+		t.Fatalf("expected web.service installed: %v", err)
+	}
+}
+
+func TestBuilderExpandsTemplateInstances(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy", "worker@.service"), "[Service]\nExecStart=/bin/true\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "worker@.service.d", "common.conf"), "[Service]\nNoNewPrivileges=yes\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "worker@a.service.d", "only-a.conf"), "[Service]\nPrivateNetwork=yes\n")
+	mustWrite(t, filepath.Join(repo, "deploy", "worker-c.timer"), "[Timer]\nOnCalendar=daily\nUnit=worker@c.service\n")
+
+	b := Builder{RepoRootAbs: repo, Instances: map[string][]string{"worker@.service": {"a", "b"}}}
+	root, units, err := b.Build([]string{"deploy/worker-c.timer", "deploy/worker@.service"})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(root) })
+
+	var names []string
+	for _, u := range units {
+		names = append(names, u.UnitName)
+		if u.Template != "worker@.service" || u.RepoRelPath != "deploy/worker@.service" {
+			t.Fatalf("unit = %#v, want instance of deploy/worker@.service", u)
+		}
+	}
+	if strings.Join(names, ",") != "worker@a.service,worker@b.service,worker@c.service" {
+		t.Fatalf("units = %v", names)
+	}
+	if len(units[2].ActivatedBy) != 1 || units[2].ActivatedBy[0] != "worker-c.timer" {
+		t.Fatalf("worker@c.service ActivatedBy = %#v, want worker-c.timer", units[2].ActivatedBy)
+	}
+
+	unitDir := filepath.Join(root, "etc", "systemd", "system")
+	target, err := os.Readlink(filepath.Join(unitDir, "worker@a.service"))
+	if err != nil || target != "worker@.service" {
+		t.Fatalf("worker@a.service link = %q, %v; want worker@.service", target, err)
+	}
+	for _, p := range []string{"worker@.service.d/common.conf", "worker@a.service.d/only-a.conf"} {
+		if _, err := os.Stat(filepath.Join(unitDir, p)); err != nil {
+			t.Fatalf("expected %s copied: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(unitDir, "worker@b.service.d")); !os.IsNotExist(err) {
+		t.Fatalf("did not expect drop-in dir for worker@b.service, stat err = %v", err)
+	}
+}
+
+func TestBuilderTemplateRequiresInstances(t *testing.T) {
+	repo := t.TempDir()
+	mustWrite(t, filepath.Join(repo, "deploy", "worker@.service"), "[Service]\n")
+
+	b := Builder{RepoRootAbs: repo}
+	root, units, err := b.Build([]string{"deploy/worker@.service"})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	_ = os.RemoveAll(root)
+	if len(units) != 1 || !strings.Contains(units[0].Error, "--instances") {
+		t.Fatalf("units = %#v, want an error asking for instances", units)
+	}
+
+	// Instances of templates that weren't matched are ignored.
+	b.Instances = map[string][]string{"other@.service": {"x"}}
+	root, units, err = b.Build([]string{"deploy/worker@.service"})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	_ = os.RemoveAll(root)
+	if len(units) != 1 || units[0].UnitName != "worker@.service" || units[0].Error == "" {
+		t.Fatalf("units = %#v, want only worker@.service, still without instances", units)
+	}
+}
+
 func mustWrite(t *testing.T, path string, content string) {
 	t.Helper()
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
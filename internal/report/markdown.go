@@ -4,37 +4,77 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/teunlao/systemd-security-gate/internal/checkdoc"
+	"github.com/teunlao/systemd-security-gate/internal/hardening"
 	"github.com/teunlao/systemd-security-gate/internal/model"
 )
 
 func MarkdownSummary(scan model.ScanReport) string {
 	var b strings.Builder
 	b.WriteString("## systemd security gate\n\n")
-	b.WriteString(fmt.Sprintf("- Threshold: %.2f\n", scan.Threshold))
+	if scan.MaxRating != "" {
+		b.WriteString(fmt.Sprintf("- Max rating: %s (exposure up to %.2f)\n", scan.MaxRating, scan.Threshold))
+	} else {
+		b.WriteString(fmt.Sprintf("- Threshold: %.2f\n", scan.Threshold))
+	}
 	if scan.Mode != "" {
 		b.WriteString(fmt.Sprintf("- Mode: %s\n", scan.Mode))
 	}
+	if len(scan.RequiredChecks) > 0 {
+		b.WriteString(fmt.Sprintf("- Required checks: `%s`\n", strings.Join(scan.RequiredChecks, "`, `")))
+	}
+	if scan.ConfigPath != "" {
+		b.WriteString(fmt.Sprintf("- Config: `%s`\n", scan.ConfigPath))
+	}
+	if scan.Base != "" {
+		b.WriteString(fmt.Sprintf("- Base: `%s` (merge base `%s`)\n", scan.Base, shortCommit(scan.BaseCommit)))
+	}
+	if scan.BaselinePath != "" {
+		b.WriteString(fmt.Sprintf("- Baseline: `%s` (fail on regressions)\n", scan.BaselinePath))
+	}
 	if scan.PolicyPath != "" {
 		b.WriteString(fmt.Sprintf("- Policy: `%s`\n", scan.PolicyPath))
 	}
-	if scan.SystemdVersion != "" {
+	if scan.Backend == "native" {
+		b.WriteString(fmt.Sprintf("- Backend: native (%s scoring)\n", scan.SystemdVersion))
+	} else if scan.SystemdVersion != "" {
 		b.WriteString(fmt.Sprintf("- systemd-analyze: `%s` (%s)\n", scan.SystemdAnalyze, scan.SystemdVersion))
 	} else {
 		b.WriteString(fmt.Sprintf("- systemd-analyze: `%s`\n", scan.SystemdAnalyze))
 	}
 	b.WriteString("\n")
 
-	b.WriteString("| Unit | Path | Status | Overall |\n")
-	b.WriteString("|------|------|--------|---------|\n")
+	withBaseline := scan.BaselinePath != "" || scan.Base != ""
+	if scan.Base != "" && len(scan.Units) == 0 {
+		b.WriteString("No unit exposure changed since the base.\n")
+	} else if withBaseline {
+		b.WriteString("| Unit | Path | Status | Overall | Threshold | Baseline |\n")
+		b.WriteString("|------|------|--------|---------|-----------|----------|\n")
+	} else {
+		b.WriteString("| Unit | Path | Status | Overall | Threshold |\n")
+		b.WriteString("|------|------|--------|---------|-----------|\n")
+	}
 	for _, u := range scan.Units {
+		flagged := u.Flagged()
 		status := "✅ pass"
 		if u.Error != "" {
 			status = "❌ error"
-		} else if u.ThresholdExceeded && !u.Allowed {
+		} else if flagged && u.ExceptionExpired {
+			status = "⌛ expired exception"
+		} else if flagged && u.OverBudget {
+			status = "❌ over budget"
+		} else if flagged && !u.Allowed && u.Baseline != nil && u.Baseline.Regressed {
+			status = "❌ regressed"
+		} else if flagged && !u.Allowed {
 			status = "❌ fail"
-		} else if u.ThresholdExceeded && u.Allowed {
+		} else if len(u.RequiredChecksFailed) > 0 {
+			status = "❌ required checks"
+		} else if flagged && u.Allowed {
 			status = "⚠️ allowed"
 		}
+		if u.Mode != "" && u.Mode != scan.Mode && u.Error == "" {
+			status += fmt.Sprintf(" (%s)", u.Mode)
+		}
 		overall := ""
 		if u.Error != "" {
 			overall = u.Error
@@ -43,10 +83,74 @@ func MarkdownSummary(scan model.ScanReport) string {
 		} else {
 			overall = fmt.Sprintf("%.2f", u.OverallExposure)
 		}
-		b.WriteString(fmt.Sprintf("| `%s` | `%s` | %s | %s |\n", u.UnitName, u.RepoRelPath, status, overall))
+		unit := fmt.Sprintf("`%s`", u.UnitName)
+		if len(u.ActivatedBy) > 0 {
+			unit += fmt.Sprintf(" (via `%s`)", strings.Join(u.ActivatedBy, "`, `"))
+		}
+		threshold := fmt.Sprintf("%.2f", u.Threshold)
+		if u.MaxRating != "" {
+			threshold = u.MaxRating
+		}
+		if u.PolicyPath != "" && u.PolicyPath != scan.PolicyPath {
+			threshold += fmt.Sprintf(" (`%s`)", u.PolicyPath)
+		}
+		if !withBaseline {
+			b.WriteString(fmt.Sprintf("| %s | `%s` | %s | %s | %s |\n", unit, u.RepoRelPath, status, overall, threshold))
+			continue
+		}
+		delta := ""
+		if u.Baseline != nil && u.Baseline.New {
+			delta = "new"
+		} else if u.Baseline != nil {
+			delta = fmt.Sprintf("%+.2f (was %.2f)", u.Baseline.Delta, u.Baseline.PreviousExposure)
+		}
+		b.WriteString(fmt.Sprintf("| %s | `%s` | %s | %s | %s | %s |\n", unit, u.RepoRelPath, status, overall, threshold, delta))
 	}
 	b.WriteString("\n")
 
+	var changes []string
+	for _, u := range scan.Units {
+		if u.Baseline == nil || len(u.Baseline.IntroducedChecks)+len(u.Baseline.ResolvedChecks) == 0 {
+			continue
+		}
+		line := fmt.Sprintf("- `%s`:", u.UnitName)
+		if len(u.Baseline.IntroducedChecks) > 0 {
+			line += fmt.Sprintf(" introduced `%s`", strings.Join(u.Baseline.IntroducedChecks, "`, `"))
+		}
+		if len(u.Baseline.ResolvedChecks) > 0 {
+			if len(u.Baseline.IntroducedChecks) > 0 {
+				line += ";"
+			}
+			line += fmt.Sprintf(" resolved `%s`", strings.Join(u.Baseline.ResolvedChecks, "`, `"))
+		}
+		changes = append(changes, line)
+	}
+	if len(changes) > 0 {
+		b.WriteString("### Changes since baseline\n\n")
+		b.WriteString(strings.Join(changes, "\n"))
+		b.WriteString("\n\n")
+	}
+
+	if len(scan.DeletedUnits) > 0 {
+		b.WriteString("### Deleted units\n\n")
+		for _, u := range scan.DeletedUnits {
+			if u.Error != "" {
+				b.WriteString(fmt.Sprintf("- `%s` (`%s`)\n", u.UnitName, u.RepoRelPath))
+				continue
+			}
+			b.WriteString(fmt.Sprintf("- `%s` (`%s`), was %.2f %s\n", u.UnitName, u.RepoRelPath, u.OverallExposure, u.OverallRating))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(scan.Warnings) > 0 {
+		b.WriteString("### Warnings\n\n")
+		for _, w := range scan.Warnings {
+			b.WriteString(fmt.Sprintf("- %s\n", w))
+		}
+		b.WriteString("\n")
+	}
+
 	for _, u := range scan.Units {
 		if u.Error != "" {
 			b.WriteString(fmt.Sprintf("### %s\n\n", u.UnitName))
@@ -56,7 +160,7 @@ func MarkdownSummary(scan model.ScanReport) string {
 			b.WriteString(fmt.Sprintf("- Error: %s\n\n", u.Error))
 			continue
 		}
-		if !u.ThresholdExceeded {
+		if !u.Flagged() && len(u.RequiredChecksFailed) == 0 {
 			continue
 		}
 		if len(u.TopIssues) == 0 {
@@ -64,8 +168,21 @@ func MarkdownSummary(scan model.ScanReport) string {
 		}
 		b.WriteString(fmt.Sprintf("### %s\n\n", u.UnitName))
 		if u.RepoRelPath != "" {
-			b.WriteString(fmt.Sprintf("- Path: `%s`\n\n", u.RepoRelPath))
+			b.WriteString(fmt.Sprintf("- Path: `%s`\n", u.RepoRelPath))
+		}
+		if len(u.RequiredChecksFailed) > 0 {
+			b.WriteString(fmt.Sprintf("- Required checks exposed: `%s`\n", strings.Join(u.RequiredChecksFailed, "`, `")))
+		}
+		for _, e := range u.Exceptions {
+			if u.ExceptionExpired {
+				b.WriteString(fmt.Sprintf("- Expired exception: %s\n", e.Describe()))
+			} else if u.OverBudget {
+				b.WriteString(fmt.Sprintf("- Exceeded exception budget: %s\n", e.Describe()))
+			} else {
+				b.WriteString(fmt.Sprintf("- Exception: %s\n", e.Describe()))
+			}
 		}
+		b.WriteString("\n")
 		for _, c := range u.TopIssues {
 			id := c.JSONField
 			if id == "" {
@@ -78,10 +195,23 @@ func MarkdownSummary(scan model.ScanReport) string {
 			if desc == "" {
 				desc = c.Name
 			}
-			b.WriteString(fmt.Sprintf("- `%s` exposure=%.2f: %s\n", id, c.Exposure, desc))
+			line := fmt.Sprintf("- `%s` exposure=%.2f: %s", id, c.Exposure, desc)
+			if fix, ok := hardening.Suggest(c); ok {
+				line += fmt.Sprintf(" (suggested: `%s`, -%.2f)", fix, c.Exposure)
+			} else if d, ok := checkdoc.Get(c.JSONField); ok && c.Exposure > 0 {
+				line += fmt.Sprintf(" (recommended: %s)", d.Recommended)
+			}
+			b.WriteString(line + "\n")
 		}
 		b.WriteString("\n")
 	}
 
 	return b.String()
 }
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -31,6 +31,7 @@ func TestMarkdownSummaryIncludesOnlyFailDetails(t *testing.T) {
 				ThresholdExceeded: true,
 				TopIssues: []model.SecurityCheck{
 					{JSONField: "PrivateNetwork", Exposure: 0.5, Description: "fail unit issue"},
+					{JSONField: "RootDirectoryOrRootImage", Exposure: 0.1, Description: "root issue"},
 				},
 			},
 			{
@@ -62,7 +63,124 @@ func TestMarkdownSummaryIncludesOnlyFailDetails(t *testing.T) {
 	if !strings.Contains(md, "### fail.service") {
 		t.Fatalf("expected details section for fail unit, got:\n%s", md)
 	}
+	if !strings.Contains(md, "- `PrivateNetwork` exposure=0.50: fail unit issue (suggested: `PrivateNetwork=yes`, -0.50)") {
+		t.Fatalf("expected suggested fix, got:\n%s", md)
+	}
+	if !strings.Contains(md, "- `RootDirectoryOrRootImage` exposure=0.10: root issue (recommended: `RootDirectory=` or `RootImage=`)") {
+		t.Fatalf("expected recommendation for an issue without a mechanical fix, got:\n%s", md)
+	}
 	if !strings.Contains(md, "### err.service") || !strings.Contains(md, "Error: boom") {
 		t.Fatalf("expected details section for error unit, got:\n%s", md)
 	}
 }
+
+func TestMarkdownSummaryShowsActivators(t *testing.T) {
+	scan := model.ScanReport{
+		Threshold: 6,
+		Units: []model.UnitReport{
+			{
+				UnitName:        "web.service",
+				RepoRelPath:     "deploy/web.service",
+				ActivatedBy:     []string{"web.socket"},
+				OverallExposure: 4.0,
+				OverallRating:   "OK",
+			},
+		},
+	}
+
+	md := MarkdownSummary(scan)
+	if !strings.Contains(md, "`web.service` (via `web.socket`)") {
+		t.Fatalf("expected activator next to unit, got:\n%s", md)
+	}
+}
+
+func TestMarkdownSummaryShowsPerUnitSettings(t *testing.T) {
+	scan := model.ScanReport{
+		Threshold:  3,
+		Mode:       "enforce",
+		ConfigPath: ".ssg.yaml",
+		Units: []model.UnitReport{
+			{UnitName: "api.service", RepoRelPath: "deploy/api.service", Threshold: 3, Mode: "enforce", OverallExposure: 2.1, OverallRating: "OK"},
+			{UnitName: "legacy.service", RepoRelPath: "vendor/legacy.service", Threshold: 7.5, PolicyPath: ".ci/vendor.json", Mode: "report", OverallExposure: 8.2, OverallRating: "EXPOSED", ThresholdExceeded: true},
+		},
+	}
+
+	md := MarkdownSummary(scan)
+	for _, want := range []string{
+		"- Config: `.ssg.yaml`",
+		"| `api.service` | `deploy/api.service` | ✅ pass | 2.10 OK | 3.00 |",
+		"| `legacy.service` | `vendor/legacy.service` | ❌ fail (report) | 8.20 EXPOSED | 7.50 (`.ci/vendor.json`) |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("expected %q in summary, got:\n%s", want, md)
+		}
+	}
+}
+
+func TestMarkdownSummaryShowsExpiredExceptions(t *testing.T) {
+	scan := model.ScanReport{
+		Threshold: 6,
+		Warnings:  []string{"allowlist entry expires soon: b.service (expires: 2026-04-01)"},
+		Units: []model.UnitReport{
+			{
+				UnitName:          "a.service",
+				RepoRelPath:       "deploy/a.service",
+				OverallExposure:   7.0,
+				ThresholdExceeded: true,
+				ExceptionExpired:  true,
+				Exceptions:        []model.Exception{{Unit: "a.service", Owner: "@infra", Expires: "2026-01-31"}},
+				TopIssues:         []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5}},
+			},
+		},
+	}
+
+	md := MarkdownSummary(scan)
+	for _, want := range []string{
+		"⌛ expired exception",
+		"- Expired exception: a.service (expires: 2026-01-31, owner: @infra)",
+		"### Warnings\n\n- allowlist entry expires soon: b.service (expires: 2026-04-01)",
+	} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("expected %q in summary, got:\n%s", want, md)
+		}
+	}
+}
+
+func TestMarkdownSummaryShowsBaselineDeltas(t *testing.T) {
+	scan := model.ScanReport{
+		Threshold:    10,
+		BaselinePath: "ssg-baseline.json",
+		Units: []model.UnitReport{
+			{
+				UnitName: "a.service", RepoRelPath: "deploy/a.service", Threshold: 10,
+				OverallExposure: 4.5, OverallRating: "OK",
+				Baseline:  &model.BaselineDelta{PreviousExposure: 4.2, Delta: 0.3, IntroducedChecks: []string{"PrivateNetwork"}, ResolvedChecks: []string{"ProtectHome"}, Regressed: true},
+				TopIssues: []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5}},
+			},
+			{
+				UnitName: "b.service", RepoRelPath: "deploy/b.service", Threshold: 10,
+				OverallExposure: 3.0, OverallRating: "OK",
+				Baseline: &model.BaselineDelta{PreviousExposure: 3.5, Delta: -0.5, ResolvedChecks: []string{"NoNewPrivileges"}},
+			},
+			{
+				UnitName: "c.service", RepoRelPath: "deploy/c.service", Threshold: 10,
+				OverallExposure: 9.6, OverallRating: "UNSAFE",
+				Baseline: &model.BaselineDelta{New: true},
+			},
+		},
+	}
+
+	md := MarkdownSummary(scan)
+	for _, want := range []string{
+		"- Baseline: `ssg-baseline.json` (fail on regressions)",
+		"| `a.service` | `deploy/a.service` | ❌ regressed | 4.50 OK | 10.00 | +0.30 (was 4.20) |",
+		"| `b.service` | `deploy/b.service` | ✅ pass | 3.00 OK | 10.00 | -0.50 (was 3.50) |",
+		"| `c.service` | `deploy/c.service` | ✅ pass | 9.60 UNSAFE | 10.00 | new |",
+		"- `a.service`: introduced `PrivateNetwork`; resolved `ProtectHome`\n- `b.service`: resolved `NoNewPrivileges`",
+		"### a.service",
+	} {
+		if !strings.Contains(md, want) {
+			t.Fatalf("expected %q in summary, got:\n%s", want, md)
+		}
+	}
+}
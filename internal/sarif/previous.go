@@ -0,0 +1,81 @@
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+// Previous holds the fingerprinted results of an earlier scan, to compare a
+// new SARIF report against.
+type Previous struct {
+	results []Result
+	index   map[string]Result
+}
+
+// LoadPrevious reads a previous SARIF report, or a JSON report whose findings
+// in scope are converted the way FromScanReportWith would. Results of SARIF
+// reports written before fingerprints were added are ignored.
+func LoadPrevious(path string, scope Scope) (*Previous, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Runs  json.RawMessage `json:"runs"`
+		Units json.RawMessage `json:"units"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	var report Report
+	switch {
+	case probe.Runs != nil:
+		if err := json.Unmarshal(b, &report); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	case probe.Units != nil:
+		var scan model.ScanReport
+		if err := json.Unmarshal(b, &scan); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		report = FromScanReportWith(scan, Options{Scope: scope})
+	default:
+		return nil, fmt.Errorf("parse %s: neither a SARIF nor a JSON report", path)
+	}
+
+	prev := &Previous{index: map[string]Result{}}
+	for _, run := range report.Runs {
+		for _, r := range run.Results {
+			fp := r.PartialFingerprints[fingerprintKey]
+			if fp == "" || r.BaselineState == "absent" {
+				continue
+			}
+			if _, dup := prev.index[fp]; dup {
+				continue
+			}
+			r.BaselineState = ""
+			prev.index[fp] = r
+			prev.results = append(prev.results, r)
+		}
+	}
+	return prev, nil
+}
+
+// state is the baselineState of r: "new" without a previous result of the
+// same fingerprint, "updated" if its level or message changed (e.g. the
+// exposure), else "unchanged".
+func (p *Previous) state(r Result) string {
+	old, ok := p.index[r.PartialFingerprints[fingerprintKey]]
+	switch {
+	case !ok:
+		return "new"
+	case old.Level != r.Level || old.Message.Text != r.Message.Text:
+		return "updated"
+	default:
+		return "unchanged"
+	}
+}
@@ -0,0 +1,85 @@
+package sarif
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/teunlao/systemd-security-gate/internal/checkdoc"
+	"github.com/teunlao/systemd-security-gate/internal/model"
+)
+
+// knownRules describes the checks of the native catalog, keyed by rule ID.
+func knownRules() map[string]Rule {
+	docs := checkdoc.All()
+	rules := make(map[string]Rule, len(docs)+1)
+	for _, d := range docs {
+		rules[checkRuleID(d.JSONField)] = checkRule(d)
+	}
+	rules[model.ExpiredExceptionRuleID] = Rule{
+		ID:               model.ExpiredExceptionRuleID,
+		Name:             "ExpiredException",
+		ShortDescription: &Message{Text: "Allowlist exception expired"},
+		FullDescription:  &Message{Text: "The unit exceeds its threshold and only expired allowlist entries cover it."},
+		Help: &Help{
+			Text:     "Harden the unit, or renew the allowlist entry with a new expires date.",
+			Markdown: "Harden the unit until it passes the gate, or renew the allowlist entry with a new `expires` date (and review its `reason`, `owner` and `ticket`).",
+		},
+		DefaultConfiguration: &Configuration{Level: "error"},
+		Properties:           &RuleProperties{SecuritySeverity: "7.0", Tags: []string{"security", "systemd"}},
+	}
+	return rules
+}
+
+func checkRuleID(testID string) string {
+	return "systemd." + testID
+}
+
+func checkRule(d checkdoc.Doc) Rule {
+	desc := d.DescriptionBad
+	if desc == "" {
+		desc = fmt.Sprintf("Service is exposed by its %s setting", strings.TrimSuffix(d.Name, "="))
+	}
+
+	return Rule{
+		ID:               checkRuleID(d.JSONField),
+		Name:             d.JSONField,
+		ShortDescription: &Message{Text: d.Name},
+		FullDescription:  &Message{Text: desc},
+		Help: &Help{
+			Text:     fmt.Sprintf("%s. Recommended: %s.", desc, strings.ReplaceAll(d.Recommended, "`", "")),
+			Markdown: fmt.Sprintf("**%s**: %s.\n\n%s\n\nRecommended: %s\n\nMay break: %s\n\nSee [%s](%s).", d.Name, desc, d.Measures, d.Recommended, d.Breakages, d.ManPage(), d.ManURL),
+		},
+		HelpURI:              d.ManURL,
+		DefaultConfiguration: &Configuration{Level: levels[weightSeverity(d.Weight)]},
+		Properties: &RuleProperties{
+			SecuritySeverity: securitySeverity(d.Weight),
+			Tags:             []string{"security", "systemd"},
+		},
+	}
+}
+
+// securitySeverity scales a check weight to GitHub's 0-10 security-severity:
+// PrivateNetwork= (2500) is 10, User= (2000) 8, CAP_SYS_ADMIN (1500) 6.
+func securitySeverity(weight uint64) string {
+	return fmt.Sprintf("%.1f", min(float64(weight)/250, 10))
+}
+
+// weightSeverity rates a fully exposed check of the given weight like
+// model.Finding.Severity rates its exposure.
+func weightSeverity(weight uint64) string {
+	switch {
+	case weight >= 2000:
+		return model.SeverityHigh
+	case weight >= 1000:
+		return model.SeverityMedium
+	default:
+		return model.SeverityLow
+	}
+}
+
+// levels maps finding severities to SARIF result levels.
+var levels = map[string]string{
+	model.SeverityHigh:   "error",
+	model.SeverityMedium: "warning",
+	model.SeverityLow:    "note",
+}
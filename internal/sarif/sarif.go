@@ -3,8 +3,10 @@ package sarif
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/teunlao/systemd-security-gate/internal/hardening"
 	"github.com/teunlao/systemd-security-gate/internal/model"
 )
 
@@ -33,23 +35,79 @@ type Driver struct {
 }
 
 type Rule struct {
-	ID   string `json:"id"`
-	Name string `json:"name,omitempty"`
+	ID                   string          `json:"id"`
+	Name                 string          `json:"name,omitempty"`
+	ShortDescription     *Message        `json:"shortDescription,omitempty"`
+	FullDescription      *Message        `json:"fullDescription,omitempty"`
+	Help                 *Help           `json:"help,omitempty"`
+	HelpURI              string          `json:"helpUri,omitempty"`
+	DefaultConfiguration *Configuration  `json:"defaultConfiguration,omitempty"`
+	Properties           *RuleProperties `json:"properties,omitempty"`
+}
+
+type Help struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+}
+
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+// RuleProperties carries the properties GitHub Code Scanning reads:
+// security-severity (0-10) sets the alert severity.
+type RuleProperties struct {
+	SecuritySeverity string   `json:"security-severity,omitempty"`
+	Tags             []string `json:"tags,omitempty"`
 }
 
 type Invoc struct {
-	ExecutionSuccessful bool      `json:"executionSuccessful"`
-	StartTimeUTC        time.Time `json:"startTimeUtc,omitempty"`
-	EndTimeUTC          time.Time `json:"endTimeUtc,omitempty"`
+	ExecutionSuccessful        bool           `json:"executionSuccessful"`
+	StartTimeUTC               *time.Time     `json:"startTimeUtc,omitempty"`
+	EndTimeUTC                 *time.Time     `json:"endTimeUtc,omitempty"`
+	ToolExecutionNotifications []Notification `json:"toolExecutionNotifications,omitempty"`
 }
 
-type Result struct {
-	RuleID    string     `json:"ruleId"`
-	Level     string     `json:"level,omitempty"`
+// Notification reports a problem running the tool, such as a unit that
+// failed analysis.
+type Notification struct {
+	Level     string     `json:"level"`
 	Message   Message    `json:"message"`
 	Locations []Location `json:"locations,omitempty"`
 }
 
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level,omitempty"`
+	Message             Message           `json:"message"`
+	Locations           []Location        `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	BaselineState       string            `json:"baselineState,omitempty"`
+	Fixes               []Fix             `json:"fixes,omitempty"`
+}
+
+// Fix is a proposed change that code scanning can offer to apply.
+type Fix struct {
+	Description     Message          `json:"description"`
+	ArtifactChanges []ArtifactChange `json:"artifactChanges"`
+}
+
+type ArtifactChange struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Replacements     []Replacement    `json:"replacements"`
+}
+
+// Replacement inserts InsertedContent in place of DeletedRegion; an empty
+// region is a pure insertion.
+type Replacement struct {
+	DeletedRegion   Region   `json:"deletedRegion"`
+	InsertedContent *Content `json:"insertedContent,omitempty"`
+}
+
+type Content struct {
+	Text string `json:"text"`
+}
+
 type Message struct {
 	Text string `json:"text"`
 }
@@ -60,51 +118,177 @@ type Location struct {
 
 type PhysicalLocation struct {
 	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+type Region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
 }
 
 type ArtifactLocation struct {
 	URI string `json:"uri"`
 }
 
+// fingerprintKey names the partial fingerprint of results: the hash of the
+// repo path, unit name and rule (see model.Finding.Fingerprint).
+const fingerprintKey = "ssgFindingHash/v1"
+
+// suggestedFix changes the unit at loc, which is the check's current
+// assignment or the [Service] header, so directive takes effect: it replaces
+// an assignment of the same key, and otherwise goes after loc (past any
+// continuation lines) so it lands in the [Service] section and takes effect
+// last. A list directive (see hardening.IsList) would add to an existing
+// assignment rather than replace it, so it is only offered where the key is
+// unset. Without a line there is nowhere safe to put it.
+func suggestedFix(loc model.Location, directive string) []Fix {
+	if loc.Line == 0 {
+		return nil
+	}
+	key, _, _ := strings.Cut(directive, "=")
+	end := loc.EndLine
+	if end < loc.Line {
+		end = loc.Line
+	}
+
+	desc := "Add " + directive + " to [Service]"
+	rep := Replacement{
+		DeletedRegion:   Region{StartLine: end + 1, StartColumn: 1, EndLine: end + 1, EndColumn: 1},
+		InsertedContent: &Content{Text: directive + "\n"},
+	}
+	if loc.Key == key {
+		if hardening.IsList(key) {
+			return nil
+		}
+		// Whole lines, without the final line break.
+		desc = "Replace " + key + "= with " + directive
+		rep = Replacement{
+			DeletedRegion:   Region{StartLine: loc.Line, EndLine: end},
+			InsertedContent: &Content{Text: directive},
+		}
+	}
+	return []Fix{{
+		Description: Message{Text: desc},
+		ArtifactChanges: []ArtifactChange{{
+			ArtifactLocation: ArtifactLocation{URI: loc.Path},
+			Replacements:     []Replacement{rep},
+		}},
+	}}
+}
+
+// Scope selects which findings become results.
+type Scope string
+
+const (
+	// ScopeFailing reports the top issues of units that fail the gate.
+	ScopeFailing Scope = "failing"
+	// ScopeAll reports every exposed check of every analyzed unit; those of
+	// passing units at "note" level.
+	ScopeAll Scope = "all"
+)
+
+// findings returns the findings of scan in scope.
+func (s Scope) findings(scan model.ScanReport) []model.Finding {
+	if s == ScopeAll {
+		return model.AllFindings(scan)
+	}
+	return model.Findings(scan)
+}
+
+// Options tune FromScanReportWith.
+type Options struct {
+	// Scope defaults to ScopeFailing.
+	Scope Scope
+	// Previous, when set, is the earlier scan to set each result's
+	// baselineState against. Its findings that are gone are added as
+	// "absent" results so their alerts close.
+	Previous *Previous
+}
+
+// FromScanReport reports each finding as a result whose level follows the
+// finding's severity. Rules carry descriptions and remediation help for the
+// checks systemd 252 knows; other checks get a bare rule. Units that failed
+// analysis are tool execution notifications.
 func FromScanReport(scan model.ScanReport) Report {
+	return FromScanReportWith(scan, Options{})
+}
+
+// FromScanReportWith is FromScanReport with options.
+func FromScanReportWith(scan model.ScanReport, opts Options) Report {
+	prev := opts.Previous
+	known := knownRules()
 	rules := map[string]Rule{}
+	addRule := func(id, name string) {
+		rule, ok := known[id]
+		if !ok {
+			rule = Rule{ID: id, Name: name}
+		}
+		rules[id] = rule
+	}
 	var results []Result
+	seen := map[string]bool{}
 
-	for _, u := range scan.Units {
-		if u.Error != "" {
-			continue
+	for _, f := range opts.Scope.findings(scan) {
+		addRule(f.RuleID, f.RuleName)
+		level := levels[f.Severity()]
+		if f.Passing {
+			level = "note"
 		}
-		if u.Allowed {
-			continue
-		}
-		if !u.ThresholdExceeded {
-			continue
+		physical := PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: f.Location.Path}}
+		if f.Location.Line > 0 {
+			physical.Region = &Region{StartLine: f.Location.Line}
+			if f.Location.EndLine > f.Location.Line {
+				physical.Region.EndLine = f.Location.EndLine
+			}
 		}
-		for _, c := range u.TopIssues {
-			testID := c.JSONField
-			if testID == "" {
-				testID = c.Name
+		msg := f.Message
+		var fixes []Fix
+		if f.Check != nil {
+			if directive, ok := hardening.Suggest(*f.Check); ok {
+				msg += fmt.Sprintf(" Suggested: %s (about -%.2f exposure).", directive, f.Check.Exposure)
+				fixes = suggestedFix(f.Location, directive)
 			}
-			if testID == "" {
+		}
+		result := Result{
+			RuleID:              f.RuleID,
+			Level:               level,
+			Message:             Message{Text: msg},
+			Fixes:               fixes,
+			Locations:           []Location{{PhysicalLocation: physical}},
+			PartialFingerprints: map[string]string{fingerprintKey: f.Fingerprint()},
+		}
+		if prev != nil {
+			result.BaselineState = prev.state(result)
+			seen[f.Fingerprint()] = true
+		}
+		results = append(results, result)
+	}
+	if prev != nil {
+		for _, r := range prev.results {
+			if seen[r.PartialFingerprints[fingerprintKey]] {
 				continue
 			}
-			ruleID := "systemd." + testID
-			rules[ruleID] = Rule{ID: ruleID, Name: testID}
-
-			msg := fmt.Sprintf("%s exposure=%.2f: %s", testID, c.Exposure, c.Description)
-			results = append(results, Result{
-				RuleID:  ruleID,
-				Level:   "warning",
-				Message: Message{Text: msg},
-				Locations: []Location{
-					{
-						PhysicalLocation: PhysicalLocation{
-							ArtifactLocation: ArtifactLocation{URI: u.RepoRelPath},
-						},
-					},
-				},
-			})
+			addRule(r.RuleID, strings.TrimPrefix(r.RuleID, "systemd."))
+			r.BaselineState = "absent"
+			results = append(results, r)
+		}
+	}
+
+	invocation := Invoc{ExecutionSuccessful: true}
+	for _, u := range scan.Units {
+		if u.Error == "" {
+			continue
 		}
+		invocation.ExecutionSuccessful = false
+		invocation.ToolExecutionNotifications = append(invocation.ToolExecutionNotifications, Notification{
+			Level:   "error",
+			Message: Message{Text: u.UnitName + ": " + u.Error},
+			Locations: []Location{{
+				PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: u.RepoRelPath}},
+			}},
+		})
 	}
 
 	var ruleList []Rule
@@ -124,6 +308,7 @@ func FromScanReport(scan model.ScanReport) Report {
 						Rules: ruleList,
 					},
 				},
+				Inv:     []Invoc{invocation},
 				Results: results,
 			},
 		},
@@ -1,6 +1,11 @@
 package sarif
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/teunlao/systemd-security-gate/internal/model"
@@ -34,3 +39,218 @@ func TestFromScanReportSortsRules(t *testing.T) {
 		t.Fatalf("rules not sorted: %#v", rules)
 	}
 }
+
+func TestFromScanReportExpiredException(t *testing.T) {
+	scan := model.ScanReport{
+		Units: []model.UnitReport{
+			{
+				UnitName:          "a.service",
+				RepoRelPath:       "deploy/a.service",
+				ThresholdExceeded: true,
+				ExceptionExpired:  true,
+				Exceptions:        []model.Exception{{Unit: "a.service", Ticket: "OPS-1", Expires: "2026-01-31"}},
+				TopIssues:         []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5}},
+			},
+		},
+	}
+
+	results := FromScanReport(scan).Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("results len = %d, want 2", len(results))
+	}
+	if results[0].RuleID != "ssg.expired-exception" || results[0].Level != "error" || !strings.Contains(results[0].Message.Text, "ticket: OPS-1") {
+		t.Fatalf("unexpected expired exception result: %#v", results[0])
+	}
+	if results[1].RuleID != "systemd.PrivateNetwork" {
+		t.Fatalf("unexpected issue result: %#v", results[1])
+	}
+}
+
+func TestFromScanReportRuleMetadataAndLevels(t *testing.T) {
+	scan := model.ScanReport{
+		Units: []model.UnitReport{
+			{
+				UnitName:          "a.service",
+				RepoRelPath:       "deploy/a.service",
+				ThresholdExceeded: true,
+				TopIssues: []model.SecurityCheck{
+					{Name: "PrivateNetwork=", JSONField: "PrivateNetwork", Exposure: 0.5},
+					{Name: "CapabilityBoundingSet=~CAP_KILL", JSONField: "CapabilityBoundingSet_CAP_KILL", Exposure: 0.1},
+					{Name: "Future=", JSONField: "Future", Exposure: 0.2},
+				},
+			},
+		},
+	}
+
+	run := FromScanReport(scan).Runs[0]
+	levels := map[string]string{}
+	for _, r := range run.Results {
+		levels[r.RuleID] = r.Level
+	}
+	if levels["systemd.PrivateNetwork"] != "error" || levels["systemd.CapabilityBoundingSet_CAP_KILL"] != "note" || levels["systemd.Future"] != "warning" {
+		t.Fatalf("unexpected levels: %v", levels)
+	}
+
+	rules := map[string]Rule{}
+	for _, r := range run.Tool.Driver.Rules {
+		rules[r.ID] = r
+	}
+	pn := rules["systemd.PrivateNetwork"]
+	if pn.Properties == nil || pn.Properties.SecuritySeverity != "10.0" {
+		t.Fatalf("PrivateNetwork properties = %#v", pn.Properties)
+	}
+	if pn.Help == nil || !strings.Contains(pn.Help.Markdown, "`PrivateNetwork=yes`") || pn.FullDescription == nil {
+		t.Fatalf("PrivateNetwork help = %#v", pn.Help)
+	}
+	if kill := rules["systemd.CapabilityBoundingSet_CAP_KILL"]; kill.Help == nil || !strings.Contains(kill.Help.Markdown, "`CapabilityBoundingSet=~CAP_KILL`") {
+		t.Fatalf("CAP_KILL help = %#v", kill.Help)
+	}
+	if future := rules["systemd.Future"]; future.Help != nil || future.Name != "Future" {
+		t.Fatalf("unknown check rule = %#v", future)
+	}
+}
+
+func TestFromScanReportWithBaseline(t *testing.T) {
+	unit := func(checks ...model.SecurityCheck) model.ScanReport {
+		return model.ScanReport{Units: []model.UnitReport{{
+			UnitName:          "a.service",
+			RepoRelPath:       "deploy/a.service",
+			ThresholdExceeded: true,
+			TopIssues:         checks,
+		}}}
+	}
+	before := unit(
+		model.SecurityCheck{JSONField: "PrivateNetwork", Exposure: 0.5},
+		model.SecurityCheck{JSONField: "ProtectSystem", Exposure: 0.2},
+		model.SecurityCheck{JSONField: "PrivateTmp", Exposure: 0.2},
+	)
+	after := unit(
+		model.SecurityCheck{JSONField: "PrivateNetwork", Exposure: 0.5},
+		model.SecurityCheck{JSONField: "ProtectSystem", Exposure: 0.1},
+		model.SecurityCheck{JSONField: "ProtectHome", Exposure: 0.2},
+	)
+
+	dir := t.TempDir()
+	for name, v := range map[string]any{"prev.sarif": FromScanReport(before), "prev.json": before} {
+		path := filepath.Join(dir, name)
+		b, _ := json.Marshal(v)
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		prev, err := LoadPrevious(path, ScopeFailing)
+		if err != nil {
+			t.Fatalf("LoadPrevious(%s): %v", name, err)
+		}
+
+		states := map[string]string{}
+		for _, r := range FromScanReportWith(after, Options{Previous: prev}).Runs[0].Results {
+			if r.PartialFingerprints[fingerprintKey] == "" {
+				t.Fatalf("%s: result %s has no fingerprint", name, r.RuleID)
+			}
+			states[r.RuleID] = r.BaselineState
+		}
+		want := map[string]string{
+			"systemd.PrivateNetwork": "unchanged",
+			"systemd.ProtectSystem":  "updated",
+			"systemd.ProtectHome":    "new",
+			"systemd.PrivateTmp":     "absent",
+		}
+		if fmt.Sprint(states) != fmt.Sprint(want) {
+			t.Fatalf("%s: states = %v, want %v", name, states, want)
+		}
+	}
+
+	if r := FromScanReport(after).Runs[0].Results[0]; r.BaselineState != "" {
+		t.Fatalf("baselineState without a baseline = %q", r.BaselineState)
+	}
+}
+
+func TestFromScanReportScopeAll(t *testing.T) {
+	scan := model.ScanReport{
+		Units: []model.UnitReport{
+			{
+				UnitName:          "a.service",
+				RepoRelPath:       "deploy/a.service",
+				ThresholdExceeded: true,
+				Checks: []model.SecurityCheck{
+					{JSONField: "PrivateNetwork", Exposure: 0.5},
+					{JSONField: "PrivateTmp", Exposure: 0.2},
+					{JSONField: "NoNewPrivileges", Exposure: 0},
+				},
+				TopIssues: []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5}},
+			},
+			{
+				UnitName:    "b.service",
+				RepoRelPath: "deploy/b.service",
+				Checks:      []model.SecurityCheck{{JSONField: "PrivateNetwork", Exposure: 0.5}},
+			},
+			{UnitName: "c.service", RepoRelPath: "deploy/c.service", Error: "boom"},
+		},
+	}
+
+	if results := FromScanReport(scan).Runs[0].Results; len(results) != 1 {
+		t.Fatalf("failing scope results len = %d, want 1", len(results))
+	}
+
+	run := FromScanReportWith(scan, Options{Scope: ScopeAll}).Runs[0]
+	var got []string
+	for _, r := range run.Results {
+		got = append(got, r.Locations[0].PhysicalLocation.ArtifactLocation.URI+" "+r.RuleID+" "+r.Level)
+	}
+	want := []string{
+		"deploy/a.service systemd.PrivateNetwork error",
+		"deploy/a.service systemd.PrivateTmp warning",
+		"deploy/b.service systemd.PrivateNetwork note",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("results:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if len(run.Inv) != 1 || run.Inv[0].ExecutionSuccessful {
+		t.Fatalf("invocations = %#v, want one unsuccessful invocation", run.Inv)
+	}
+	notes := run.Inv[0].ToolExecutionNotifications
+	if len(notes) != 1 || notes[0].Level != "error" || notes[0].Message.Text != "c.service: boom" {
+		t.Fatalf("notifications = %#v", notes)
+	}
+}
+
+func TestSuggestedFix(t *testing.T) {
+	insert := func(line int, text string) *Replacement {
+		return &Replacement{DeletedRegion: Region{StartLine: line, StartColumn: 1, EndLine: line, EndColumn: 1}, InsertedContent: &Content{Text: text}}
+	}
+	for _, tc := range []struct {
+		name      string
+		loc       model.Location
+		directive string
+		want      *Replacement
+	}{
+		{"after header", model.Location{Path: "a.service", Line: 4, EndLine: 4}, "PrivateNetwork=yes", insert(5, "PrivateNetwork=yes\n")},
+		{"replaces assignment", model.Location{Path: "a.service", Line: 6, EndLine: 6, Key: "ProtectSystem"}, "ProtectSystem=strict",
+			&Replacement{DeletedRegion: Region{StartLine: 6, EndLine: 6}, InsertedContent: &Content{Text: "ProtectSystem=strict"}}},
+		{"replaces continued assignment", model.Location{Path: "a.service", Line: 6, EndLine: 8, Key: "ProtectSystem"}, "ProtectSystem=strict",
+			&Replacement{DeletedRegion: Region{StartLine: 6, EndLine: 8}, InsertedContent: &Content{Text: "ProtectSystem=strict"}}},
+		{"after continued assignment of another key", model.Location{Path: "a.service", Line: 6, EndLine: 7, Key: "User"}, "DynamicUser=yes", insert(8, "DynamicUser=yes\n")},
+		{"list after header", model.Location{Path: "a.service", Line: 1, EndLine: 1}, "CapabilityBoundingSet=~CAP_SYS_ADMIN", insert(2, "CapabilityBoundingSet=~CAP_SYS_ADMIN\n")},
+		{"list already assigned", model.Location{Path: "a.service", Line: 3, EndLine: 4, Key: "CapabilityBoundingSet"}, "CapabilityBoundingSet=~CAP_SYS_ADMIN", nil},
+		{"system call list already assigned", model.Location{Path: "a.service", Line: 3, EndLine: 3, Key: "SystemCallFilter"}, "SystemCallFilter=~@mount", nil},
+		{"no line", model.Location{Path: "a.service"}, "PrivateNetwork=yes", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fixes := suggestedFix(tc.loc, tc.directive)
+			if tc.want == nil {
+				if fixes != nil {
+					t.Fatalf("suggestedFix() = %#v, want none", fixes)
+				}
+				return
+			}
+			if len(fixes) != 1 || len(fixes[0].ArtifactChanges) != 1 || fixes[0].ArtifactChanges[0].ArtifactLocation.URI != tc.loc.Path {
+				t.Fatalf("suggestedFix() = %#v", fixes)
+			}
+			got := fixes[0].ArtifactChanges[0].Replacements
+			if len(got) != 1 || got[0].DeletedRegion != tc.want.DeletedRegion || *got[0].InsertedContent != *tc.want.InsertedContent {
+				t.Fatalf("replacements = %#v, want %#v", got, *tc.want)
+			}
+		})
+	}
+}
@@ -58,6 +58,33 @@ func TestSecurityOverall_PassWhenThresholdHigh(t *testing.T) {
 	}
 }
 
+// systemd-analyze only compares whole steps of its 0..100 scale, so its exit
+// code can't gate on a threshold between two of them.
+func TestSecurityOverall_ComparesPrintedExposure(t *testing.T) {
+	stub := writeSystemdAnalyzeStub(t, stubCfg{})
+
+	for _, tc := range []struct {
+		threshold float64
+		want      bool
+	}{
+		{7.15, true},
+		{7.2, false},
+		{7.25, false},
+	} {
+		got, err := SecurityOverall(stub, SecurityOverallArgs{
+			Root:      t.TempDir(),
+			UnitName:  "myapp.service",
+			Threshold: tc.threshold,
+		})
+		if err != nil {
+			t.Fatalf("SecurityOverall() error = %v", err)
+		}
+		if got.ThresholdExceeded != tc.want {
+			t.Fatalf("threshold %v: ThresholdExceeded = %v, want %v", tc.threshold, got.ThresholdExceeded, tc.want)
+		}
+	}
+}
+
 func TestSecurityTable_ParsesJSON(t *testing.T) {
 	stub := writeSystemdAnalyzeStub(t, stubCfg{})
 	root := t.TempDir()
@@ -96,8 +123,90 @@ func TestSecurityTable_ErrorsOnNonZeroExit(t *testing.T) {
 	}
 }
 
+func TestSecurity_DerivesOverallFromTable(t *testing.T) {
+	table, err := os.ReadFile(filepath.Join("..", "nativeanalyze", "testdata", "parity", "default", "security.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := writeSystemdAnalyzeStub(t, stubCfg{table: string(table), failOverall: true})
+
+	got, err := Security(stub, SecurityArgs{
+		Root:      t.TempDir(),
+		UnitName:  "myapp.service",
+		Threshold: 9.0,
+		Version:   "systemd 252 (stub)",
+	})
+	if err != nil {
+		t.Fatalf("Security() error = %v", err)
+	}
+	if !got.Derived {
+		t.Fatalf("Derived = false, want true")
+	}
+	if got.OverallExposure != 9.6 || got.OverallRating != "UNSAFE" {
+		t.Fatalf("overall = %v %s, want 9.6 UNSAFE", got.OverallExposure, got.OverallRating)
+	}
+	if !got.ThresholdExceeded {
+		t.Fatalf("ThresholdExceeded = false, want true")
+	}
+}
+
+func TestSecurity_FallsBackToTextReport(t *testing.T) {
+	table, err := os.ReadFile(filepath.Join("..", "nativeanalyze", "testdata", "parity", "default", "security.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, cfg := range map[string]struct {
+		table   string
+		version string
+	}{
+		"other version": {table: string(table), version: "systemd 255 (255.4-1)"},
+		"unknown table": {version: "systemd 252 (stub)"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stub := writeSystemdAnalyzeStub(t, stubCfg{table: cfg.table})
+			got, err := Security(stub, SecurityArgs{
+				Root:      t.TempDir(),
+				UnitName:  "myapp.service",
+				Threshold: 9.0,
+				Version:   cfg.version,
+			})
+			if err != nil {
+				t.Fatalf("Security() error = %v", err)
+			}
+			if got.Derived {
+				t.Fatalf("Derived = true, want false")
+			}
+			if got.OverallExposure != 7.2 || got.ThresholdExceeded {
+				t.Fatalf("overall = %v (exceeded %v), want 7.2 below threshold", got.OverallExposure, got.ThresholdExceeded)
+			}
+		})
+	}
+}
+
+func TestSecurity_VerifyReportsMismatch(t *testing.T) {
+	table, err := os.ReadFile(filepath.Join("..", "nativeanalyze", "testdata", "parity", "default", "security.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub := writeSystemdAnalyzeStub(t, stubCfg{table: string(table)})
+
+	_, err = Security(stub, SecurityArgs{
+		Root:      t.TempDir(),
+		UnitName:  "myapp.service",
+		Threshold: 9.0,
+		Version:   "systemd 252 (stub)",
+		Verify:    true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "(9.6 UNSAFE) differs from systemd-analyze (7.2 EXPOSED)") {
+		t.Fatalf("expected mismatch error, got: %v", err)
+	}
+}
+
 type stubCfg struct {
-	failJSON bool
+	failJSON    bool
+	failOverall bool
+	// table replaces the --json=short output.
+	table string
 }
 
 func writeSystemdAnalyzeStub(t *testing.T, cfg stubCfg) string {
@@ -109,11 +218,23 @@ func writeSystemdAnalyzeStub(t *testing.T, cfg stubCfg) string {
 	if cfg.failJSON {
 		failJSON = "1"
 	}
+	failOverall := "0"
+	if cfg.failOverall {
+		failOverall = "1"
+	}
+	table := cfg.table
+	if table == "" {
+		table = `[
+  {"set":false,"name":"PrivateNetwork=","json_field":"PrivateNetwork","description":"Service has access to the host's network","exposure":"0.5"},
+  {"set":true,"name":"NoNewPrivileges=","json_field":"NoNewPrivileges","description":"Service cannot gain new privileges","exposure":null}
+]`
+	}
 
 	script := `#!/usr/bin/env sh
 set -eu
 
 FAIL_JSON="` + failJSON + `"
+FAIL_OVERALL="` + failOverall + `"
 
 if [ "${1-}" = "--version" ]; then
   echo "systemd 252 (stub)"
@@ -139,17 +260,19 @@ if [ "${1-}" = "security" ]; then
         exit 2
       fi
       cat <<'JSON'
-[
-  {"set":false,"name":"PrivateNetwork=","json_field":"PrivateNetwork","description":"Service has access to the host's network","exposure":"0.5"},
-  {"set":true,"name":"NoNewPrivileges=","json_field":"NoNewPrivileges","description":"Service cannot gain new privileges","exposure":null}
-]
+` + table + `
 JSON
       exit 0
       ;;
   esac
 
+  if [ "$FAIL_OVERALL" = "1" ]; then
+    echo "text report not expected" >&2
+    exit 2
+  fi
+
   echo "→ Overall exposure level for $unit: 7.2 EXPOSED 🙂"
-  awk -v e="7.2" -v t="$threshold" 'BEGIN{exit (e>t)?1:0}'
+  awk -v e="7.2" -v t="$threshold" 'BEGIN{exit (e*10>t)?1:0}'
   exit $?
 fi
 
@@ -0,0 +1,167 @@
+package systemdanalyze
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/nativeanalyze"
+)
+
+type SecurityArgs struct {
+	Root       string
+	UnitName   string
+	PolicyPath string
+	Threshold  float64
+	// Version is the --version line of systemd-analyze. The overall exposure
+	// is derived from the JSON table only for the systemd version the check
+	// catalog follows.
+	Version string
+	// Verify also runs the text report and fails when it disagrees with the
+	// derived overall exposure.
+	Verify bool
+}
+
+type SecurityResult struct {
+	OverallExposure   float64
+	OverallRating     string
+	ThresholdExceeded bool
+	Checks            []model.SecurityCheck
+	// Derived is set when the overall exposure came from the JSON table.
+	Derived bool
+}
+
+// Security analyzes a unit with a single "systemd-analyze security --json"
+// run where possible, falling back to the text report for the overall
+// exposure on other systemd versions or tables it can't account for.
+func Security(systemdAnalyzePath string, args SecurityArgs) (SecurityResult, error) {
+	table, err := SecurityTable(systemdAnalyzePath, SecurityTableArgs{
+		Root:       args.Root,
+		UnitName:   args.UnitName,
+		PolicyPath: args.PolicyPath,
+	})
+	if err != nil {
+		return SecurityResult{}, err
+	}
+	res := SecurityResult{Checks: table.Checks}
+
+	if major, ok := VersionMajor(args.Version); ok && major == nativeanalyze.TargetMajor {
+		catalog, err := nativeanalyze.Catalog(args.PolicyPath)
+		if err != nil {
+			return SecurityResult{}, err
+		}
+		if exposure, ok := overallFromTable(table.Checks, catalog); ok {
+			res.OverallExposure = float64(exposure) / 10
+			res.OverallRating = nativeanalyze.Rating(exposure)
+			res.ThresholdExceeded = res.OverallExposure > args.Threshold
+			res.Derived = true
+		}
+	}
+	if res.Derived && !args.Verify {
+		return res, nil
+	}
+
+	overall, err := SecurityOverall(systemdAnalyzePath, SecurityOverallArgs{
+		Root:       args.Root,
+		UnitName:   args.UnitName,
+		PolicyPath: args.PolicyPath,
+		Threshold:  args.Threshold,
+	})
+	if err != nil {
+		return SecurityResult{}, err
+	}
+	if res.Derived && (overall.OverallExposure != res.OverallExposure || overall.OverallRating != res.OverallRating) {
+		return SecurityResult{}, fmt.Errorf("overall exposure derived from the JSON table (%.1f %s) differs from systemd-analyze (%.1f %s)",
+			res.OverallExposure, res.OverallRating, overall.OverallExposure, overall.OverallRating)
+	}
+	res.OverallExposure = overall.OverallExposure
+	res.OverallRating = overall.OverallRating
+	res.ThresholdExceeded = overall.ThresholdExceeded
+	return res, nil
+}
+
+var versionRe = regexp.MustCompile(`^systemd ([0-9]+)\b`)
+
+// VersionMajor extracts NNN from a "systemd NNN (...)" version line.
+func VersionMajor(version string) (int, bool) {
+	m := versionRe.FindStringSubmatch(version)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
+
+// overallFromTable reconstructs systemd's overall exposure (on its 0..100
+// scale) from the --json=short table, which only carries each check's
+// rounded share of it. The badness of an exposed check comes from its
+// description, checked against its share; ok is false when the table doesn't
+// match the catalog.
+func overallFromTable(checks []model.SecurityCheck, catalog []nativeanalyze.Check) (exposure uint64, ok bool) {
+	if len(checks) != len(catalog) {
+		return 0, false
+	}
+	byField := make(map[string]nativeanalyze.Check, len(catalog))
+	for _, c := range catalog {
+		byField[c.JSONField] = c
+	}
+
+	// Checks that are neither set nor exposed were not applicable.
+	var weightSum uint64
+	for _, c := range checks {
+		info, found := byField[c.JSONField]
+		if !found {
+			return 0, false
+		}
+		if c.Set || c.Exposure > 0 {
+			weightSum += info.Weight
+		}
+	}
+	if weightSum == 0 {
+		return 0, false
+	}
+
+	var badnessSum uint64
+	for _, c := range checks {
+		if c.Exposure <= 0 {
+			continue
+		}
+		info := byField[c.JSONField]
+		if info.Range == 0 {
+			return 0, false
+		}
+		share := uint64(math.Round(c.Exposure * 10))
+		b, known := info.Badness(c.Description)
+		if known {
+			if divRoundUp(b*info.Weight*100, info.Range*weightSum) != share {
+				return 0, false
+			}
+		} else if b, known = badnessFromShare(share, info, weightSum); !known {
+			return 0, false
+		}
+		badnessSum += divRoundUp(b*info.Weight, info.Range)
+	}
+	return divRoundUp(badnessSum*100, weightSum), true
+}
+
+// badnessFromShare finds the badness whose share of the overall exposure is
+// share, for descriptions the catalog doesn't know (e.g. policy overrides).
+func badnessFromShare(share uint64, info nativeanalyze.Check, weightSum uint64) (uint64, bool) {
+	var badness uint64
+	for b := uint64(1); b <= info.Range; b++ {
+		if divRoundUp(b*info.Weight*100, info.Range*weightSum) != share {
+			continue
+		}
+		if badness != 0 {
+			return 0, false
+		}
+		badness = b
+	}
+	return badness, badness != 0
+}
+
+func divRoundUp(x, y uint64) uint64 {
+	return (x + y - 1) / y
+}
@@ -0,0 +1,102 @@
+package systemdanalyze
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/teunlao/systemd-security-gate/internal/model"
+	"github.com/teunlao/systemd-security-gate/internal/nativeanalyze"
+)
+
+// TestOverallFromTableParity derives the overall exposure from the
+// --json=short tables recorded for the native analyzer's parity tests.
+func TestOverallFromTableParity(t *testing.T) {
+	cases, err := filepath.Glob(filepath.Join("..", "nativeanalyze", "testdata", "parity", "*", "security.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("no parity cases found")
+	}
+	for _, path := range cases {
+		dir := filepath.Dir(path)
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			policy := filepath.Join(dir, "policy.json")
+			if _, err := os.Stat(policy); err != nil {
+				policy = ""
+			}
+			catalog, err := nativeanalyze.Catalog(policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exposure, ok := overallFromTable(readTable(t, path), catalog)
+			if !ok {
+				t.Fatalf("could not derive overall exposure")
+			}
+			want, err := os.ReadFile(filepath.Join(dir, "overall.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := fmt.Sprintf("%d.%d %s", exposure/10, exposure%10, nativeanalyze.Rating(exposure))
+			if got != strings.TrimSpace(string(want)) {
+				t.Fatalf("overall = %q, want %q", got, strings.TrimSpace(string(want)))
+			}
+		})
+	}
+}
+
+func TestOverallFromTableRejectsUnknownTables(t *testing.T) {
+	catalog, err := nativeanalyze.Catalog("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := readTable(t, filepath.Join("..", "nativeanalyze", "testdata", "parity", "default", "security.json"))
+
+	if _, ok := overallFromTable(table[1:], catalog); ok {
+		t.Errorf("expected a short table to be rejected")
+	}
+	renamed := append([]model.SecurityCheck(nil), table...)
+	renamed[0].JSONField = "SomethingNew"
+	if _, ok := overallFromTable(renamed, catalog); ok {
+		t.Errorf("expected an unknown check to be rejected")
+	}
+	impossible := append([]model.SecurityCheck(nil), table...)
+	for i := range impossible {
+		if impossible[i].Exposure > 0 {
+			impossible[i].Exposure = 9.9
+			break
+		}
+	}
+	if _, ok := overallFromTable(impossible, catalog); ok {
+		t.Errorf("expected an impossible share to be rejected")
+	}
+}
+
+func TestVersionMajor(t *testing.T) {
+	cases := map[string]int{"systemd 252 (252.39-1~deb12u1)": 252, "systemd 255 (255.4-1ubuntu8)": 255}
+	for in, want := range cases {
+		if got, ok := VersionMajor(in); !ok || got != want {
+			t.Errorf("VersionMajor(%q) = %d, %v", in, got, ok)
+		}
+	}
+	if _, ok := VersionMajor("libsystemd 252"); ok {
+		t.Errorf("expected no version")
+	}
+}
+
+func readTable(t *testing.T, path string) []model.SecurityCheck {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks, err := parseTable(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checks
+}
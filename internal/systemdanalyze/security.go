@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -37,7 +38,7 @@ func SecurityOverall(systemdAnalyzePath string, args SecurityOverallArgs) (Secur
 		"--no-pager",
 		"--offline=yes",
 		"--root=" + args.Root,
-		"--threshold=" + trimFloat(args.Threshold),
+		"--threshold=" + thresholdArg(args.Threshold),
 	}
 	if args.PolicyPath != "" {
 		cmdArgs = append(cmdArgs, "--security-policy="+args.PolicyPath)
@@ -63,13 +64,22 @@ func SecurityOverall(systemdAnalyzePath string, args SecurityOverallArgs) (Secur
 		return SecurityOverallResult{}, fmt.Errorf("parse exposure: %w", err)
 	}
 
+	// The exit code only compares whole steps of the 0..100 scale, so it is
+	// not used: the gate compares the printed exposure like the other
+	// backends do.
 	return SecurityOverallResult{
 		OverallExposure:   exposure,
 		OverallRating:     m[2],
-		ThresholdExceeded: res.ExitCode != 0,
+		ThresholdExceeded: exposure > args.Threshold,
 	}, nil
 }
 
+// thresholdArg renders a threshold for --threshold, which systemd-analyze
+// compares on its internal 0..100 scale rather than the 0..10 one it prints.
+func thresholdArg(threshold float64) string {
+	return strconv.FormatInt(int64(math.Round(threshold*10)), 10)
+}
+
 type SecurityTableArgs struct {
 	Root       string
 	UnitName   string
@@ -109,6 +119,15 @@ func SecurityTable(systemdAnalyzePath string, args SecurityTableArgs) (SecurityT
 		return SecurityTableResult{}, fmt.Errorf("systemd-analyze security --json failed (exit=%d): %s", res.ExitCode, snippet(out, 800))
 	}
 
+	checks, err := parseTable([]byte(res.Stdout))
+	if err != nil {
+		return SecurityTableResult{}, fmt.Errorf("parse JSON: %w (output: %s)", err, snippet(res.Stdout, 800))
+	}
+	return SecurityTableResult{Checks: checks}, nil
+}
+
+// parseTable reads the output of "systemd-analyze security --json=short".
+func parseTable(b []byte) ([]model.SecurityCheck, error) {
 	var raw []struct {
 		Set         any             `json:"set"`
 		Name        string          `json:"name"`
@@ -116,8 +135,8 @@ func SecurityTable(systemdAnalyzePath string, args SecurityTableArgs) (SecurityT
 		Description string          `json:"description"`
 		Exposure    flexibleFloat64 `json:"exposure"`
 	}
-	if err := json.Unmarshal([]byte(res.Stdout), &raw); err != nil {
-		return SecurityTableResult{}, fmt.Errorf("parse JSON: %w (output: %s)", err, snippet(res.Stdout, 800))
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
 	}
 
 	checks := make([]model.SecurityCheck, 0, len(raw))
@@ -130,8 +149,7 @@ func SecurityTable(systemdAnalyzePath string, args SecurityTableArgs) (SecurityT
 			Exposure:    float64(r.Exposure),
 		})
 	}
-
-	return SecurityTableResult{Checks: checks}, nil
+	return checks, nil
 }
 
 func coerceBool(v any) bool {
@@ -154,16 +172,6 @@ func coerceBool(v any) bool {
 	}
 }
 
-func trimFloat(f float64) string {
-	s := fmt.Sprintf("%.6f", f)
-	s = strings.TrimRight(s, "0")
-	s = strings.TrimRight(s, ".")
-	if s == "" {
-		return "0"
-	}
-	return s
-}
-
 func parseFloat(s string) (float64, error) {
 	return strconv.ParseFloat(s, 64)
 }
@@ -19,6 +19,20 @@ func TestOverallRegex(t *testing.T) {
 	}
 }
 
+func TestThresholdArg(t *testing.T) {
+	for threshold, want := range map[float64]string{
+		0:    "0",
+		6:    "60",
+		7.15: "72",
+		9.6:  "96",
+		10:   "100",
+	} {
+		if got := thresholdArg(threshold); got != want {
+			t.Fatalf("thresholdArg(%v) = %q, want %q", threshold, got, want)
+		}
+	}
+}
+
 func TestCoerceBool(t *testing.T) {
 	tests := []struct {
 		in   any
@@ -0,0 +1,261 @@
+package unitfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Entry is a single "Key=Value" assignment inside a unit file section.
+type Entry struct {
+	Section string
+	Key     string
+	Value   string
+	Line    int
+	// EndLine is the last line of the assignment, past any backslash
+	// continuations.
+	EndLine int
+	// Path is the file the entry was read from (empty for Parse).
+	Path string
+}
+
+// Header is a "[Section]" line.
+type Header struct {
+	Section string
+	Line    int
+	Path    string
+}
+
+// Comment is a "#" or ";" comment line. Text omits the marker and
+// surrounding space.
+type Comment struct {
+	Text string
+	Line int
+	Path string
+}
+
+// Position is a line in a unit file or drop-in: an assignment of Key that
+// runs to EndLine, or a section header when Key is empty.
+type Position struct {
+	Path    string
+	Line    int
+	EndLine int
+	Key     string
+}
+
+// File holds the assignments of a unit file (and optionally its drop-ins) in
+// the order systemd would apply them.
+type File struct {
+	Entries  []Entry
+	Headers  []Header
+	Comments []Comment
+}
+
+func Parse(r io.Reader) (File, error) {
+	var f File
+	var section string
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimSpace(sc.Text())
+		start := lineNo
+
+		for strings.HasSuffix(line, "\\") && !isComment(line) {
+			line = strings.TrimSpace(strings.TrimSuffix(line, "\\"))
+			if !sc.Scan() {
+				break
+			}
+			lineNo++
+			next := strings.TrimSpace(sc.Text())
+			if isComment(next) {
+				f.Comments = append(f.Comments, comment(next, lineNo))
+				continue
+			}
+			line += " " + next
+		}
+
+		if isComment(line) {
+			f.Comments = append(f.Comments, comment(line, start))
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			f.Headers = append(f.Headers, Header{Section: section, Line: start})
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return File{}, fmt.Errorf("line %d: expected Key=Value, got %q", start, line)
+		}
+		f.Entries = append(f.Entries, Entry{
+			Section: section,
+			Key:     strings.TrimSpace(key),
+			Value:   strings.TrimSpace(value),
+			Line:    start,
+			EndLine: lineNo,
+		})
+	}
+	if err := sc.Err(); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+func ParseFile(path string) (File, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return File{}, err
+	}
+	defer in.Close()
+
+	f, err := Parse(in)
+	if err != nil {
+		return File{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range f.Entries {
+		f.Entries[i].Path = path
+	}
+	for i := range f.Headers {
+		f.Headers[i].Path = path
+	}
+	for i := range f.Comments {
+		f.Comments[i].Path = path
+	}
+	return f, nil
+}
+
+// ParseWithDropIns parses the unit file at path and merges the "*.conf"
+// drop-ins found in "<path>.d/" in lexical order.
+func ParseWithDropIns(path string) (File, error) {
+	return ParseWithDropInDirs(path, path+".d")
+}
+
+// ParseWithDropInDirs parses the unit file at path and merges the "*.conf"
+// drop-ins of dirs ordered by file name, like systemd does across drop-in
+// directories. For the same file name, the earlier dir wins.
+func ParseWithDropInDirs(path string, dirs ...string) (File, error) {
+	f, err := ParseFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	dropIns := map[string]string{}
+	var order []string
+	for _, dir := range dirs {
+		paths, err := DropInPaths(dir)
+		if err != nil {
+			return File{}, err
+		}
+		for _, p := range paths {
+			base := filepath.Base(p)
+			if _, ok := dropIns[base]; ok {
+				continue
+			}
+			dropIns[base] = p
+			order = append(order, base)
+		}
+	}
+	sort.Strings(order)
+	for _, base := range order {
+		d, err := ParseFile(dropIns[base])
+		if err != nil {
+			return File{}, err
+		}
+		f = f.Merge(d)
+	}
+	return f, nil
+}
+
+// DropInPaths lists the "*.conf" files in dir in lexical order. A missing dir
+// is not an error.
+func DropInPaths(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read drop-in dir %s: %w", dir, err)
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".conf" {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Merge appends the entries of the given files (typically drop-ins, in order)
+// so later assignments override earlier ones.
+func (f File) Merge(others ...File) File {
+	out := File{
+		Entries:  append([]Entry(nil), f.Entries...),
+		Headers:  append([]Header(nil), f.Headers...),
+		Comments: append([]Comment(nil), f.Comments...),
+	}
+	for _, o := range others {
+		out.Entries = append(out.Entries, o.Entries...)
+		out.Headers = append(out.Headers, o.Headers...)
+		out.Comments = append(out.Comments, o.Comments...)
+	}
+	return out
+}
+
+// Locate returns where the effective value of any of keys in section is set:
+// the last such assignment, or else the first header of section. ok is false
+// if the section appears nowhere.
+func (f File) Locate(section string, keys ...string) (Position, bool) {
+	for i := len(f.Entries) - 1; i >= 0; i-- {
+		e := f.Entries[i]
+		if e.Section != section {
+			continue
+		}
+		for _, k := range keys {
+			if e.Key == k {
+				return Position{Path: e.Path, Line: e.Line, EndLine: e.EndLine, Key: e.Key}, true
+			}
+		}
+	}
+	for _, h := range f.Headers {
+		if h.Section == section {
+			return Position{Path: h.Path, Line: h.Line, EndLine: h.Line}, true
+		}
+	}
+	return Position{}, false
+}
+
+// Lookup returns the effective value of key in section: the last assignment
+// wins, and an empty assignment resets the value.
+func (f File) Lookup(section, key string) (string, bool) {
+	var value string
+	var found bool
+	for _, e := range f.Entries {
+		if e.Section != section || e.Key != key {
+			continue
+		}
+		value = e.Value
+		found = e.Value != ""
+	}
+	return value, found
+}
+
+func comment(line string, lineNo int) Comment {
+	return Comment{Text: strings.TrimSpace(line[1:]), Line: lineNo}
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";")
+}
@@ -0,0 +1,110 @@
+package unitfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSectionsCommentsAndContinuations(t *testing.T) {
+	in := `# leading comment
+[Unit]
+Description=demo
+
+[Service]
+; another comment
+ExecStart=/bin/echo \
+  hello
+User=app
+User=
+`
+	f, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(f.Entries) != 4 {
+		t.Fatalf("entries len = %d, want 4: %#v", len(f.Entries), f.Entries)
+	}
+	exec := f.Entries[1]
+	if exec.Section != "Service" || exec.Key != "ExecStart" || exec.Value != "/bin/echo hello" || exec.Line != 7 || exec.EndLine != 8 {
+		t.Fatalf("ExecStart entry = %#v", exec)
+	}
+	if _, ok := f.Lookup("Service", "User"); ok {
+		t.Fatalf("expected empty assignment to reset User=")
+	}
+	if v, ok := f.Lookup("Unit", "Description"); !ok || v != "demo" {
+		t.Fatalf("Lookup(Description) = %q, %v", v, ok)
+	}
+	if len(f.Comments) != 2 || f.Comments[1] != (Comment{Text: "another comment", Line: 6}) {
+		t.Fatalf("comments = %#v", f.Comments)
+	}
+}
+
+func TestParseRejectsGarbage(t *testing.T) {
+	if _, err := Parse(strings.NewReader("[Service]\nnot an assignment\n")); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestParseWithDropInsLastAssignmentWins(t *testing.T) {
+	dir := t.TempDir()
+	unit := filepath.Join(dir, "myapp.socket")
+	mustWrite(t, unit, "[Socket]\nListenStream=80\nUnit=a.service\n")
+	mustWrite(t, filepath.Join(unit+".d", "20-b.conf"), "[Socket]\nUnit=c.service\n")
+	mustWrite(t, filepath.Join(unit+".d", "10-a.conf"), "[Socket]\nUnit=b.service\n")
+	mustWrite(t, filepath.Join(unit+".d", "README"), "not a drop-in")
+
+	f, err := ParseWithDropIns(unit)
+	if err != nil {
+		t.Fatalf("ParseWithDropIns() error = %v", err)
+	}
+	if v, _ := f.Lookup("Socket", "Unit"); v != "c.service" {
+		t.Fatalf("Unit = %q, want c.service", v)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	dir := t.TempDir()
+	unit := filepath.Join(dir, "myapp.service")
+	mustWrite(t, unit, "[Unit]\nDescription=demo\n\n[Service]\nExecStart=/bin/true\nPrivateTmp=yes\n")
+	mustWrite(t, filepath.Join(unit+".d", "10-net.conf"), "# override\n[Service]\nPrivateNetwork=no\n")
+	instanceDir := filepath.Join(dir, "myapp@a.service.d")
+	mustWrite(t, filepath.Join(instanceDir, "10-net.conf"), "[Service]\n\nPrivateNetwork=yes\n")
+
+	f, err := ParseWithDropIns(unit)
+	if err != nil {
+		t.Fatalf("ParseWithDropIns() error = %v", err)
+	}
+	if pos, ok := f.Locate("Service", "PrivateTmp"); !ok || pos != (Position{Path: unit, Line: 6, EndLine: 6, Key: "PrivateTmp"}) {
+		t.Fatalf("Locate(PrivateTmp) = %#v, %v", pos, ok)
+	}
+	if pos, ok := f.Locate("Service", "PrivateNetwork"); !ok || pos.Path != filepath.Join(unit+".d", "10-net.conf") || pos.Line != 3 {
+		t.Fatalf("Locate(PrivateNetwork) = %#v, %v", pos, ok)
+	}
+	if pos, ok := f.Locate("Service", "User", "DynamicUser"); !ok || pos != (Position{Path: unit, Line: 4, EndLine: 4}) {
+		t.Fatalf("Locate(User) = %#v, want the [Service] header", pos)
+	}
+	if _, ok := f.Locate("Socket", "Unit"); ok {
+		t.Fatalf("expected no position without a [Socket] section")
+	}
+
+	// The earlier dir wins for drop-ins of the same name.
+	f, err = ParseWithDropInDirs(unit, instanceDir, unit+".d")
+	if err != nil {
+		t.Fatalf("ParseWithDropInDirs() error = %v", err)
+	}
+	if pos, _ := f.Locate("Service", "PrivateNetwork"); pos.Path != filepath.Join(instanceDir, "10-net.conf") || pos.Line != 3 {
+		t.Fatalf("Locate(PrivateNetwork) = %#v, want the instance drop-in", pos)
+	}
+}
+
+func mustWrite(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+// Package version reports the version of ssg itself.
+package version
+
+import "runtime/debug"
+
+// Version is set at build time with
+// -ldflags "-X github.com/teunlao/systemd-security-gate/internal/version.Version=v0.x.y".
+var Version = ""
+
+// String returns Version, or else the module version ssg was built from
+// ("go install ...@v0.x.y"), or "dev".
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}